@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Список ID администраторов бота из переменной ADMIN_IDS (через запятую)
+func getAdminIDs() map[int64]bool {
+	admins := make(map[int64]bool)
+	for _, value := range strings.Split(os.Getenv("ADMIN_IDS"), ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			admins[id] = true
+		}
+	}
+	return admins
+}
+
+func isAdmin(userID int64) bool {
+	return getAdminIDs()[userID]
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/cache"
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/parser"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+func main() {
+	k := flag.Int("k", 5, "Количество кластеров")
+	dataDir := flag.String("data", "data", "Папка с документами")
+	cachePath := flag.String("cache", "cache/embeddings.json", "Файл кэша эмбеддингов")
+	simHashThreshold := flag.Int("simhash-threshold", vectorstore.GetSimHashThreshold(), "Максимальное расстояние Хэмминга между SimHash почти одинаковых документов")
+	flag.Parse()
+
+	fmt.Println("=== Анализ тематик базы знаний ===")
+
+	markdownParser := parser.NewMarkdownParser()
+	documents, _, err := markdownParser.ParseDirectory(*dataDir)
+	if err != nil {
+		log.Fatalf("Ошибка парсинга: %v", err)
+	}
+	fmt.Printf("Найдено документов: %d\n", len(documents))
+
+	// Эмбеддинги берем из кэша, недостающие генерируем через LLM
+	embeddingCache := cache.NewEmbeddingCache(*cachePath)
+	llmClient := llm.NewHTTPLLM(llm.GetApiURL())
+	generated := 0
+
+	for i, doc := range documents {
+		if embedding, found := embeddingCache.GetEmbedding(doc); found {
+			documents[i].Embedding = embedding
+			continue
+		}
+
+		embedding, err := llmClient.GenerateEmbedding(context.Background(), doc.Title+"\n"+doc.Content)
+		if err != nil {
+			log.Printf("Ошибка генерации эмбеддинга для %s: %v", doc.ID, err)
+			continue
+		}
+
+		documents[i].Embedding = embedding
+		generated++
+		if err := embeddingCache.SetEmbedding(doc, embedding); err != nil {
+			log.Printf("Ошибка сохранения эмбеддинга в кэш для %s: %v", doc.ID, err)
+		}
+	}
+
+	if generated > 0 {
+		if err := embeddingCache.FlushCache(); err != nil {
+			log.Printf("Ошибка сохранения кэша: %v", err)
+		}
+	}
+
+	vectorStore := vectorstore.NewVectorStore()
+	vectorStore.AddDocuments(documents)
+
+	clusters, err := vectorStore.Cluster(*k)
+	if err != nil {
+		log.Fatalf("Ошибка кластеризации: %v", err)
+	}
+
+	for i, cluster := range clusters {
+		fmt.Printf("\n--- Кластер %d: \"%s\" (документов: %d) ---\n", i+1, cluster[0].Title, len(cluster))
+		for _, doc := range cluster {
+			fmt.Printf("- %s (%s)\n", doc.Title, doc.ID)
+		}
+	}
+
+	duplicates := vectorStore.FindNearDuplicates(*simHashThreshold)
+	fmt.Printf("\n--- Почти одинаковые документы (групп: %d) ---\n", len(duplicates))
+	for _, group := range duplicates {
+		fmt.Printf("- %s\n", strings.Join(group, ", "))
+	}
+
+	fmt.Println("\n=== Анализ завершен ===")
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ad/rag-bot/internal/cache"
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/parser"
+	"github.com/ad/rag-bot/internal/retrieval"
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// benchmarkQuery - строка файла запросов
+type benchmarkQuery struct {
+	Query string `json:"query"`
+}
+
+// timingEngine замеряет время генерации эмбеддинга запроса внутри FindRelevantDocuments
+type timingEngine struct {
+	llm.LLMEngine
+	lastEmbedding time.Duration
+}
+
+func (t *timingEngine) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	started := time.Now()
+	embedding, err := t.LLMEngine.GenerateEmbedding(ctx, text)
+	t.lastEmbedding = time.Since(started)
+	return embedding, err
+}
+
+func main() {
+	queriesFile := flag.String("queries-file", "", "JSONL-файл с запросами вида {\"query\":\"...\"}")
+	dataDir := flag.String("data", "data", "Папка с документами")
+	cachePath := flag.String("cache", "cache/embeddings.json", "Файл кэша эмбеддингов")
+	limit := flag.Int("limit", 2, "Количество документов для ответа")
+	outputFormat := flag.String("format", "table", "Формат отчета: table или json")
+	batch := flag.Bool("batch", false, "Генерировать ответы параллельно через BatchAnswer (до LLM_MAX_CONCURRENCY одновременно)")
+	flag.Parse()
+
+	if *queriesFile == "" {
+		log.Fatal("Укажите --queries-file")
+	}
+	if *outputFormat != "table" && *outputFormat != "json" {
+		log.Fatalf("Неизвестный формат отчета: %s (допустимо table или json)", *outputFormat)
+	}
+
+	queries, err := loadQueries(*queriesFile)
+	if err != nil {
+		log.Fatalf("Ошибка чтения запросов: %v", err)
+	}
+	if len(queries) == 0 {
+		log.Fatal("Файл запросов пуст")
+	}
+
+	llmClient := llm.NewHTTPLLM(llm.GetApiURL())
+	embeddingCache := cache.NewEmbeddingCache(*cachePath, cache.WithMaxEntries(cache.GetCacheMaxEntries()))
+
+	documents, err := loadDocuments(*dataDir, embeddingCache, llmClient)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки документов: %v", err)
+	}
+
+	vectorStore := vectorstore.NewVectorStore()
+	vectorStore.AddDocuments(documents)
+
+	engine := &timingEngine{LLMEngine: llmClient}
+	retrievalEngine := retrieval.NewVectorRetrieval(vectorStore, engine)
+
+	var embeddingTimes, searchTimes, generationTimes, totalTimes []time.Duration
+	failed := 0
+
+	// В режиме --batch ответы генерируются одной пачкой после поиска документов для всех запросов
+	var batchQueries []string
+	var batchDocs [][]llm.Document
+	var retrievalTimes []time.Duration
+
+	started := time.Now()
+	for _, query := range queries {
+		queryStarted := time.Now()
+
+		engine.lastEmbedding = 0
+		docs, err := retrievalEngine.FindRelevantDocuments(query, *limit)
+		retrievalTime := time.Since(queryStarted)
+		if err != nil {
+			log.Printf("Ошибка поиска для %q: %v", query, err)
+			failed++
+			continue
+		}
+
+		embeddingTimes = append(embeddingTimes, engine.lastEmbedding)
+		searchTimes = append(searchTimes, retrievalTime-engine.lastEmbedding)
+
+		if *batch {
+			batchQueries = append(batchQueries, query)
+			batchDocs = append(batchDocs, toLLMDocuments(docs))
+			retrievalTimes = append(retrievalTimes, retrievalTime)
+			continue
+		}
+
+		generationStarted := time.Now()
+		if _, err := llmClient.Answer(context.Background(), query, toLLMDocuments(docs)); err != nil {
+			log.Printf("Ошибка генерации ответа для %q: %v", query, err)
+			failed++
+			continue
+		}
+		generationTime := time.Since(generationStarted)
+
+		generationTimes = append(generationTimes, generationTime)
+		totalTimes = append(totalTimes, time.Since(queryStarted))
+	}
+
+	if len(batchQueries) > 0 {
+		// Каждый запрос пачки ждет ее целиком, поэтому время генерации у всех одинаковое
+		generationStarted := time.Now()
+		answers, err := llmClient.BatchAnswer(context.Background(), batchQueries, batchDocs)
+		generationTime := time.Since(generationStarted)
+		if err != nil {
+			log.Printf("Ошибка генерации ответов: %v", err)
+		}
+
+		for i, answer := range answers {
+			// Пустой ответ бывает только у запроса с ошибкой: Answer заменяет пустой ответ модели текстом-заглушкой
+			if answer == "" {
+				failed++
+				continue
+			}
+			generationTimes = append(generationTimes, generationTime)
+			totalTimes = append(totalTimes, retrievalTimes[i]+generationTime)
+		}
+	}
+	elapsed := time.Since(started)
+
+	cacheStats := embeddingCache.GetRuntimeStats()
+	report := Report{
+		Queries:    len(queries),
+		Failed:     failed,
+		Throughput: float64(len(totalTimes)) / elapsed.Seconds(),
+		Stages: []StageStats{
+			newStageStats("embedding", embeddingTimes),
+			newStageStats("search", searchTimes),
+			newStageStats("generation", generationTimes),
+			newStageStats("total", totalTimes),
+		},
+	}
+	if lookups := cacheStats.Hits + cacheStats.Misses; lookups > 0 {
+		report.CacheHitRate = float64(cacheStats.Hits) / float64(lookups)
+	}
+
+	if *outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Ошибка вывода отчета: %v", err)
+		}
+		return
+	}
+
+	report.WriteTable(os.Stdout)
+}
+
+// loadQueries читает запросы из JSONL-файла, пропуская пустые строки
+func loadQueries(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var queries []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var query benchmarkQuery
+		if err := json.Unmarshal([]byte(text), &query); err != nil {
+			return nil, fmt.Errorf("строка %d: %w", line, err)
+		}
+		if query.Query != "" {
+			queries = append(queries, query.Query)
+		}
+	}
+
+	return queries, scanner.Err()
+}
+
+// loadDocuments загружает документы и их эмбеддинги: из кэша, недостающие генерирует через LLM
+func loadDocuments(dataDir string, embeddingCache *cache.EmbeddingCache, llmClient llm.LLMEngine) ([]types.Document, error) {
+	documents, _, err := parser.NewMarkdownParser().ParseDirectory(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := 0
+	for i, doc := range documents {
+		if embedding, found := embeddingCache.GetEmbedding(doc); found {
+			documents[i].Embedding = embedding
+			continue
+		}
+
+		embedding, err := llmClient.GenerateEmbedding(context.Background(), doc.Title+"\n"+doc.Content)
+		if err != nil {
+			log.Printf("Ошибка генерации эмбеддинга для %s: %v", doc.ID, err)
+			continue
+		}
+
+		documents[i].Embedding = embedding
+		generated++
+		if err := embeddingCache.SetEmbedding(doc, embedding); err != nil {
+			log.Printf("Ошибка сохранения эмбеддинга в кэш для %s: %v", doc.ID, err)
+		}
+	}
+
+	if generated > 0 {
+		if err := embeddingCache.FlushCache(); err != nil {
+			log.Printf("Ошибка сохранения кэша: %v", err)
+		}
+	}
+
+	return documents, nil
+}
+
+func toLLMDocuments(docs []types.Document) []llm.Document {
+	llmDocs := make([]llm.Document, 0, len(docs))
+	for _, doc := range docs {
+		llmDocs = append(llmDocs, llm.Document{
+			ID:           doc.ID,
+			Header:       doc.Title,
+			Link:         doc.URL,
+			Text:         doc.Content,
+			CodeSnippets: parser.CodeSnippets(doc),
+
+			ReadingTimeSeconds: doc.ReadingTimeSeconds,
+		})
+	}
+	return llmDocs
+}
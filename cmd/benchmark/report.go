@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// StageStats - перцентили задержки одного этапа конвейера
+type StageStats struct {
+	Stage string        `json:"stage"`
+	P50   time.Duration `json:"p50_ns"`
+	P95   time.Duration `json:"p95_ns"`
+	P99   time.Duration `json:"p99_ns"`
+}
+
+// Report - итог прогона бенчмарка
+type Report struct {
+	Queries      int          `json:"queries"`
+	Failed       int          `json:"failed"`
+	Throughput   float64      `json:"throughput_qps"`
+	CacheHitRate float64      `json:"cache_hit_rate"`
+	Stages       []StageStats `json:"stages"`
+}
+
+func newStageStats(stage string, durations []time.Duration) StageStats {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	return StageStats{
+		Stage: stage,
+		P50:   percentile(sorted, 50),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+	}
+}
+
+// percentile возвращает перцентиль p по методу ближайшего ранга; sorted должен быть отсортирован
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// WriteTable выводит отчет в виде таблицы
+func (r Report) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "Запросов: %d (ошибок: %d)\n", r.Queries, r.Failed)
+	fmt.Fprintf(w, "Пропускная способность: %.2f запросов/сек\n", r.Throughput)
+	fmt.Fprintf(w, "Попадания в кэш эмбеддингов: %.1f%%\n\n", r.CacheHitRate*100)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Этап\tp50\tp95\tp99\t")
+	for _, stage := range r.Stages {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", stage.Stage, stage.P50, stage.P95, stage.P99)
+	}
+	tw.Flush()
+}
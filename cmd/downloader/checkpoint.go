@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Checkpoint хранит список уже обработанных URL, чтобы прерванную загрузку можно было продолжить
+type Checkpoint struct {
+	path string
+	done map[string]bool
+	mu   sync.Mutex
+}
+
+// LoadCheckpoint читает файл контрольной точки (по одному URL на строку). Отсутствующий файл не является ошибкой.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		path: path,
+		done: make(map[string]bool),
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла контрольной точки: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if url := strings.TrimSpace(scanner.Text()); url != "" {
+			cp.done[url] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла контрольной точки: %w", err)
+	}
+
+	return cp, nil
+}
+
+// NewCheckpoint начинает загрузку с пустой контрольной точкой: файл прежней загрузки удаляется
+func NewCheckpoint(path string) (*Checkpoint, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("ошибка удаления файла контрольной точки: %w", err)
+	}
+
+	return &Checkpoint{
+		path: path,
+		done: make(map[string]bool),
+	}, nil
+}
+
+// IsDone сообщает, была ли страница уже сохранена. Ключ - адрес из sitemap, как и в MarkDone.
+func (cp *Checkpoint) IsDone(url string) bool {
+	if cp == nil {
+		return false
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return cp.done[url]
+}
+
+// MarkDone дописывает адрес страницы из sitemap (не итоговый URL после редиректов) в файл контрольной точки
+func (cp *Checkpoint) MarkDone(url string) error {
+	if cp == nil {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	file, err := os.OpenFile(cp.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла контрольной точки: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, url); err != nil {
+		return fmt.Errorf("ошибка записи в файл контрольной точки: %w", err)
+	}
+
+	cp.done[url] = true
+	return nil
+}
+
+// Len возвращает количество уже обработанных URL
+func (cp *Checkpoint) Len() int {
+	if cp == nil {
+		return 0
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return len(cp.done)
+}
+
+// Clear удаляет файл контрольной точки после завершенной загрузки, чтобы следующий запуск начался заново
+func (cp *Checkpoint) Clear() error {
+	if cp == nil {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка удаления файла контрольной точки: %w", err)
+	}
+
+	cp.done = make(map[string]bool)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+)
+
+// compileExcludePatterns компилирует регулярные выражения --exclude-pattern
+func compileExcludePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный --exclude-pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// isExcluded сообщает, подходит ли URL под одно из исключающих выражений
+func isExcluded(pageURL string, exclude []*regexp.Regexp) bool {
+	for _, re := range exclude {
+		if re.MatchString(pageURL) {
+			slog.Debug("URL пропущен по --exclude-pattern", "url", pageURL, "pattern", re.String())
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Цепочка запасных селекторов для страниц без div.help-article__main.
+// Обрабатываются прямые потомки первого найденного элемента.
+var fallbackSelectors = []string{"article", "main", "#content", "body"}
+
+// Элементы, которые не несут полезного содержимого
+var fallbackExcludedTags = map[string]bool{
+	"nav":      true,
+	"header":   true,
+	"footer":   true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+}
+
+// getMinContentChars возвращает минимальную длину текста, при которой страница сохраняется
+func getMinContentChars() int {
+	if value, err := strconv.Atoi(os.Getenv("MIN_CONTENT_CHARS")); err == nil && value >= 0 {
+		return value
+	}
+	return 200
+}
+
+// extractFallbackContent извлекает содержимое по цепочке запасных селекторов.
+// Возвращает markdown-текст и текст без разметки; пустые строки, если ничего не найдено.
+func extractFallbackContent(e *colly.HTMLElement) (string, string) {
+	for _, selector := range fallbackSelectors {
+		selection := e.DOM.Find(selector).First()
+		if selection.Length() == 0 {
+			continue
+		}
+
+		el := colly.NewHTMLElementFromSelectionNode(e.Response, selection, selection.Nodes[0], 0)
+
+		var content, plainText strings.Builder
+		forEachChild(el, "*", func(_ int, child *colly.HTMLElement) {
+			if fallbackExcludedTags[child.Name] {
+				return
+			}
+			processElement(child, &content, 0)
+			plainText.WriteString(child.Text + " ")
+		})
+
+		if text := cleanText(content.String()); text != "" {
+			return text, cleanText(plainText.String())
+		}
+	}
+
+	return "", ""
+}
+
+// SkippedLog записывает пропущенные страницы в файл
+type SkippedLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewSkippedLog(path string) *SkippedLog {
+	return &SkippedLog{path: path}
+}
+
+func (l *SkippedLog) Add(pageURL, reason string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия %s: %w", l.path, err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), pageURL, reason)
+	return err
+}
+
+// textLength возвращает длину текста в символах
+func textLength(text string) int {
+	return utf8.RuneCountInString(strings.TrimSpace(text))
+}
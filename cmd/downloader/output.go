@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// JSONLRecord - одна страница в выходном JSONL-файле
+type JSONLRecord struct {
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Content   string `json:"content"`
+	ScrapedAt string `json:"scraped_at"`
+}
+
+// JSONLWriter дописывает страницы в один JSONL-файл (по одному JSON-объекту на строку)
+type JSONLWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+	mu      sync.Mutex
+}
+
+func NewJSONLWriter(path string) (*JSONLWriter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла %s: %w", path, err)
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+
+	return &JSONLWriter{
+		file:    file,
+		encoder: encoder,
+	}, nil
+}
+
+func (w *JSONLWriter) Write(record JSONLRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.encoder.Encode(record)
+}
+
+func (w *JSONLWriter) Close() error {
+	return w.file.Close()
+}
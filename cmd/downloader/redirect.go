@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errRedirectLoop возвращается, когда цепочка редиректов возвращается на уже пройденный URL
+var errRedirectLoop = errors.New("обнаружен цикл редиректов")
+
+// getMaxRedirects возвращает максимальное число редиректов для одной страницы (MAX_REDIRECTS)
+func getMaxRedirects() int {
+	if value, err := strconv.Atoi(os.Getenv("MAX_REDIRECTS")); err == nil && value >= 0 {
+		return value
+	}
+	return 5
+}
+
+// RedirectTracker прерывает циклы и слишком длинные цепочки редиректов
+// и запоминает цепочку, которая привела к каждому итоговому URL
+type RedirectTracker struct {
+	MaxRedirects int
+
+	mu     sync.Mutex
+	chains map[string][]string // итоговый URL -> цепочка URL от исходного
+}
+
+func NewRedirectTracker(maxRedirects int) *RedirectTracker {
+	return &RedirectTracker{
+		MaxRedirects: maxRedirects,
+		chains:       make(map[string][]string),
+	}
+}
+
+// CheckRedirect подходит для colly.Collector.SetRedirectHandler
+func (t *RedirectTracker) CheckRedirect(req *http.Request, via []*http.Request) error {
+	chain := make([]string, 0, len(via)+1)
+	for _, prev := range via {
+		chain = append(chain, prev.URL.String())
+	}
+	chain = append(chain, req.URL.String())
+
+	// Первый возврат на исходную страницу (A -> A, A -> B -> A) - не цикл: так сайты ставят
+	// cookie сессии, и colly такой редирект разрешает. Цикл - повтор любого URL после этого.
+	for _, prev := range via[1:] {
+		if prev.URL.String() == req.URL.String() {
+			log.Printf("WARNING: цикл редиректов: %s", strings.Join(chain, " -> "))
+			return fmt.Errorf("%w: %s", errRedirectLoop, req.URL)
+		}
+	}
+
+	if len(via) > t.MaxRedirects {
+		log.Printf("WARNING: больше %d редиректов: %s", t.MaxRedirects, strings.Join(chain, " -> "))
+		return fmt.Errorf("превышено число редиректов (%d) для %s", t.MaxRedirects, via[0].URL)
+	}
+
+	t.mu.Lock()
+	t.chains[req.URL.String()] = chain
+	t.mu.Unlock()
+
+	return nil
+}
+
+// Chain возвращает цепочку редиректов, которая привела к finalURL, или nil, если редиректов не было
+func (t *RedirectTracker) Chain(finalURL string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.chains[finalURL]
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseDate разбирает дату в формате RFC3339 (2024-01-01T10:00:00+03:00) или 2024-01-01
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("некорректная дата %q: ожидается 2024-01-01 или 2024-01-01T00:00:00Z", value)
+}
+
+// filterSince оставляет записи sitemap, измененные после since.
+// Записи без <lastmod> или с нераспознанной датой оставляются, чтобы не пропустить изменения.
+func filterSince(entries []URL, since time.Time) []URL {
+	var filtered []URL
+	for _, entry := range entries {
+		if entry.LastMod != "" {
+			if lastMod, err := parseDate(entry.LastMod); err == nil && !lastMod.After(since) {
+				continue
+			}
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// truncatedMarker дописывается в конец обрезанного markdown
+const truncatedMarker = "<!-- truncated -->"
+
+// truncateContent обрезает содержимое страницы до maxBytes байт и дописывает truncatedMarker.
+// Обрезка идет по концу последней целой строки, а если строк нет - по границе символа UTF-8.
+// maxBytes <= 0 - без ограничения. Второе значение - было ли содержимое обрезано.
+func truncateContent(content string, maxBytes int) (string, bool) {
+	if maxBytes <= 0 || len(content) <= maxBytes {
+		return content, false
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	truncated := content[:cut]
+	if newline := strings.LastIndexByte(truncated, '\n'); newline > 0 {
+		truncated = truncated[:newline]
+	}
+
+	return strings.TrimRight(truncated, " \t\n") + "\n\n" + truncatedMarker, true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	scrapedAtLineRegex = regexp.MustCompile(`(?m)^\*\*ScrapedAt:\*\*\s+(\S+)\s*$`)
+	dateLinesRegex     = regexp.MustCompile(`(?m)^\*\*(ScrapedAt|UpdatedAt):\*\*.*$`)
+)
+
+// previousScrape читает ранее сохраненную версию страницы.
+// Возвращает дату первой загрузки и признак того, что содержимое не изменилось.
+func previousScrape(filePath, markdownContent string) (scrapedAt string, unchanged bool, found bool) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", false, false
+	}
+
+	previous := string(data)
+	if match := scrapedAtLineRegex.FindStringSubmatch(previous); len(match) > 1 {
+		scrapedAt = match[1]
+	}
+
+	return scrapedAt, normalizeForCompare(previous) == normalizeForCompare(markdownContent), true
+}
+
+// normalizeForCompare убирает строки с датами и различия в пробелах
+func normalizeForCompare(markdown string) string {
+	return strings.Join(strings.Fields(dateLinesRegex.ReplaceAllString(markdown, "")), " ")
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ad/rag-bot/internal/parser"
+)
+
+// validateSavedFile разбирает сохраненный файл так же, как при индексации, и проверяет,
+// что у документа есть заголовок и содержимое. При strict некорректный файл удаляется.
+func validateSavedFile(markdownParser *parser.MarkdownParser, filePath string, strict bool) error {
+	doc, err := markdownParser.ParseFile(filePath)
+
+	var problem string
+	switch {
+	case err != nil:
+		problem = fmt.Sprintf("ошибка разбора: %v", err)
+	case doc.Title == "":
+		problem = "пустой заголовок"
+	case doc.Content == "":
+		problem = "пустое содержимое"
+	default:
+		return nil
+	}
+
+	if strict {
+		if err := os.Remove(filePath); err != nil {
+			return fmt.Errorf("%s; ошибка удаления файла: %w", problem, err)
+		}
+		return fmt.Errorf("%s, файл удален", problem)
+	}
+
+	return fmt.Errorf("%s", problem)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ad/rag-bot/internal/fileutil"
+)
+
+// WatchState хранит <lastmod> из sitemap для уже загруженных страниц,
+// чтобы в режиме --watch скачивать только новые и измененные страницы
+type WatchState struct {
+	path    string
+	lastMod map[string]string
+	mu      sync.Mutex
+}
+
+func LoadWatchState(path string) (*WatchState, error) {
+	state := &WatchState{
+		path:    path,
+		lastMod: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &state.lastMod); err != nil {
+		return nil, fmt.Errorf("ошибка разбора %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// Changed возвращает URL, которых нет в состоянии или у которых изменился <lastmod>
+func (s *WatchState) Changed(entries []URL) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []string
+	for _, entry := range entries {
+		lastMod, known := s.lastMod[entry.Loc]
+		if !known || lastMod != entry.LastMod {
+			changed = append(changed, entry.Loc)
+		}
+	}
+
+	return changed
+}
+
+// Update запоминает <lastmod> загруженной страницы и сохраняет состояние на диск
+func (s *WatchState) Update(pageURL, lastMod string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastMod[pageURL] = lastMod
+
+	data, err := json.MarshalIndent(s.lastMod, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации состояния: %w", err)
+	}
+
+	return fileutil.AtomicWrite(s.path, data, 0644)
+}
+
+// errWebhookUnauthorized - бот отклонил webhook из-за токена; повтор без исправления --webhook-token не поможет
+var errWebhookUnauthorized = errors.New("webhook отклонен: неверный или отсутствующий токен")
+
+// notifyWebhook сообщает работающему боту о новых и измененных страницах. Если задан token,
+// он передается в заголовке "Authorization: Bearer" (HTTP API бота с API_JWT_SECRET требует JWT).
+// Любой ответ, кроме 2xx, - ошибка.
+func notifyWebhook(webhookURL, token string, pageURLs []string) error {
+	body, err := json.Marshal(map[string][]string{"urls": pageURLs})
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации запроса: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w (HTTP %d)", errWebhookUnauthorized, resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP ошибка: %d, ответ: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
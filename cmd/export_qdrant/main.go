@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ad/rag-bot/internal/cache"
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/parser"
+	"github.com/ad/rag-bot/internal/retrieval"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", retrieval.GetQdrantURL(), "Адрес HTTP API Qdrant")
+	collection := flag.String("collection", retrieval.GetQdrantCollection(), "Имя коллекции")
+	dataDir := flag.String("data", "data", "Папка с документами")
+	cachePath := flag.String("cache", "cache/embeddings.json", "Файл кэша эмбеддингов")
+	flag.Parse()
+
+	markdownParser := parser.NewMarkdownParser()
+	documents, _, err := markdownParser.ParseDirectory(*dataDir)
+	if err != nil {
+		log.Fatalf("Ошибка парсинга: %v", err)
+	}
+	fmt.Printf("Найдено документов: %d\n", len(documents))
+
+	// Эмбеддинги берем из кэша, недостающие генерируем через LLM
+	embeddingCache := cache.NewEmbeddingCache(*cachePath)
+	llmClient := llm.NewHTTPLLM(llm.GetApiURL())
+
+	for i, doc := range documents {
+		if embedding, found := embeddingCache.GetEmbedding(doc); found {
+			documents[i].Embedding = embedding
+			continue
+		}
+
+		embedding, err := llmClient.GenerateEmbedding(context.Background(), doc.Title+"\n"+doc.Content)
+		if err != nil {
+			log.Printf("Ошибка генерации эмбеддинга для %s: %v", doc.ID, err)
+			continue
+		}
+
+		documents[i].Embedding = embedding
+		if err := embeddingCache.SetEmbedding(doc, embedding); err != nil {
+			log.Printf("Ошибка сохранения эмбеддинга в кэш для %s: %v", doc.ID, err)
+		}
+	}
+
+	if err := embeddingCache.FlushCache(); err != nil {
+		log.Printf("Ошибка сохранения кэша: %v", err)
+	}
+
+	vectorStore := vectorstore.NewVectorStore()
+	vectorStore.AddDocuments(documents)
+
+	fmt.Printf("Экспорт в Qdrant %s, коллекция %s...\n", *endpoint, *collection)
+	if err := vectorStore.ExportToQdrant(*endpoint, *collection); err != nil {
+		log.Fatalf("Ошибка экспорта: %v", err)
+	}
+
+	fmt.Println("Экспорт завершен")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	certPath := flag.String("cert", "cert.pem", "Файл сертификата")
+	keyPath := flag.String("key", "key.pem", "Файл закрытого ключа")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "Имена хостов и IP-адреса сертификата через запятую")
+	days := flag.Int("days", 365, "Срок действия сертификата в днях")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Ошибка генерации ключа: %v", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		log.Fatalf("Ошибка генерации серийного номера: %v", err)
+	}
+
+	notBefore := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"rag-bot"}},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(0, 0, *days),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, host := range strings.Split(*hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		log.Fatalf("Ошибка создания сертификата: %v", err)
+	}
+
+	if err := writePEM(*certPath, "CERTIFICATE", certDER, 0644); err != nil {
+		log.Fatal(err)
+	}
+	if err := writePEM(*keyPath, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), 0600); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Самоподписанный сертификат сохранен: %s, ключ: %s (действителен %d дней)\n", *certPath, *keyPath, *days)
+}
+
+func writePEM(path, blockType string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: data}); err != nil {
+		return fmt.Errorf("ошибка записи %s: %w", path, err)
+	}
+
+	return nil
+}
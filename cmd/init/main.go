@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/ad/rag-bot/internal/llm"
+)
+
+// Шаблон docker-compose.yml: Ollama и бот, данные и кэш эмбеддингов монтируются с хоста
+var composeTemplate = template.Must(template.New("compose").Parse(`services:
+  ollama:
+    image: ollama/ollama:latest
+    container_name: ollama
+    ports:
+      - "11434:11434"
+    volumes:
+      - ollama_data:/root/.ollama
+    networks:
+      - rag-net
+    entrypoint: ["/bin/sh", "-c"]
+    command:
+      - |
+        OLLAMA_CONTEXT_LENGTH=${OLLAMA_CONTEXT_LENGTH:-4096} ollama serve
+
+  rag-bot:
+    build:
+      context: .
+      dockerfile: Dockerfile
+    container_name: rag-bot
+    env_file: .env
+    volumes:
+      - {{.DataDir}}:/app/data
+      - {{.CacheDir}}:/app/cache
+    environment:
+      - LLM_API_URL=http://ollama:11434
+    depends_on:
+      - ollama
+    networks:
+      - rag-net
+    restart: on-failure
+
+volumes:
+  ollama_data:
+
+networks:
+  rag-net:
+    driver: bridge
+`))
+
+func main() {
+	outputDir := flag.String("dir", ".", "Папка, в которую записываются .env и docker-compose.yml")
+	force := flag.Bool("force", false, "Перезаписывать существующие файлы без подтверждения")
+	flag.Parse()
+
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Println("Первоначальная настройка rag-bot")
+	fmt.Println()
+
+	token := prompt(reader, "Токен Telegram бота (получите у @BotFather)", "")
+	for token == "" {
+		token = prompt(reader, "Токен не может быть пустым, введите токен", "")
+	}
+	model := prompt(reader, "Модель LLM", llm.GetLLMModel())
+	embeddingsModel := prompt(reader, "Модель эмбеддингов", llm.GetLLMEmbeddingsModel())
+	dataDir := prompt(reader, "Папка с документами", "./data")
+	cacheDir := "./cache"
+
+	for _, dir := range []string{dataDir, cacheDir} {
+		path := dir
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(*outputDir, dir)
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			log.Fatalf("Ошибка создания папки %s: %v", path, err)
+		}
+	}
+
+	env := fmt.Sprintf(`# Telegram Bot Token (получите у @BotFather)
+TELEGRAM_BOT_TOKEN=%s
+
+LLM_MODEL=%s
+LLM_EMBEDDINGS_MODEL=%s
+`, token, model, embeddingsModel)
+
+	// В .env хранится токен, поэтому файл доступен только владельцу
+	envPath := filepath.Join(*outputDir, ".env")
+	if err := writeFile(reader, envPath, []byte(env), 0600, *force); err != nil {
+		log.Fatal(err)
+	}
+
+	var compose strings.Builder
+	if err := composeTemplate.Execute(&compose, struct{ DataDir, CacheDir string }{dataDir, cacheDir}); err != nil {
+		log.Fatalf("Ошибка генерации docker-compose.yml: %v", err)
+	}
+
+	composePath := filepath.Join(*outputDir, "docker-compose.yml")
+	if err := writeFile(reader, composePath, []byte(compose.String()), 0644, *force); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println()
+	fmt.Println("Готово. Дальнейшие шаги:")
+	fmt.Printf("1. Положите markdown-документы в %s (или загрузите их: go run cmd/downloader/main.go)\n", dataDir)
+	fmt.Println("2. Запустите сервисы: docker compose up -d --build")
+	fmt.Printf("3. Загрузите модели: docker exec -it ollama ollama pull %s && docker exec -it ollama ollama pull %s\n", model, embeddingsModel)
+	fmt.Println("4. Напишите боту в Telegram")
+}
+
+// prompt задает вопрос и возвращает ответ или значение по умолчанию при пустом вводе
+func prompt(reader *bufio.Reader, question, defaultValue string) string {
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	answer, err := reader.ReadString('\n')
+	if err != nil && answer == "" {
+		return defaultValue
+	}
+
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue
+	}
+	return answer
+}
+
+// writeFile записывает файл, спрашивая подтверждение, если он уже существует
+func writeFile(reader *bufio.Reader, path string, data []byte, perm os.FileMode, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		answer := prompt(reader, fmt.Sprintf("Файл %s уже существует, перезаписать? (y/N)", path), "n")
+		if !strings.EqualFold(answer, "y") {
+			fmt.Printf("Пропущено: %s\n", path)
+			return nil
+		}
+	}
+
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("ошибка записи %s: %w", path, err)
+	}
+
+	fmt.Printf("Создан файл: %s\n", path)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -15,7 +16,7 @@ func main() {
 
 	fmt.Println("Тестируем генерацию эмбеддингов...")
 
-	embedding, err := client.GenerateEmbedding("Тестовый текст для эмбеддинга")
+	embedding, err := client.GenerateEmbedding(context.Background(), "Тестовый текст для эмбеддинга")
 	if err != nil {
 		log.Printf("Ошибка: %v", err)
 		return
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ad/rag-bot/internal/cache"
+)
+
+func main() {
+	basePath := flag.String("base", "cache/embeddings.json", "Основной файл кэша эмбеддингов")
+	patchPath := flag.String("patch", "", "Файл кэша, записи которого добавляются к основному")
+	outputPath := flag.String("output", "", "Файл результата (по умолчанию перезаписывается --base)")
+	overwrite := flag.Bool("overwrite", false, "Заменять совпадающие записи основного кэша записями --patch")
+	flag.Parse()
+
+	if *patchPath == "" {
+		log.Fatal("Укажите файл --patch")
+	}
+	if *outputPath == "" {
+		*outputPath = *basePath
+	}
+
+	base := cache.NewEmbeddingCache(*basePath)
+	patch := cache.NewEmbeddingCache(*patchPath)
+
+	merged := base
+	if filepath.Clean(*outputPath) != filepath.Clean(*basePath) {
+		// Существующий файл результата загрузился бы в кэш и попал в объединение
+		if _, err := os.Stat(*outputPath); err == nil {
+			log.Fatalf("Файл %s уже существует, укажите другой --output", *outputPath)
+		}
+
+		merged = cache.NewEmbeddingCache(*outputPath)
+		if _, _, err := merged.MergeFrom(base, false); err != nil {
+			log.Fatalf("Ошибка чтения %s: %v", *basePath, err)
+		}
+	}
+
+	baseSize, err := merged.GetCacheStats()
+	if err != nil {
+		log.Fatalf("Ошибка чтения %s: %v", *basePath, err)
+	}
+
+	added, updated, err := merged.MergeFrom(patch, *overwrite)
+	if err != nil {
+		log.Fatalf("Ошибка объединения с %s: %v", *patchPath, err)
+	}
+
+	if err := merged.SaveCache(); err != nil {
+		log.Fatalf("Ошибка сохранения %s: %v", *outputPath, err)
+	}
+
+	fmt.Printf("Основной кэш: %d эмбеддингов, добавлено %d, заменено %d, итого %d\n", baseSize, added, updated, merged.GetCacheSize())
+	fmt.Printf("Результат сохранен в %s\n", *outputPath)
+}
@@ -17,11 +17,13 @@ func main() {
 	parser := parser.NewMarkdownParser()
 
 	// парсинг всей папки
-	docs, err := parser.ParseDirectory("data")
+	docs, stats, err := parser.ParseDirectory("data")
 	if err != nil {
 		log.Printf("Ошибка: %v", err)
 	} else {
 		fmt.Printf("Найдено документов: %d\n", len(docs))
+		fmt.Printf("Файлов: %d, пропущено: %d, без текста: %d, слов в среднем: %d\n",
+			stats.Total, stats.Skipped, stats.ZeroContent, stats.AverageWordCount)
 		for _, doc := range docs {
 			fmt.Printf("- %s (%s)\n", doc.Title, doc.ID)
 		}
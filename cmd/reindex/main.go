@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/cache"
+	"github.com/ad/rag-bot/internal/fileutil"
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/parser"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// botCachePath - кэш эмбеддингов, который загружает бот
+const botCachePath = "cache/embeddings.json"
+
+func main() {
+	dataDir := flag.String("data", "data", "Папка с документами")
+	cachePath := flag.String("cache", botCachePath, "Файл кэша эмбеддингов")
+	snapshotPath := flag.String("snapshot", "", "Файл снимка векторного хранилища (по умолчанию cache/vectorstore.<VECTOR_STORE_FORMAT>)")
+	model := flag.String("model", "", "Модель эмбеддингов вместо LLM_EMBEDDINGS_MODEL (чтобы проверить новую модель, не меняя .env); требует отдельного --cache")
+	flag.Parse()
+
+	if *model != "" {
+		// Ключ кэша не включает модель: бот загрузил бы эмбеддинги другой модели (и размерности) как свои
+		if filepath.Clean(*cachePath) == filepath.Clean(botCachePath) {
+			log.Fatalf("С --model укажите отдельный файл --cache, а не %s: бот использует его с моделью из LLM_EMBEDDINGS_MODEL", botCachePath)
+		}
+		os.Setenv("LLM_EMBEDDINGS_MODEL", *model)
+	}
+
+	format := vectorstore.GetSerializationFormat()
+	if *snapshotPath == "" {
+		*snapshotPath = "cache/vectorstore." + format
+	}
+
+	fmt.Println("=== Перестроение индекса ===")
+	fmt.Printf("Модель эмбеддингов: %s\n", llm.GetLLMEmbeddingsModel())
+
+	markdownParser := parser.NewMarkdownParser()
+	markdownParser.ExtractCodeSnippets = os.Getenv("PARSER_EXTRACT_CODE") == "true"
+	markdownParser.CSVTitleColumn = os.Getenv("CSV_TITLE_COLUMN")
+	markdownParser.CSVContentColumn = os.Getenv("CSV_CONTENT_COLUMN")
+	markdownParser.CSVURLColumn = os.Getenv("CSV_URL_COLUMN")
+	markdownParser.CSVMetadataColumns = os.Getenv("CSV_METADATA_COLUMNS") == "true"
+	markdownParser.DeduplicateContent = os.Getenv("PARSER_DEDUPLICATE") == "true"
+	markdownParser.SortFiles = os.Getenv("PARSER_SORT_FILES") == "true"
+
+	documents, _, err := markdownParser.ParseDirectory(*dataDir)
+	if err != nil {
+		log.Fatalf("Ошибка парсинга: %v", err)
+	}
+	fmt.Printf("Найдено документов: %d\n", len(documents))
+
+	// Размерности эмбеддингов до перестроения берем из текущего кэша
+	embeddingCache := cache.NewEmbeddingCache(*cachePath)
+	before := make(map[int]int)
+	for _, doc := range documents {
+		if embedding, found := embeddingCache.GetEmbedding(doc); found {
+			before[len(embedding)]++
+		}
+	}
+
+	embeddingCache.ClearCache()
+
+	llmClient := llm.NewHTTPLLM(llm.GetApiURL())
+	after := make(map[int]int)
+	failed := 0
+
+	for i, doc := range documents {
+		if i%10 == 0 {
+			fmt.Printf("Обработано %d/%d документов\n", i, len(documents))
+		}
+
+		text := doc.Title + "\n" + doc.Content
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		embedding, err := llmClient.GenerateEmbedding(context.Background(), text)
+		if err != nil {
+			log.Printf("Ошибка генерации эмбеддинга для %s: %v", doc.ID, err)
+			failed++
+			continue
+		}
+
+		documents[i].Embedding = embedding
+		after[len(embedding)]++
+		if err := embeddingCache.SetEmbedding(doc, embedding); err != nil {
+			log.Printf("Ошибка сохранения эмбеддинга в кэш для %s: %v", doc.ID, err)
+		}
+	}
+
+	// Кэш перезаписывается целиком: эмбеддинги прежней модели в него не попадают
+	if err := embeddingCache.FlushCache(); err != nil {
+		log.Fatalf("Ошибка сохранения кэша: %v", err)
+	}
+
+	vectorStore := vectorstore.NewVectorStore(vectorstore.WithInitialCapacity(len(documents)))
+	vectorStore.AddDocuments(documents)
+
+	data, err := vectorStore.Serialize(format)
+	if err != nil {
+		log.Fatalf("Ошибка сериализации хранилища: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(*snapshotPath), 0755); err != nil {
+		log.Fatalf("Ошибка создания папки снимка: %v", err)
+	}
+	if err := fileutil.AtomicWrite(*snapshotPath, data, 0644); err != nil {
+		log.Fatalf("Ошибка сохранения снимка хранилища: %v", err)
+	}
+
+	fmt.Printf("\nЭмбеддингов сгенерировано: %d, ошибок: %d\n", len(documents)-failed, failed)
+	fmt.Printf("Размерность эмбеддингов до:    %s\n", formatDimensions(before))
+	fmt.Printf("Размерность эмбеддингов после: %s\n", formatDimensions(after))
+	fmt.Printf("Кэш: %s, снимок хранилища: %s (%s)\n", *cachePath, *snapshotPath, format)
+	fmt.Println("=== Перестроение завершено ===")
+}
+
+// formatDimensions выводит размерности эмбеддингов и число документов с каждой размерностью
+func formatDimensions(dimensions map[int]int) string {
+	if len(dimensions) == 0 {
+		return "нет эмбеддингов"
+	}
+
+	sizes := make([]int, 0, len(dimensions))
+	for size := range dimensions {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	parts := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		parts = append(parts, fmt.Sprintf("%d (документов: %d)", size, dimensions[size]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -30,7 +31,7 @@ func main() {
 
 	// 2. Парсим документы
 	fmt.Println("2. Парсинг документов...")
-	documents, err := markdownParser.ParseDirectory("data")
+	documents, _, err := markdownParser.ParseDirectory("data")
 	if err != nil {
 		log.Fatalf("Ошибка парсинга: %v", err)
 	}
@@ -44,7 +45,7 @@ func main() {
 		// Комбинируем заголовок и содержимое для эмбеддинга
 		text := doc.Title + "\n" + doc.Content
 
-		embedding, err := llmClient.GenerateEmbedding(text)
+		embedding, err := llmClient.GenerateEmbedding(context.Background(), text)
 		if err != nil {
 			log.Printf("Ошибка генерации эмбеддинга для %s: %v", doc.ID, err)
 			continue
@@ -72,7 +73,7 @@ func main() {
 		fmt.Printf("\n--- Запрос: \"%s\" ---\n", query)
 
 		// Генерируем эмбеддинг для запроса
-		queryEmbedding, err := llmClient.GenerateEmbedding(query)
+		queryEmbedding, err := llmClient.GenerateEmbedding(context.Background(), query)
 		if err != nil {
 			log.Printf("Ошибка генерации эмбеддинга для запроса: %v", err)
 			continue
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/cache"
+	"github.com/ad/rag-bot/internal/parser"
+	"github.com/ad/rag-bot/internal/querylog"
+	"github.com/ad/rag-bot/internal/retrieval"
+	"github.com/ad/rag-bot/internal/vectorstore"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// adminOnly пропускает к обработчику только администраторов
+func adminOnly(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if update.Message == nil {
+			return
+		}
+
+		if !isAdmin(update.Message.From.ID) {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   "Команда доступна только администраторам.",
+			})
+			return
+		}
+
+		next(ctx, b, update)
+	}
+}
+
+// /stats - статистика хранилища и кэша эмбеддингов
+func statsHandler(vectorStore *vectorstore.VectorStore, embeddingCache *cache.EmbeddingCache, rateLimiter *RateLimiter) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		stats := embeddingCache.GetRuntimeStats()
+
+		hitRate := 0.0
+		if total := stats.Hits + stats.Misses; total > 0 {
+			hitRate = float64(stats.Hits) / float64(total) * 100
+		}
+
+		text := fmt.Sprintf("Документов в хранилище: %d\n"+
+			"Эмбеддингов в кэше: %d\n"+
+			"Кэш: %d попаданий, %d промахов (%.1f%% попаданий), %d устаревших, %d вытеснено",
+			vectorStore.GetDocumentCount(),
+			embeddingCache.GetCacheSize(),
+			stats.Hits, stats.Misses, hitRate, stats.Stale, stats.Evictions,
+		)
+
+		if diskSize, err := embeddingCache.GetDiskSize(); err == nil {
+			text += fmt.Sprintf("\nРазмер хранилища кэша: %.1f МБ", float64(diskSize)/(1024*1024))
+		} else {
+			log.Printf("Ошибка получения размера кэша: %v", err)
+			text += "\nРазмер хранилища кэша: н/д"
+		}
+
+		limiterStats := rateLimiter.Stats()
+		text += fmt.Sprintf("\nRate limiter: отслеживается пользователей: %d, отклонено сегодня: %d", limiterStats.ActiveUsers, limiterStats.RejectedToday)
+
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   text,
+		})
+	}
+}
+
+// maxDocumentChars - сколько символов текста документа показывает /document
+const maxDocumentChars = 3000
+
+// /document <id> - заголовок, ссылка и начало текста проиндексированного документа.
+// Документы с Metadata["private"] == "true" показываются только администраторам.
+func documentHandler(vectorStore *vectorstore.VectorStore) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		reply := func(text string) {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   text,
+			})
+		}
+
+		// Первое поле - сама команда (возможно, с @username бота)
+		fields := strings.Fields(update.Message.Text)
+		if len(fields) < 2 {
+			reply("Использование: /document <id>")
+			return
+		}
+
+		doc, found := vectorStore.GetDocument(fields[1])
+		if !found || (doc.Metadata["private"] == "true" && !isAdmin(update.Message.From.ID)) {
+			reply("Документ не найден.")
+			return
+		}
+
+		content := []rune(doc.Content)
+		text := string(content)
+		if len(content) > maxDocumentChars {
+			text = string(content[:maxDocumentChars]) + fmt.Sprintf("...\n\n(показаны первые %d из %d символов)", maxDocumentChars, len(content))
+		}
+
+		reply(fmt.Sprintf("%s\n%s\n\n%s", doc.Title, doc.URL, text))
+	}
+}
+
+// errFiltersOnly - в вопросе боту есть только фильтры, и отвечать не на что
+var errFiltersOnly = errors.New("добавьте к фильтрам вопрос или используйте /search для поиска только по фильтрам")
+
+// parseUserQuery выделяет фильтры field:value из исходного текста вопроса (см. retrieval.ParseStructuredQuery)
+// до выделения сути: выжимка LLM переформулирует запрос и теряет фильтры
+func parseUserQuery(query string) (freeText string, filters map[string]string, err error) {
+	freeText, filters, err = retrieval.ParseStructuredQuery(query)
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.TrimSpace(freeText) == "" {
+		return "", nil, errFiltersOnly
+	}
+	return freeText, filters, nil
+}
+
+// /search <запрос> - список найденных документов без ответа LLM. Поддерживает фильтры field:value
+// (tag:billing title:"счет на оплату"); запрос может состоять из одних фильтров.
+func searchHandler(retrievalEngine retrieval.RetrievalEngine, rateLimiter *RateLimiter, settings *Settings) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		reply := func(text string) {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   text,
+				LinkPreviewOptions: &models.LinkPreviewOptions{
+					IsDisabled: bot.True(),
+				},
+			})
+		}
+
+		// Первое поле - сама команда (возможно, с @username бота)
+		_, query, _ := strings.Cut(strings.TrimSpace(update.Message.Text), " ")
+		if strings.TrimSpace(query) == "" {
+			reply("Использование: /search <запрос>. Фильтры: tag:billing, title:\"счет на оплату\", url:..., id:...")
+			return
+		}
+
+		if !rateLimiter.Allow(update.Message.From.ID) {
+			reply("Слишком много запросов. Подождите ответа на предыдущий запрос.")
+			return
+		}
+
+		freeText, filters, err := retrieval.ParseStructuredQuery(query)
+		if err != nil {
+			reply("Ошибка в запросе: " + err.Error())
+			return
+		}
+
+		docs, err := retrievalEngine.FindWithContext(ctx, freeText, retrieval.SearchOptions{Filters: filters}, settings.TopK())
+		if errors.Is(err, retrieval.ErrInvalidQuery) {
+			reply("Ошибка в запросе: " + err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка поиска документов: %v", err)
+			reply("Ошибка при поиске документов.")
+			return
+		}
+
+		if len(docs) == 0 {
+			reply("Не найдено подходящих документов по вашему запросу.")
+			return
+		}
+
+		var sb strings.Builder
+		for i, doc := range docs {
+			fmt.Fprintf(&sb, "%d. %s\n%s\n", i+1, doc.Title, doc.URL)
+		}
+		reply(sb.String())
+	}
+}
+
+// /top_queries - самые частые вопросы пользователей из журнала запросов
+func topQueriesHandler(queryLog *querylog.QueryLog) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		reply := func(text string) {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   text,
+			})
+		}
+
+		if queryLog == nil {
+			reply("Журнал запросов выключен (задайте QUERY_LOG_PATH).")
+			return
+		}
+
+		top, err := queryLog.TopQueries(10)
+		if err != nil {
+			log.Printf("Ошибка получения частых запросов: %v", err)
+			reply("Ошибка при чтении журнала запросов.")
+			return
+		}
+
+		if len(top) == 0 {
+			reply("Журнал запросов пуст.")
+			return
+		}
+
+		var sb strings.Builder
+		sb.WriteString("Самые частые вопросы:\n")
+		for i, item := range top {
+			fmt.Fprintf(&sb, "%d. %s — %d\n", i+1, item.Essence, item.Count)
+		}
+		reply(sb.String())
+	}
+}
+
+// /settings - текущие настройки; /settings topk <n> - число документов на запрос
+func settingsHandler(settings *Settings) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		reply := func(text string) {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   text,
+			})
+		}
+
+		fields := strings.Fields(update.Message.Text)
+		if len(fields) == 1 {
+			reply(fmt.Sprintf("topk: %d (документов на запрос, максимум %d)", settings.TopK(), settings.maxK))
+			return
+		}
+
+		if len(fields) != 3 || fields[1] != "topk" {
+			reply("Использование: /settings topk <n>")
+			return
+		}
+
+		k, err := strconv.Atoi(fields[2])
+		if err == nil {
+			err = settings.SetTopK(k)
+		}
+		if err != nil {
+			reply(fmt.Sprintf("Некорректное значение: %v", err))
+			return
+		}
+
+		log.Printf("Администратор id%d изменил topk на %d", update.Message.From.ID, k)
+		reply(fmt.Sprintf("topk: %d", k))
+	}
+}
+
+// /restart - перезапуск бота: перезагрузка документов и эмбеддингов без перезапуска процесса
+func restartHandler(restart func(updateID int64)) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "Restarting...",
+		})
+
+		log.Printf("Администратор id%d перезапускает бота", update.Message.From.ID)
+		restart(update.ID)
+	}
+}
+
+// /ingest_url <url> - загрузка страницы в базу знаний без запуска загрузчика
+func ingestURLHandler(ingester *Ingester) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		reply := func(text string) {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   text,
+			})
+		}
+
+		fields := strings.Fields(update.Message.Text)
+		if len(fields) != 2 {
+			reply("Использование: /ingest_url <url>")
+			return
+		}
+		pageURL := fields[1]
+
+		config := parser.GetScraperConfig()
+		if !config.IsAllowed(pageURL) {
+			reply(fmt.Sprintf("URL не разрешен. Допустимые префиксы: %s", strings.Join(config.AllowedPrefixes, ", ")))
+			return
+		}
+
+		_, _ = b.SendChatAction(ctx, &bot.SendChatActionParams{
+			ChatID: update.Message.Chat.ID,
+			Action: models.ChatActionTyping,
+		})
+
+		doc, replaced, err := ingester.IngestURL(ctx, pageURL, config)
+		if err != nil {
+			log.Printf("Ошибка загрузки %s: %v", pageURL, err)
+			reply(fmt.Sprintf("Не удалось загрузить страницу: %v", err))
+			return
+		}
+
+		action := "добавлен"
+		if replaced {
+			action = "обновлен"
+		}
+		log.Printf("Администратор id%d загрузил %s (документ %s %s)", update.Message.From.ID, pageURL, doc.ID, action)
+		reply(fmt.Sprintf("Документ %s %s: %d слов", doc.ID, action, len(strings.Fields(doc.Content))))
+	}
+}
+
+// feedbackCallbackPrefix - префикс данных кнопок оценки ответа (ab_feedback:<id автора вопроса>:1 или :-1)
+const feedbackCallbackPrefix = "ab_feedback:"
+
+// feedbackKeyboard - кнопки оценки ответа под сообщением бота. В данных кнопок хранится автор вопроса:
+// стратегия A/B-теста определяется по нему, а не по тому, кто нажал кнопку (в группе это может быть другой участник).
+func feedbackKeyboard(askerID int64) *models.InlineKeyboardMarkup {
+	data := feedbackCallbackPrefix + strconv.FormatInt(askerID, 10) + ":"
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "👍", CallbackData: data + "1"},
+			{Text: "👎", CallbackData: data + "-1"},
+		}},
+	}
+}
+
+// parseFeedbackData разбирает данные кнопки оценки: id автора вопроса и оценку
+func parseFeedbackData(data string) (askerID int64, rating int, err error) {
+	asker, value, found := strings.Cut(strings.TrimPrefix(data, feedbackCallbackPrefix), ":")
+	if !found {
+		return 0, 0, fmt.Errorf("неверные данные кнопки оценки: %q", data)
+	}
+	if askerID, err = strconv.ParseInt(asker, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("неверный id автора вопроса: %w", err)
+	}
+	if rating, err = strconv.Atoi(value); err != nil {
+		return 0, 0, fmt.Errorf("неверная оценка: %w", err)
+	}
+	return askerID, rating, nil
+}
+
+// feedbackHandler учитывает оценку ответа в A/B-тесте поиска и убирает кнопки оценки.
+// Оценку учитывает только автор вопроса: ответ искала его стратегия.
+func feedbackHandler(abRetrieval *retrieval.ABRetrieval) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		query := update.CallbackQuery
+		if query == nil {
+			return
+		}
+
+		askerID, rating, err := parseFeedbackData(query.Data)
+		switch {
+		case err != nil:
+			// Например, кнопки, отправленные до смены формата данных: оценку не учитываем, кнопки убираем
+			log.Printf("Ошибка оценки ответа: %v", err)
+		case askerID != query.From.ID:
+			_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+				CallbackQueryID: query.ID,
+				Text:            "Оценить ответ может только автор вопроса",
+			})
+			return
+		case abRetrieval != nil:
+			abRetrieval.RecordFeedback(askerID, rating)
+		}
+
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: query.ID,
+			Text:            "Спасибо за оценку!",
+		})
+
+		if message := query.Message.Message; message != nil {
+			_, _ = b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+				ChatID:    message.Chat.ID,
+				MessageID: message.ID,
+			})
+		}
+	}
+}
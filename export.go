@@ -0,0 +1,137 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ad/rag-bot/internal/vectorstore"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// maxExportFileSize - лимит Telegram на размер отправляемого ботом файла.
+// Выгрузка большего размера отправляется сжатой gzip.
+const maxExportFileSize = 50 * 1024 * 1024
+
+// writeExportFile записывает документы хранилища без эмбеддингов во временный JSONL-файл
+// и возвращает его путь. Удалить файл должен вызывающий.
+func writeExportFile(vectorStore *vectorstore.VectorStore) (string, error) {
+	file, err := os.CreateTemp("", "rag-bot-export-*.jsonl")
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, doc := range vectorStore.Snapshot() {
+		doc.Embedding = nil
+		if err := encoder.Encode(doc); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return "", fmt.Errorf("ошибка записи документа %s: %w", doc.ID, err)
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// gzipFile сжимает файл path во временный .jsonl.gz и возвращает путь к сжатому файлу
+func gzipFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "rag-bot-export-*.jsonl.gz")
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("ошибка сжатия файла: %w", err)
+	}
+
+	return dst.Name(), nil
+}
+
+// /export - выгрузка документов хранилища (без эмбеддингов) в JSONL-файл
+func exportHandler(vectorStore *vectorstore.VectorStore) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		reply := func(text string) {
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   text,
+			})
+		}
+
+		_, _ = b.SendChatAction(ctx, &bot.SendChatActionParams{
+			ChatID: update.Message.Chat.ID,
+			Action: models.ChatActionUploadDocument,
+		})
+
+		path, err := writeExportFile(vectorStore)
+		if err != nil {
+			log.Printf("Ошибка выгрузки документов: %v", err)
+			reply(fmt.Sprintf("Не удалось выгрузить документы: %v", err))
+			return
+		}
+		defer os.Remove(path)
+
+		filename := fmt.Sprintf("documents-%s.jsonl", time.Now().Format("2006-01-02"))
+		if info, err := os.Stat(path); err == nil && info.Size() > maxExportFileSize {
+			compressed, err := gzipFile(path)
+			if err != nil {
+				log.Printf("Ошибка сжатия выгрузки: %v", err)
+				reply(fmt.Sprintf("Не удалось сжать выгрузку: %v", err))
+				return
+			}
+			defer os.Remove(compressed)
+
+			path = compressed
+			filename += ".gz"
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			log.Printf("Ошибка открытия выгрузки: %v", err)
+			reply(fmt.Sprintf("Не удалось выгрузить документы: %v", err))
+			return
+		}
+		defer file.Close()
+
+		count := vectorStore.GetDocumentCount()
+		_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+			ChatID:   update.Message.Chat.ID,
+			Document: &models.InputFileUpload{Filename: filename, Data: file},
+			Caption:  fmt.Sprintf("Документов: %d", count),
+		})
+		if err != nil {
+			log.Printf("Ошибка отправки выгрузки: %v", err)
+			reply(fmt.Sprintf("Не удалось отправить файл: %v", err))
+			return
+		}
+
+		log.Printf("Администратор id%d выгрузил %d документов (%s)", update.Message.From.ID, count, filename)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Сколько реплик пользователя хранится и как долго диалог считается продолжающимся
+const (
+	maxHistoryTurns = 2
+	historyTTL      = 10 * time.Minute
+)
+
+// ConversationHistory хранит последние запросы каждого пользователя для уточняющих вопросов
+type ConversationHistory struct {
+	turns     map[int64][]string
+	documents map[int64][]string // ID документов, найденных на последней реплике
+	lastSeen  map[int64]time.Time
+	lastSweep time.Time // когда из карт последний раз удалялись неактивные пользователи
+	mu        sync.Mutex
+}
+
+func NewConversationHistory() *ConversationHistory {
+	return &ConversationHistory{
+		turns:     make(map[int64][]string),
+		documents: make(map[int64][]string),
+		lastSeen:  make(map[int64]time.Time),
+	}
+}
+
+// Get возвращает последние запросы пользователя; после паузы дольше historyTTL диалог начинается заново
+func (h *ConversationHistory) Get(userID int64) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.lastSeen[userID]) > historyTTL {
+		delete(h.turns, userID)
+		delete(h.documents, userID)
+		delete(h.lastSeen, userID)
+		return nil
+	}
+
+	return append([]string(nil), h.turns[userID]...)
+}
+
+// LastDocuments возвращает ID документов, найденных на последней реплике продолжающегося диалога
+func (h *ConversationHistory) LastDocuments(userID int64) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.lastSeen[userID]) > historyTTL {
+		return nil
+	}
+
+	return append([]string(nil), h.documents[userID]...)
+}
+
+// SetLastDocuments запоминает ID документов, найденных по последнему запросу пользователя
+func (h *ConversationHistory) SetLastDocuments(userID int64, ids []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.documents[userID] = ids
+}
+
+// Add запоминает запрос пользователя
+func (h *ConversationHistory) Add(userID int64, query string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	turns := append(h.turns[userID], query)
+	if len(turns) > maxHistoryTurns {
+		turns = turns[len(turns)-maxHistoryTurns:]
+	}
+
+	h.turns[userID] = turns
+	h.lastSeen[userID] = time.Now()
+
+	// Пользователи, которые не вернулись, иначе остались бы в памяти навсегда
+	if time.Since(h.lastSweep) > historyTTL {
+		h.sweep()
+	}
+}
+
+// sweep удаляет диалоги пользователей, неактивных дольше historyTTL. Вызывается под h.mu.
+func (h *ConversationHistory) sweep() {
+	for userID, lastSeen := range h.lastSeen {
+		if time.Since(lastSeen) > historyTTL {
+			delete(h.turns, userID)
+			delete(h.documents, userID)
+			delete(h.lastSeen, userID)
+		}
+	}
+	for userID := range h.documents {
+		if _, ok := h.lastSeen[userID]; !ok {
+			delete(h.documents, userID)
+		}
+	}
+
+	h.lastSweep = time.Now()
+}
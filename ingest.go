@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ad/rag-bot/internal/api"
+	"github.com/ad/rag-bot/internal/cache"
+	"github.com/ad/rag-bot/internal/crawlutil"
+	"github.com/ad/rag-bot/internal/fileutil"
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/parser"
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// Ingester добавляет в хранилище новые и измененные документы во время работы бота
+type Ingester struct {
+	markdownParser *parser.MarkdownParser
+	dataDir        string
+	vectorStore    *vectorstore.VectorStore
+	embeddingCache *cache.EmbeddingCache
+	llmEngine      llm.LLMEngine
+
+	// Обновления выполняются по одному: парсер и сброс кэша не рассчитаны на параллельный запуск
+	mu sync.Mutex
+}
+
+func NewIngester(markdownParser *parser.MarkdownParser, dataDir string, vectorStore *vectorstore.VectorStore,
+	embeddingCache *cache.EmbeddingCache, llmEngine llm.LLMEngine) *Ingester {
+	return &Ingester{
+		markdownParser: markdownParser,
+		dataDir:        dataDir,
+		vectorStore:    vectorStore,
+		embeddingCache: embeddingCache,
+		llmEngine:      llmEngine,
+	}
+}
+
+// IngestURLs - обработчик webhook загрузчика: перечитывает документы из папки с документами
+// и обновляет в хранилище страницы с указанными URL, не дублируя уже загруженные документы
+func (in *Ingester) IngestURLs(ctx context.Context, urls []string) (api.IngestResult, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	var result api.IngestResult
+
+	wanted := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		wanted[url] = true
+	}
+
+	documents, _, err := in.markdownParser.ParseDirectory(in.dataDir)
+	if err != nil {
+		return result, fmt.Errorf("ошибка загрузки документов: %w", err)
+	}
+
+	for _, doc := range documents {
+		if !wanted[doc.URL] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		replaced, err := in.upsert(ctx, doc)
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		if replaced {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+	}
+
+	if err := in.embeddingCache.FlushCache(); err != nil {
+		log.Printf("Ошибка сохранения кэша: %v", err)
+	}
+
+	log.Printf("Webhook: добавлено %d, обновлено %d документов", result.Added, result.Updated)
+	return result, nil
+}
+
+// IngestURL скачивает страницу, сохраняет ее в папку с документами в формате загрузчика
+// и добавляет (или обновляет) документ в хранилище. Возвращает документ и признак замены.
+func (in *Ingester) IngestURL(ctx context.Context, pageURL string, config parser.ScraperConfig) (types.Document, bool, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	// Страница сохраняется в исходном markdown, чтобы документ пережил перезапуск бота,
+	// поэтому вместо ParseURL используются FetchMarkdown и ParseFile
+	markdown, err := in.markdownParser.FetchMarkdown(pageURL, config.ContentSelector)
+	if err != nil {
+		return types.Document{}, false, err
+	}
+
+	// ID документа - имя файла: страницу, уже загруженную в хранилище, перезаписываем в ее же файл,
+	// а новую сохраняем под именем, которое дал бы ей загрузчик, чтобы он потом не создал дубликат
+	filename := crawlutil.Filename(pageURL)
+	if existing, found := in.vectorStore.FindByURL(pageURL); found {
+		filename = existing.ID
+	}
+
+	path := filepath.Join(in.dataDir, filename+".md")
+	if err := os.MkdirAll(in.dataDir, 0755); err != nil {
+		return types.Document{}, false, fmt.Errorf("ошибка создания папки с документами: %w", err)
+	}
+	if err := fileutil.AtomicWrite(path, []byte(markdown), 0644); err != nil {
+		return types.Document{}, false, fmt.Errorf("ошибка сохранения документа: %w", err)
+	}
+
+	doc, err := in.markdownParser.ParseFile(path)
+	if err != nil {
+		return types.Document{}, false, fmt.Errorf("ошибка разбора документа: %w", err)
+	}
+
+	replaced, err := in.upsert(ctx, doc)
+	if err != nil {
+		return types.Document{}, false, err
+	}
+
+	if err := in.embeddingCache.FlushCache(); err != nil {
+		log.Printf("Ошибка сохранения кэша: %v", err)
+	}
+
+	return doc, replaced, nil
+}
+
+// upsert генерирует эмбеддинг документа (или берет его из кэша) и заменяет документ в хранилище.
+// Вызывается под in.mu.
+func (in *Ingester) upsert(ctx context.Context, doc types.Document) (bool, error) {
+	embedding, found := in.embeddingCache.GetEmbedding(doc)
+	if !found {
+		text := doc.Title + "\n" + doc.Content
+		if strings.TrimSpace(text) == "" {
+			return false, fmt.Errorf("пустое содержимое документа %s", doc.ID)
+		}
+
+		var err error
+		embedding, err = in.llmEngine.GenerateEmbedding(ctx, text)
+		if err != nil {
+			return false, fmt.Errorf("ошибка генерации эмбеддинга для %s: %w", doc.ID, err)
+		}
+		if err := in.embeddingCache.SetEmbedding(doc, embedding); err != nil {
+			log.Printf("Ошибка сохранения эмбеддинга в кэш для %s: %v", doc.ID, err)
+		}
+	}
+
+	doc.Embedding = embedding
+	return in.vectorStore.UpsertDocument(doc), nil
+}
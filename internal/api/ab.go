@@ -0,0 +1,16 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ad/rag-bot/internal/retrieval"
+)
+
+// HandleABReport включает маршрут GET /ab_report: число запросов и оценки ответов по стратегиям A/B-теста поиска
+func (s *Server) HandleABReport(ab *retrieval.ABRetrieval) {
+	s.mux.Handle("GET /ab_report", s.protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ab.Report())
+	})))
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Middleware - обертка над HTTP-обработчиком
+type Middleware func(http.Handler) http.Handler
+
+// Chain применяет middleware к обработчику; первая в списке выполняется первой
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// JWTConfig - параметры проверки bearer-токенов
+type JWTConfig struct {
+	Secret   string // ключ подписи HS256
+	Issuer   string // ожидаемое значение iss
+	Audience string // ожидаемое значение aud, если задано
+}
+
+// GetJWTConfig читает параметры JWT из переменных окружения
+func GetJWTConfig() JWTConfig {
+	return JWTConfig{
+		Secret:   os.Getenv("API_JWT_SECRET"),
+		Issuer:   os.Getenv("API_JWT_ISSUER"),
+		Audience: os.Getenv("API_JWT_AUDIENCE"),
+	}
+}
+
+// Validate проверяет, что вместе с ключом подписи задан ожидаемый iss: с пустым Issuer
+// проверка iss не выполняется, и подходит любой токен, подписанный тем же ключом
+func (c JWTConfig) Validate() error {
+	if c.Secret != "" && c.Issuer == "" {
+		return errors.New("API_JWT_ISSUER обязателен, если задан API_JWT_SECRET")
+	}
+	return nil
+}
+
+// BearerAuthMiddleware пропускает только запросы с валидным JWT (HS256) в заголовке
+// "Authorization: Bearer <token>". Проверяются подпись, exp, iss и, если задан, aud.
+// Если конфигурация не проходит Validate, отклоняются все запросы.
+func BearerAuthMiddleware(cfg JWTConfig) Middleware {
+	if err := cfg.Validate(); err != nil {
+		return func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "аутентификация HTTP API не настроена", http.StatusServiceUnavailable)
+			})
+		}
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(cfg.Issuer),
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	parser := jwt.NewParser(options...)
+	keyFunc := func(*jwt.Token) (interface{}, error) {
+		return []byte(cfg.Secret), nil
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || tokenString == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "требуется bearer-токен", http.StatusUnauthorized)
+				return
+			}
+
+			if _, err := parser.Parse(tokenString, keyFunc); err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "недействительный токен", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
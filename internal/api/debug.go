@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// handleDebugDocument отдает диагностическую информацию о документе в текстовом виде
+func (s *Server) handleDebugDocument(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if _, found := s.vectorStore.GetDocument(id); !found {
+		http.Error(w, "документ не найден", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(s.vectorStore.DebugDocument(id)))
+}
+
+// debugSearchResult - результат поиска с объяснением для GET /debug/search
+type debugSearchResult struct {
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	URL           string  `json:"url"`
+	Score         float32 `json:"score"`
+	TopDimensions []int   `json:"top_dimensions"`
+}
+
+// HandleDebugSearch включает маршрут GET /debug/search?query=...&top_k=5: векторный поиск с номерами
+// измерений эмбеддинга, сильнее всего повлиявших на скор каждого документа
+func (s *Server) HandleDebugSearch(embedder vectorstore.Embedder) {
+	s.mux.Handle("GET /debug/search", s.protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := strings.TrimSpace(r.URL.Query().Get("query"))
+		if query == "" {
+			http.Error(w, "параметр query обязателен", http.StatusBadRequest)
+			return
+		}
+
+		topK := 5
+		if value := r.URL.Query().Get("top_k"); value != "" {
+			var err error
+			if topK, err = strconv.Atoi(value); err != nil || topK <= 0 {
+				http.Error(w, "некорректный top_k", http.StatusBadRequest)
+				return
+			}
+		}
+
+		embedding, err := s.vectorStore.QueryEmbedding(r.Context(), query, embedder)
+		if err != nil {
+			http.Error(w, "ошибка генерации эмбеддинга: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		results, err := s.vectorStore.Search(embedding, topK)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		vectorstore.ExplainResults(embedding, results)
+
+		response := make([]debugSearchResult, 0, len(results))
+		for _, result := range results {
+			response = append(response, debugSearchResult{
+				ID:            result.Document.ID,
+				Title:         result.Document.Title,
+				URL:           result.Document.URL,
+				Score:         result.Score,
+				TopDimensions: result.TopDimensions,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})))
+}
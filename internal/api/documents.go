@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// handleDocumentsStream отдает документы в формате NDJSON (один JSON-объект на строку),
+// сбрасывая буфер после каждой строки, чтобы не держать весь список в памяти клиента и сервера.
+// Параметр ?tag=billing оставляет только документы с указанным тегом,
+// ?since=2024-01-01 (или RFC3339) - только документы, загруженные не раньше указанного момента.
+func (s *Server) handleDocumentsStream(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		since, err = parseTime(value)
+		if err != nil {
+			http.Error(w, "некорректный параметр since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, _ := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	for _, doc := range s.vectorStore.Snapshot() {
+		if tag != "" && !slices.Contains(doc.Tags, tag) {
+			continue
+		}
+
+		if !since.IsZero() && doc.CreatedAt.Before(since) {
+			continue
+		}
+
+		if err := encoder.Encode(doc); err != nil {
+			log.Printf("Ошибка записи документа %s в поток: %v", doc.ID, err)
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// parseTime разбирает дату в формате 2024-01-01 или RFC3339
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// IngestResult - итог обработки webhook загрузчика
+type IngestResult struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+}
+
+// IngestFunc заново индексирует страницы с указанными URL
+type IngestFunc func(ctx context.Context, urls []string) (IngestResult, error)
+
+// ingestRequest - тело webhook, которое отправляет downloader --watch --webhook
+type ingestRequest struct {
+	URLs []string `json:"urls"`
+}
+
+// HandleIngest включает маршрут POST /webhook/ingest для обновления документов после загрузки
+func (s *Server) HandleIngest(ingest IngestFunc) {
+	s.mux.Handle("POST /webhook/ingest", s.protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ingestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "некорректное тело запроса: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.URLs) == 0 {
+			http.Error(w, "список urls пуст", http.StatusBadRequest)
+			return
+		}
+
+		result, err := ingest(r.Context(), req.URLs)
+		if err != nil {
+			log.Printf("Ошибка обработки webhook: %v", err)
+			http.Error(w, "ошибка обновления документов", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})))
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ad/rag-bot/internal/vectorstore"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// GetAPIPort возвращает порт HTTP API. Пустая строка означает, что API выключен.
+func GetAPIPort() string {
+	return os.Getenv("API_PORT")
+}
+
+// GetTLSFiles возвращает пути к сертификату и ключу TLS (API_TLS_CERT, API_TLS_KEY)
+func GetTLSFiles() (certFile, keyFile string) {
+	return os.Getenv("API_TLS_CERT"), os.Getenv("API_TLS_KEY")
+}
+
+// Server - HTTP API для доступа к базе знаний
+type Server struct {
+	vectorStore *vectorstore.VectorStore
+	mux         *http.ServeMux
+	auth        []Middleware // middleware для защищенных маршрутов
+}
+
+// NewServer создает HTTP API; ошибка - некорректные настройки JWT (см. JWTConfig.Validate)
+func NewServer(vs *vectorstore.VectorStore) (*Server, error) {
+	s := &Server{
+		vectorStore: vs,
+		mux:         http.NewServeMux(),
+	}
+
+	jwtConfig := GetJWTConfig()
+	if err := jwtConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	if jwtConfig.Secret != "" {
+		s.auth = append(s.auth, BearerAuthMiddleware(jwtConfig))
+	} else {
+		log.Println("ВНИМАНИЕ: API_JWT_SECRET не задан, HTTP API доступен без аутентификации")
+	}
+
+	s.mux.Handle("GET /documents/stream", s.protected(http.HandlerFunc(s.handleDocumentsStream)))
+	s.mux.Handle("GET /metrics", promhttp.Handler())
+	s.mux.Handle("GET /debug/document/{id}", s.protected(http.HandlerFunc(s.handleDebugDocument)))
+
+	return s, nil
+}
+
+// protected оборачивает обработчик в middleware аутентификации
+func (s *Server) protected(h http.Handler) http.Handler {
+	return Chain(h, s.auth...)
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start запускает HTTP-сервер и останавливает его при отмене контекста
+func (s *Server) Start(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if certFile, keyFile := GetTLSFiles(); certFile != "" && keyFile != "" {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		log.Println("ВНИМАНИЕ: API_TLS_CERT и API_TLS_KEY не заданы, HTTP API работает без TLS")
+		err = srv.ListenAndServe()
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("ошибка HTTP сервера: %w", err)
+	}
+
+	return nil
+}
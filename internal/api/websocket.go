@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// Source - документ, на основе которого сформирован ответ
+type Source struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// QueryStreamFunc отвечает на вопрос, передавая фрагменты ответа в onToken по мере генерации.
+// Возвращает документы, использованные для ответа.
+type QueryStreamFunc func(ctx context.Context, query string, onToken func(token string) error) ([]Source, error)
+
+// wsQueryRequest - сообщение клиента WebSocket
+type wsQueryRequest struct {
+	Query string `json:"query"`
+}
+
+// wsQueryMessage - сообщение сервера: фрагмент ответа или, при done, итог с источниками
+type wsQueryMessage struct {
+	Token   string   `json:"token,omitempty"`
+	Done    bool     `json:"done"`
+	Sources []Source `json:"sources,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// GetWSAllowedOrigins возвращает Origin сторонних страниц, которым разрешено открывать /ws/query
+// (API_WS_ALLOWED_ORIGINS, через запятую, например https://example.com)
+func GetWSAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("API_WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	return origins
+}
+
+// checkOrigin не дает сторонним страницам открыть WebSocket из браузера пользователя:
+// браузер всегда отправляет Origin, и он должен совпадать с адресом API или быть в allowedOrigins.
+// Клиенты не из браузера Origin обычно не отправляют, их ограничивает аутентификация API.
+func checkOrigin(config *websocket.Config, req *http.Request, allowedOrigins []string) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	originURL, err := websocket.Origin(config, req)
+	if err != nil {
+		return fmt.Errorf("некорректный Origin %q: %w", origin, err)
+	}
+	config.Origin = originURL
+	if originURL.Host == req.Host {
+		return nil
+	}
+
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(origin, "/"), allowed) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Origin %s не разрешен", origin)
+}
+
+// WSQueryHandler обслуживает WebSocket-соединение: клиент отправляет {"query":"..."},
+// сервер отвечает потоком {"token":"...","done":false} и завершает ответ сообщением
+// {"done":true,"sources":[...]}. В одном соединении можно задать несколько вопросов подряд.
+func WSQueryHandler(query QueryStreamFunc) http.Handler {
+	allowedOrigins := GetWSAllowedOrigins()
+
+	return websocket.Server{Handshake: func(config *websocket.Config, req *http.Request) error {
+		if err := checkOrigin(config, req, allowedOrigins); err != nil {
+			log.Printf("WebSocket отклонен: %v", err)
+			return err
+		}
+		return nil
+	}, Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		ctx := ws.Request().Context()
+
+		for {
+			var req wsQueryRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				if !errors.Is(err, io.EOF) {
+					log.Printf("Ошибка чтения WebSocket: %v", err)
+				}
+				return
+			}
+
+			if strings.TrimSpace(req.Query) == "" {
+				if err := websocket.JSON.Send(ws, wsQueryMessage{Done: true, Error: "пустой запрос"}); err != nil {
+					return
+				}
+				continue
+			}
+
+			sources, err := query(ctx, req.Query, func(token string) error {
+				return websocket.JSON.Send(ws, wsQueryMessage{Token: token})
+			})
+
+			final := wsQueryMessage{Done: true, Sources: sources}
+			if err != nil {
+				log.Printf("Ошибка ответа через WebSocket: %v", err)
+				final.Error = "ошибка генерации ответа"
+			}
+			if err := websocket.JSON.Send(ws, final); err != nil {
+				return
+			}
+		}
+	}}
+}
+
+// HandleQueryStream включает маршрут GET /ws/query для потоковых ответов через WebSocket
+func (s *Server) HandleQueryStream(query QueryStreamFunc) {
+	s.mux.Handle("GET /ws/query", s.protected(WSQueryHandler(query)))
+}
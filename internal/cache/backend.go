@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrCacheCorrupted - данные кэша повреждены; кэш можно пересоздать с нуля
+var ErrCacheCorrupted = errors.New("кэш эмбеддингов поврежден")
+
+// ErrCacheNotLoaded - кэш не был загружен из хранилища, поэтому сохранять его нельзя
+var ErrCacheNotLoaded = errors.New("кэш эмбеддингов не загружен")
+
+// ErrSizeUnavailable - хранилище не сообщает свой размер
+var ErrSizeUnavailable = errors.New("размер хранилища кэша недоступен")
+
+// StorageBackend - хранилище, в котором EmbeddingCache сохраняет эмбеддинги между запусками
+type StorageBackend interface {
+	// Load возвращает все сохраненные эмбеддинги; пустой список, если хранилище еще не создано
+	Load() ([]CachedEmbedding, error)
+	// Save заменяет содержимое хранилища переданными эмбеддингами
+	Save(embeddings []CachedEmbedding) error
+	// Delete удаляет эмбеддинг по ключу вида "documentID:contentHash"
+	Delete(key string) error
+}
+
+// streamingBackend - хранилище, которое умеет читать эмбеддинги по одному, не загружая все в память
+type streamingBackend interface {
+	// Stream вызывает onVersion с версией схемы данных (пусто, если не указана) один раз до первого эмбеддинга,
+	// затем fn для каждого эмбеддинга. Ошибка onVersion прерывает чтение.
+	Stream(onVersion func(version string) error, fn func(CachedEmbedding)) error
+}
+
+// versionedBackend - хранилище, которое сохраняет эмбеддинги вместе с версией схемы CacheData
+type versionedBackend interface {
+	// LoadData возвращает сохраненные данные как есть, без миграции; nil, если хранилище еще не создано
+	LoadData() (*CacheData, error)
+}
+
+// writeThroughBackend - общее хранилище, в которое каждый эмбеддинг записывается сразу при SetEmbedding.
+// SaveCache для него ничего не делает: перезапись хранилища содержимым памяти удалила бы записи других экземпляров.
+type writeThroughBackend interface {
+	// Put добавляет или обновляет один эмбеддинг
+	Put(embedding CachedEmbedding) error
+}
+
+// sizedBackend - хранилище, которое сообщает занимаемый размер (для /stats и метрики rag_cache_disk_bytes)
+type sizedBackend interface {
+	// Size возвращает размер хранилища в байтах; 0, если хранилище еще не создано
+	Size() (int64, error)
+}
+
+// JSONFileBackend хранит эмбеддинги в одном JSON-файле
+type JSONFileBackend struct {
+	path string
+}
+
+var _ StorageBackend = (*JSONFileBackend)(nil)
+var _ versionedBackend = (*JSONFileBackend)(nil)
+var _ sizedBackend = (*JSONFileBackend)(nil)
+
+func NewJSONFileBackend(path string) *JSONFileBackend {
+	return &JSONFileBackend{path: path}
+}
+
+func (b *JSONFileBackend) ensureDir() error {
+	return os.MkdirAll(filepath.Dir(b.path), 0755)
+}
+
+func (b *JSONFileBackend) Load() ([]CachedEmbedding, error) {
+	cacheData, err := b.LoadData()
+	if err != nil || cacheData == nil {
+		return nil, err
+	}
+	return cacheData.Embeddings, nil
+}
+
+func (b *JSONFileBackend) LoadData() (*CacheData, error) {
+	if err := b.ensureDir(); err != nil {
+		return nil, fmt.Errorf("failed to ensure cache directory: %w", err)
+	}
+
+	data, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	var cacheData CacheData
+	if err := json.Unmarshal(data, &cacheData); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCacheCorrupted, err)
+	}
+
+	return &cacheData, nil
+}
+
+// Stream потоково читает файл и вызывает fn для каждого эмбеддинга
+func (b *JSONFileBackend) Stream(onVersion func(version string) error, fn func(CachedEmbedding)) error {
+	if err := b.ensureDir(); err != nil {
+		return fmt.Errorf("failed to ensure cache directory: %w", err)
+	}
+
+	file, err := os.Open(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open cache file: %w", err)
+	}
+	defer file.Close()
+
+	if err := decodeEmbeddings(json.NewDecoder(file), onVersion, fn); err != nil {
+		return fmt.Errorf("failed to parse cache file: %w", err)
+	}
+
+	return nil
+}
+
+func (b *JSONFileBackend) Save(embeddings []CachedEmbedding) error {
+	if err := b.ensureDir(); err != nil {
+		return fmt.Errorf("failed to ensure cache directory: %w", err)
+	}
+
+	cacheData := CacheData{
+		Version:    SchemaVersion,
+		CreatedAt:  time.Now(),
+		Embeddings: embeddings,
+	}
+
+	// Сериализуем в JSON
+	data, err := json.MarshalIndent(cacheData, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache data: %w", err)
+	}
+
+	// Записываем во временный файл, затем перемещаем (атомарная операция)
+	tempPath := b.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp cache file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, b.path); err != nil {
+		os.Remove(tempPath) // Очищаем временный файл при ошибке
+		return fmt.Errorf("failed to move temp cache file: %w", err)
+	}
+
+	return nil
+}
+
+// Delete перезаписывает файл без указанного эмбеддинга
+func (b *JSONFileBackend) Delete(key string) error {
+	embeddings, err := b.Load()
+	if err != nil {
+		return err
+	}
+
+	kept := embeddings[:0]
+	for _, embedding := range embeddings {
+		if embedding.DocumentID+":"+embedding.ContentHash != key {
+			kept = append(kept, embedding)
+		}
+	}
+
+	if len(kept) == len(embeddings) {
+		return nil
+	}
+
+	return b.Save(kept)
+}
+
+// Size возвращает размер файла кэша; 0, если файл еще не создан
+func (b *JSONFileBackend) Size() (int64, error) {
+	info, err := os.Stat(b.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения размера файла кэша: %w", err)
+	}
+	return info.Size(), nil
+}
@@ -1,21 +1,45 @@
 package cache
 
 import (
+	"container/list"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ad/rag-bot/internal/types"
 )
 
 type EmbeddingCache struct {
-	cachePath string
-	cache     map[string]CachedEmbedding
-	mutex     sync.RWMutex
-	loaded    bool
+	backend StorageBackend
+	cache   map[string]CachedEmbedding
+	mutex   sync.RWMutex
+	loaded  bool
+
+	maxEntries int                            // 0 - без ограничения
+	lru        *list.List                     // ключи в порядке обращения, в начале - самые свежие
+	lruIndex   map[string]*list.Element       // ключ -> элемент lru
+	docKeys    map[string]map[string]struct{} // id документа -> ключи его эмбеддингов
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	stale     atomic.Uint64
+	evictions atomic.Uint64
+
+	diskBytes atomic.Int64 // размер хранилища после последнего FlushCache (для метрики)
+}
+
+// Stats - статистика обращений к кэшу с момента запуска процесса
+type Stats struct {
+	Hits      uint64 // эмбеддинг найден
+	Misses    uint64 // эмбеддинг не найден
+	Stale     uint64 // найден эмбеддинг документа, но для устаревшего содержимого
+	Evictions uint64 // эмбеддинги, вытесненные новыми версиями документа или по лимиту MaxEntries
 }
 
 type CachedEmbedding struct {
@@ -23,6 +47,7 @@ type CachedEmbedding struct {
 	ContentHash string    `json:"content_hash"`
 	Embedding   []float32 `json:"embedding"`
 	CreatedAt   time.Time `json:"created_at"`
+	Language    string    `json:"language,omitempty"` // язык документа, пусто - неизвестен
 }
 
 type CacheData struct {
@@ -31,17 +56,52 @@ type CacheData struct {
 	Embeddings []CachedEmbedding `json:"embeddings"`
 }
 
-func NewEmbeddingCache(cachePath string) *EmbeddingCache {
-	return &EmbeddingCache{
-		cachePath: cachePath,
-		cache:     make(map[string]CachedEmbedding),
-		loaded:    false,
+// GetCacheMaxEntries возвращает лимит эмбеддингов в памяти (EMBEDDING_CACHE_MAX_ENTRIES), 0 - без ограничения
+func GetCacheMaxEntries() int {
+	if maxEntries, err := strconv.Atoi(os.Getenv("EMBEDDING_CACHE_MAX_ENTRIES")); err == nil && maxEntries > 0 {
+		return maxEntries
 	}
+	return 0
 }
 
-func (ec *EmbeddingCache) ensureCacheDir() error {
-	dir := filepath.Dir(ec.cachePath)
-	return os.MkdirAll(dir, 0755)
+// CacheOption настраивает EmbeddingCache при создании
+type CacheOption func(*EmbeddingCache)
+
+// WithBackend задает хранилище эмбеддингов вместо JSON-файла по умолчанию
+func WithBackend(backend StorageBackend) CacheOption {
+	return func(ec *EmbeddingCache) {
+		if backend != nil {
+			ec.backend = backend
+		}
+	}
+}
+
+// WithMaxEntries ограничивает число эмбеддингов в памяти; при превышении вытесняются
+// записи, к которым дольше всего не обращались. 0 - без ограничения.
+func WithMaxEntries(n int) CacheOption {
+	return func(ec *EmbeddingCache) {
+		if n > 0 {
+			ec.maxEntries = n
+		}
+	}
+}
+
+// NewEmbeddingCache создает кэш, который по умолчанию хранит эмбеддинги в JSON-файле cachePath
+func NewEmbeddingCache(cachePath string, opts ...CacheOption) *EmbeddingCache {
+	ec := &EmbeddingCache{
+		backend:  NewJSONFileBackend(cachePath),
+		cache:    make(map[string]CachedEmbedding),
+		loaded:   false,
+		lru:      list.New(),
+		lruIndex: make(map[string]*list.Element),
+		docKeys:  make(map[string]map[string]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(ec)
+	}
+
+	return ec
 }
 
 // loadCacheOnce загружает кэш только один раз при первом обращении
@@ -54,34 +114,25 @@ func (ec *EmbeddingCache) loadCacheOnce() error {
 		return nil
 	}
 
-	if err := ec.ensureCacheDir(); err != nil {
-		return fmt.Errorf("failed to ensure cache directory: %w", err)
-	}
-
-	// Проверяем, существует ли файл кэша
-	if _, err := os.Stat(ec.cachePath); os.IsNotExist(err) {
-		fmt.Println("Файл кэша эмбеддингов не найден, будет создан новый")
+	embeddings, err := ec.loadEmbeddings()
+	if errors.Is(err, ErrCacheCorrupted) {
+		fmt.Printf("Ошибка парсинга кэша (будет пересоздан): %v\n", err)
 		ec.loaded = true
 		return nil
 	}
-
-	// Читаем файл кэша
-	data, err := os.ReadFile(ec.cachePath)
 	if err != nil {
-		return fmt.Errorf("failed to read cache file: %w", err)
+		return err
 	}
 
-	var cacheData CacheData
-	if err := json.Unmarshal(data, &cacheData); err != nil {
-		fmt.Printf("Ошибка парсинга кэша (будет пересоздан): %v\n", err)
+	if len(embeddings) == 0 {
+		fmt.Println("Сохраненных эмбеддингов не найдено, кэш будет создан заново")
 		ec.loaded = true
 		return nil
 	}
 
 	// Заполняем карту кэша
-	for _, embedding := range cacheData.Embeddings {
-		key := ec.getCacheKey(embedding.DocumentID, embedding.ContentHash)
-		ec.cache[key] = embedding
+	for _, embedding := range embeddings {
+		ec.put(ec.getCacheKey(embedding.DocumentID, embedding.ContentHash), embedding)
 	}
 
 	ec.loaded = true
@@ -89,13 +140,187 @@ func (ec *EmbeddingCache) loadCacheOnce() error {
 	return nil
 }
 
-// SaveCache сохраняет весь кэш в файл
+// loadEmbeddings загружает эмбеддинги из хранилища; данные с версией схемы перед этим
+// приводятся к SchemaVersion (см. MigrateIfNeeded)
+func (ec *EmbeddingCache) loadEmbeddings() ([]CachedEmbedding, error) {
+	versioned, ok := ec.backend.(versionedBackend)
+	if !ok {
+		return ec.backend.Load()
+	}
+
+	data, err := versioned.LoadData()
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	version := data.Version
+	if err := MigrateIfNeeded(data); err != nil {
+		return nil, err
+	}
+
+	// Общее хранилище не перезаписывается при SaveCache, поэтому мигрированные записи сохраняются сразу
+	if _, ok := ec.backend.(writeThroughBackend); ok && version != data.Version {
+		if err := ec.backend.Save(data.Embeddings); err != nil {
+			return nil, fmt.Errorf("ошибка сохранения мигрированного кэша: %w", err)
+		}
+	}
+	return data.Embeddings, nil
+}
+
+// Preload загружает из хранилища только эмбеддинги указанных документов. JSON-файл разбирается потоково,
+// чтобы не держать в памяти весь файл. После вызова остальные записи хранилища не загружаются,
+// а при следующем сохранении в JSON-файл отбрасываются (в общем хранилище Redis они остаются). Версия схемы проверяется и мигрируется так же, как в loadEmbeddings.
+func (ec *EmbeddingCache) Preload(ids []string) error {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	if ec.loaded {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	// Версия схемы проверяется до первого эмбеддинга, миграции применяются к каждому по мере чтения
+	var plan []migration
+	checkVersion := func(version string) error {
+		var err error
+		plan, err = migrationPlan(version)
+		return err
+	}
+
+	keep := func(embedding CachedEmbedding) {
+		if !wanted[embedding.DocumentID] {
+			return
+		}
+		for _, m := range plan {
+			m.apply(&embedding)
+		}
+		ec.put(ec.getCacheKey(embedding.DocumentID, embedding.ContentHash), embedding)
+	}
+
+	ec.reset()
+	if streaming, ok := ec.backend.(streamingBackend); ok {
+		if err := streaming.Stream(checkVersion, keep); err != nil {
+			ec.reset()
+			return err
+		}
+		for _, m := range plan {
+			fmt.Printf("Кэш эмбеддингов обновлен с версии %s до %s\n", m.from, m.to)
+		}
+	} else {
+		embeddings, err := ec.loadEmbeddings()
+		if err != nil {
+			return err
+		}
+		for _, embedding := range embeddings {
+			keep(embedding)
+		}
+	}
+
+	ec.loaded = true
+	fmt.Printf("Предзагружено %d эмбеддингов из кэша\n", len(ec.cache))
+	return nil
+}
+
+// decodeEmbeddings потоково читает CacheData: вызывает onVersion с версией схемы до первого эмбеддинга
+// и fn для каждого эмбеддинга. SaveCache записывает версию перед эмбеддингами; если версии нет
+// до эмбеддингов, onVersion получает пустую строку, а версия после них считается ошибкой.
+func decodeEmbeddings(decoder *json.Decoder, onVersion func(string) error, fn func(CachedEmbedding)) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	versionChecked := false
+	checkVersion := func(version string) error {
+		versionChecked = true
+		return onVersion(version)
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch key, _ := token.(string); key {
+		case "version":
+			var version string
+			if err := decoder.Decode(&version); err != nil {
+				return err
+			}
+			if versionChecked {
+				return fmt.Errorf("версия кэша %q указана после эмбеддингов", version)
+			}
+			if err := checkVersion(version); err != nil {
+				return err
+			}
+			continue
+		case "embeddings":
+		default:
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !versionChecked {
+			if err := checkVersion(""); err != nil {
+				return err
+			}
+		}
+
+		if err := expectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var embedding CachedEmbedding
+			if err := decoder.Decode(&embedding); err != nil {
+				return err
+			}
+			fn(embedding)
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+
+	if !versionChecked {
+		if err := checkVersion(""); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(decoder, '}')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("ожидался %q, получено %v", delim, token)
+	}
+	return nil
+}
+
+// SaveCache сохраняет весь кэш в хранилище. Кэш, который еще не загружен или не загрузился
+// (например, из-за ErrUnsupportedVersion), не сохраняется: пустая карта в памяти затерла бы данные хранилища.
 func (ec *EmbeddingCache) SaveCache() error {
 	ec.mutex.RLock()
 	defer ec.mutex.RUnlock()
 
-	if err := ec.ensureCacheDir(); err != nil {
-		return fmt.Errorf("failed to ensure cache directory: %w", err)
+	if !ec.loaded {
+		return ErrCacheNotLoaded
+	}
+
+	// Записи уже сохранены по одной в SetEmbedding и MergeFrom
+	if _, ok := ec.backend.(writeThroughBackend); ok {
+		return nil
 	}
 
 	// Конвертируем карту в массив
@@ -104,30 +329,56 @@ func (ec *EmbeddingCache) SaveCache() error {
 		embeddings = append(embeddings, embedding)
 	}
 
-	cacheData := CacheData{
-		Version:    "1.0",
-		CreatedAt:  time.Now(),
-		Embeddings: embeddings,
+	return ec.backend.Save(embeddings)
+}
+
+// MergeFrom добавляет в кэш эмбеддинги из other, которых в нем еще нет (тот же документ и хеш содержимого).
+// При overwrite совпадающие записи заменяются записями other. Возвращает число добавленных и замененных
+// записей; изменения остаются в памяти до SaveCache, а в общее хранилище (Redis) записываются сразу.
+func (ec *EmbeddingCache) MergeFrom(other *EmbeddingCache, overwrite bool) (added, updated int, err error) {
+	if err := other.loadCacheOnce(); err != nil {
+		return 0, 0, fmt.Errorf("ошибка загрузки объединяемого кэша: %w", err)
+	}
+	if err := ec.loadCacheOnce(); err != nil {
+		return 0, 0, fmt.Errorf("ошибка загрузки кэша: %w", err)
 	}
 
-	// Сериализуем в JSON
-	data, err := json.MarshalIndent(cacheData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal cache data: %w", err)
+	other.mutex.RLock()
+	entries := make([]CachedEmbedding, 0, len(other.cache))
+	for _, embedding := range other.cache {
+		entries = append(entries, embedding)
 	}
+	other.mutex.RUnlock()
 
-	// Записываем во временный файл, затем перемещаем (атомарная операция)
-	tempPath := ec.cachePath + ".tmp"
-	if err := os.WriteFile(tempPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp cache file: %w", err)
+	// Старые записи добавляются первыми, чтобы при лимите MaxEntries вытеснялись именно они
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	ec.mutex.Lock()
+	changed := make([]CachedEmbedding, 0, len(entries))
+	for _, embedding := range entries {
+		key := ec.getCacheKey(embedding.DocumentID, embedding.ContentHash)
+		if _, exists := ec.cache[key]; exists {
+			if !overwrite {
+				continue
+			}
+			updated++
+		} else {
+			added++
+		}
+		ec.put(key, embedding)
+		changed = append(changed, embedding)
 	}
+	ec.mutex.Unlock()
 
-	if err := os.Rename(tempPath, ec.cachePath); err != nil {
-		os.Remove(tempPath) // Очищаем временный файл при ошибке
-		return fmt.Errorf("failed to move temp cache file: %w", err)
+	if _, ok := ec.backend.(writeThroughBackend); ok && len(changed) > 0 {
+		if err := ec.backend.Save(changed); err != nil {
+			return added, updated, fmt.Errorf("ошибка сохранения объединенных записей: %w", err)
+		}
 	}
 
-	return nil
+	return added, updated, nil
 }
 
 // GetEmbedding получает эмбеддинг из кэша
@@ -138,41 +389,182 @@ func (ec *EmbeddingCache) GetEmbedding(doc types.Document) ([]float32, bool) {
 		return nil, false
 	}
 
-	ec.mutex.RLock()
-	defer ec.mutex.RUnlock()
+	// Полная блокировка: при попадании обновляется порядок LRU
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
 
 	key := ec.getCacheKey(doc.ID, doc.GetContentHash())
 	if cached, exists := ec.cache[key]; exists {
+		// Документ загружен (впервые или повторно) после кэширования - эмбеддинг считаем устаревшим
+		if modifiedAt := doc.ModifiedAt(); !modifiedAt.IsZero() && modifiedAt.After(cached.CreatedAt) {
+			ec.misses.Add(1)
+			ec.stale.Add(1)
+			return nil, false
+		}
+
+		ec.hits.Add(1)
+		ec.touch(key)
 		return cached.Embedding, true
 	}
 
+	ec.misses.Add(1)
+	if len(ec.docKeys[doc.ID]) > 0 {
+		ec.stale.Add(1)
+	}
+
 	return nil, false
 }
 
-// SetEmbedding сохраняет эмбеддинг в кэш (в памяти)
+// SetEmbedding сохраняет эмбеддинг в кэш в памяти; в общее хранилище (Redis) эмбеддинг записывается сразу,
+// а эмбеддинги прежних версий документа из него удаляются
 func (ec *EmbeddingCache) SetEmbedding(doc types.Document, embedding []float32) error {
 	// Загружаем кэш, если еще не загружен
 	if err := ec.loadCacheOnce(); err != nil {
 		return fmt.Errorf("failed to load cache: %w", err)
 	}
 
-	ec.mutex.Lock()
-	defer ec.mutex.Unlock()
-
 	key := ec.getCacheKey(doc.ID, doc.GetContentHash())
-	ec.cache[key] = CachedEmbedding{
+	entry := CachedEmbedding{
 		DocumentID:  doc.ID,
 		ContentHash: doc.GetContentHash(),
 		Embedding:   embedding,
 		CreatedAt:   time.Now(),
 	}
 
+	ec.mutex.Lock()
+	// Вытесняем эмбеддинги прежних версий документа
+	var outdated []string
+	for oldKey := range ec.docKeys[doc.ID] {
+		if oldKey != key {
+			outdated = append(outdated, oldKey)
+		}
+	}
+	for _, oldKey := range outdated {
+		ec.remove(oldKey)
+		ec.evictions.Add(1)
+	}
+	ec.put(key, entry)
+	ec.mutex.Unlock()
+
+	// Запросы к общему хранилищу выполняются без блокировки кэша
+	writeThrough, ok := ec.backend.(writeThroughBackend)
+	if !ok {
+		return nil
+	}
+	for _, oldKey := range outdated {
+		if err := ec.backend.Delete(oldKey); err != nil {
+			return fmt.Errorf("failed to delete outdated cache entry: %w", err)
+		}
+	}
+	if err := writeThrough.Put(entry); err != nil {
+		return fmt.Errorf("failed to save cache entry: %w", err)
+	}
+
 	return nil
 }
 
+// InvalidateByHash удаляет из кэша все эмбеддинги с указанным хешем содержимого.
+// Возвращает true, если хотя бы одна запись была удалена.
+func (ec *EmbeddingCache) InvalidateByHash(hash string) bool {
+	if err := ec.loadCacheOnce(); err != nil {
+		fmt.Printf("Ошибка загрузки кэша: %v\n", err)
+		return false
+	}
+
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	found := false
+	for key, cached := range ec.cache {
+		if cached.ContentHash == hash {
+			ec.remove(key)
+			found = true
+
+			if err := ec.backend.Delete(key); err != nil {
+				fmt.Printf("Ошибка удаления эмбеддинга %s из хранилища: %v\n", key, err)
+			}
+		}
+	}
+
+	return found
+}
+
 // FlushCache сохраняет кэш на диск
 func (ec *EmbeddingCache) FlushCache() error {
-	return ec.SaveCache()
+	if err := ec.SaveCache(); err != nil {
+		return err
+	}
+
+	if size, err := ec.GetDiskSize(); err == nil {
+		ec.diskBytes.Store(size)
+	}
+	return nil
+}
+
+// GetDiskSize возвращает размер хранилища кэша в байтах: файла для JSONFileBackend, ключа в памяти для Redis.
+// Для хранилища, которое не сообщает размер, возвращает ErrSizeUnavailable.
+func (ec *EmbeddingCache) GetDiskSize() (int64, error) {
+	sized, ok := ec.backend.(sizedBackend)
+	if !ok {
+		return 0, ErrSizeUnavailable
+	}
+	return sized.Size()
+}
+
+// put добавляет запись как самую свежую и вытесняет самые старые записи сверх лимита.
+// Вызывается под блокировкой.
+func (ec *EmbeddingCache) put(key string, embedding CachedEmbedding) {
+	ec.cache[key] = embedding
+	ec.touch(key)
+
+	keys := ec.docKeys[embedding.DocumentID]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		ec.docKeys[embedding.DocumentID] = keys
+	}
+	keys[key] = struct{}{}
+
+	for ec.maxEntries > 0 && len(ec.cache) > ec.maxEntries {
+		oldest := ec.lru.Back()
+		if oldest == nil {
+			break
+		}
+		ec.remove(oldest.Value.(string))
+		ec.evictions.Add(1)
+	}
+}
+
+// touch помечает запись как самую свежую. Вызывается под блокировкой.
+func (ec *EmbeddingCache) touch(key string) {
+	if element, ok := ec.lruIndex[key]; ok {
+		ec.lru.MoveToFront(element)
+		return
+	}
+	ec.lruIndex[key] = ec.lru.PushFront(key)
+}
+
+// remove удаляет запись из кэша, порядка LRU и индекса документов. Вызывается под блокировкой.
+func (ec *EmbeddingCache) remove(key string) {
+	if cached, ok := ec.cache[key]; ok {
+		keys := ec.docKeys[cached.DocumentID]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(ec.docKeys, cached.DocumentID)
+		}
+	}
+	delete(ec.cache, key)
+	if element, ok := ec.lruIndex[key]; ok {
+		ec.lru.Remove(element)
+		delete(ec.lruIndex, key)
+	}
+}
+
+// reset очищает кэш, порядок LRU и индекс документов. Вызывается под блокировкой.
+func (ec *EmbeddingCache) reset() {
+	ec.cache = make(map[string]CachedEmbedding)
+	ec.docKeys = make(map[string]map[string]struct{})
+	ec.lru.Init()
+	ec.lruIndex = make(map[string]*list.Element)
 }
 
 func (ec *EmbeddingCache) getCacheKey(documentID, contentHash string) string {
@@ -191,12 +583,22 @@ func (ec *EmbeddingCache) GetCacheStats() (int, error) {
 	return len(ec.cache), nil
 }
 
+// GetRuntimeStats возвращает статистику обращений к кэшу с момента запуска процесса
+func (ec *EmbeddingCache) GetRuntimeStats() Stats {
+	return Stats{
+		Hits:      ec.hits.Load(),
+		Misses:    ec.misses.Load(),
+		Stale:     ec.stale.Load(),
+		Evictions: ec.evictions.Load(),
+	}
+}
+
 // ClearCache очищает кэш в памяти
 func (ec *EmbeddingCache) ClearCache() {
 	ec.mutex.Lock()
 	defer ec.mutex.Unlock()
 
-	ec.cache = make(map[string]CachedEmbedding)
+	ec.reset()
 }
 
 // GetCacheSize возвращает размер кэша в памяти
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// sharedBackend - общее хранилище в памяти, как RedisEmbeddingCache
+type sharedBackend struct {
+	entries map[string]CachedEmbedding
+	saves   int
+}
+
+func newSharedBackend(entries ...CachedEmbedding) *sharedBackend {
+	b := &sharedBackend{entries: make(map[string]CachedEmbedding)}
+	for _, entry := range entries {
+		b.entries[entry.DocumentID+":"+entry.ContentHash] = entry
+	}
+	return b
+}
+
+func (b *sharedBackend) Load() ([]CachedEmbedding, error) {
+	embeddings := make([]CachedEmbedding, 0, len(b.entries))
+	for _, entry := range b.entries {
+		embeddings = append(embeddings, entry)
+	}
+	return embeddings, nil
+}
+
+func (b *sharedBackend) Save(embeddings []CachedEmbedding) error {
+	b.saves++
+	for _, entry := range embeddings {
+		b.entries[entry.DocumentID+":"+entry.ContentHash] = entry
+	}
+	return nil
+}
+
+func (b *sharedBackend) Delete(key string) error {
+	delete(b.entries, key)
+	return nil
+}
+
+func (b *sharedBackend) Put(embedding CachedEmbedding) error {
+	b.entries[embedding.DocumentID+":"+embedding.ContentHash] = embedding
+	return nil
+}
+
+func TestWriteThroughBackend(t *testing.T) {
+	doc := types.Document{ID: "a", Content: "новое содержимое"}
+	// Запись другого экземпляра и прежняя версия документа a
+	backend := newSharedBackend(
+		CachedEmbedding{DocumentID: "other", ContentHash: "h", Embedding: []float32{1}},
+		CachedEmbedding{DocumentID: "a", ContentHash: "old", Embedding: []float32{2}},
+	)
+
+	ec := NewEmbeddingCache("", WithBackend(backend))
+	if err := ec.SetEmbedding(doc, []float32{3}); err != nil {
+		t.Fatalf("SetEmbedding: %v", err)
+	}
+	if err := ec.SaveCache(); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	if backend.saves != 0 {
+		t.Errorf("SaveCache перезаписал общее хранилище %d раз", backend.saves)
+	}
+	if _, ok := backend.entries["a:"+doc.GetContentHash()]; !ok {
+		t.Error("новый эмбеддинг не записан в хранилище")
+	}
+	if _, ok := backend.entries["a:old"]; ok {
+		t.Error("прежняя версия документа осталась в хранилище")
+	}
+	if _, ok := backend.entries["other:h"]; !ok {
+		t.Error("запись другого экземпляра удалена из хранилища")
+	}
+}
+
+func TestDocumentVersionsIndex(t *testing.T) {
+	backend := newSharedBackend(CachedEmbedding{DocumentID: "a", ContentHash: "old", Embedding: []float32{1}})
+	ec := NewEmbeddingCache("", WithBackend(backend), WithMaxEntries(2))
+
+	doc := types.Document{ID: "a", Content: "новое содержимое"}
+	// Эмбеддинг есть только для прежней версии документа
+	if _, found := ec.GetEmbedding(doc); found {
+		t.Fatal("найден эмбеддинг прежней версии документа")
+	}
+	if stats := ec.GetRuntimeStats(); stats.Stale != 1 {
+		t.Errorf("устаревших обращений %d, ожидалось 1", stats.Stale)
+	}
+
+	if err := ec.SetEmbedding(doc, []float32{2}); err != nil {
+		t.Fatalf("SetEmbedding: %v", err)
+	}
+	if size := ec.GetCacheSize(); size != 1 {
+		t.Errorf("размер кэша %d, ожидалось 1: прежняя версия не вытеснена", size)
+	}
+
+	// Вытеснение по лимиту убирает документ и из индекса
+	for _, id := range []string{"b", "c"} {
+		if err := ec.SetEmbedding(types.Document{ID: id, Content: id}, []float32{3}); err != nil {
+			t.Fatalf("SetEmbedding: %v", err)
+		}
+	}
+	if _, ok := ec.docKeys["a"]; ok {
+		t.Error("вытесненный документ остался в индексе")
+	}
+	if len(ec.docKeys) != ec.GetCacheSize() {
+		t.Errorf("документов в индексе %d, записей в кэше %d", len(ec.docKeys), ec.GetCacheSize())
+	}
+}
+
+func TestStaleEmbeddingByDocumentDate(t *testing.T) {
+	cachedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	before, after := cachedAt.Add(-time.Hour), cachedAt.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		updatedAt *time.Time
+		wantFound bool
+	}{
+		{"без дат", time.Time{}, nil, true},
+		{"загружен до кэширования", before, nil, true},
+		{"загружен после кэширования", after, nil, false},
+		{"обновлен после кэширования", before, &after, false},
+		{"обновлен до кэширования", before, &before, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := types.Document{ID: "a", Content: "текст", CreatedAt: tt.createdAt, UpdatedAt: tt.updatedAt}
+			backend := newSharedBackend(CachedEmbedding{DocumentID: "a", ContentHash: doc.GetContentHash(), Embedding: []float32{1}, CreatedAt: cachedAt})
+			ec := NewEmbeddingCache("", WithBackend(backend))
+
+			if _, found := ec.GetEmbedding(doc); found != tt.wantFound {
+				t.Errorf("эмбеддинг найден = %v, ожидалось %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestGetDiskSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "embeddings.json")
+	ec := NewEmbeddingCache(path)
+
+	if size, err := ec.GetDiskSize(); err != nil || size != 0 {
+		t.Fatalf("размер до сохранения = %d, %v; ожидалось 0", size, err)
+	}
+	if err := ec.SetEmbedding(types.Document{ID: "a", Content: "текст"}, []float32{1}); err != nil {
+		t.Fatalf("SetEmbedding: %v", err)
+	}
+	if err := ec.FlushCache(); err != nil {
+		t.Fatalf("FlushCache: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("файл кэша не создан: %v", err)
+	}
+	if size, err := ec.GetDiskSize(); err != nil || size != info.Size() {
+		t.Errorf("размер = %d, %v; ожидалось %d", size, err, info.Size())
+	}
+
+	// Хранилище без размера не подменяется размером локального файла
+	shared := NewEmbeddingCache(path, WithBackend(newSharedBackend()))
+	if _, err := shared.GetDiskSize(); !errors.Is(err, ErrSizeUnavailable) {
+		t.Errorf("ожидалась ошибка ErrSizeUnavailable, получено %v", err)
+	}
+}
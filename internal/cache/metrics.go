@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterMetrics регистрирует метрики эффективности кэша в Prometheus
+func (ec *EmbeddingCache) RegisterMetrics(reg prometheus.Registerer) error {
+	counters := []struct {
+		name  string
+		help  string
+		value func(Stats) uint64
+	}{
+		{"rag_cache_hits_total", "Количество попаданий в кэш эмбеддингов", func(s Stats) uint64 { return s.Hits }},
+		{"rag_cache_misses_total", "Количество промахов кэша эмбеддингов", func(s Stats) uint64 { return s.Misses }},
+		{"rag_cache_stale_total", "Количество обращений к устаревшим эмбеддингам", func(s Stats) uint64 { return s.Stale }},
+		{"rag_cache_evictions_total", "Количество вытесненных из кэша эмбеддингов", func(s Stats) uint64 { return s.Evictions }},
+	}
+
+	for _, counter := range counters {
+		value := counter.value
+		collector := prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: counter.name,
+			Help: counter.help,
+		}, func() float64 {
+			return float64(value(ec.GetRuntimeStats()))
+		})
+
+		if err := reg.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	entries := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rag_cache_entries",
+		Help: "Количество эмбеддингов в кэше",
+	}, func() float64 {
+		return float64(ec.GetCacheSize())
+	})
+
+	if err := reg.Register(entries); err != nil {
+		return err
+	}
+
+	// Размер хранилища обновляется при каждом FlushCache
+	if size, err := ec.GetDiskSize(); err == nil {
+		ec.diskBytes.Store(size)
+	}
+	diskBytes := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rag_cache_disk_bytes",
+		Help: "Размер хранилища кэша эмбеддингов в байтах (файл на диске или ключ в памяти Redis)",
+	}, func() float64 {
+		return float64(ec.diskBytes.Load())
+	})
+
+	return reg.Register(diskBytes)
+}
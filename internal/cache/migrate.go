@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion - текущая версия формата CacheData; записывается при каждом сохранении кэша
+const SchemaVersion = "1.1"
+
+// ErrUnsupportedVersion - версия сохраненного кэша неизвестна этой сборке (например, кэш записан более новой)
+var ErrUnsupportedVersion = errors.New("неподдерживаемая версия кэша эмбеддингов")
+
+// migration переводит эмбеддинг с версии from на версию to
+type migration struct {
+	from, to string
+	apply    func(embedding *CachedEmbedding)
+}
+
+// migrations применяются по порядку, начиная с версии сохраненного кэша
+var migrations = []migration{
+	// 1.1: у эмбеддинга появился язык документа; для старых записей он неизвестен
+	{from: "1.0", to: "1.1", apply: func(embedding *CachedEmbedding) {
+		embedding.Language = ""
+	}},
+}
+
+// migrationPlan возвращает миграции, которые приводят данные версии version к SchemaVersion.
+// Кэш без версии считается версией 1.0. Неизвестная (например, более новая) версия - ошибка:
+// такой кэш нельзя читать, не рискуя потерять данные при следующем сохранении.
+func migrationPlan(version string) ([]migration, error) {
+	if version == "" {
+		version = "1.0"
+	}
+
+	current := version
+
+	var plan []migration
+	for _, m := range migrations {
+		if current == SchemaVersion {
+			break
+		}
+		if current != m.from {
+			continue
+		}
+
+		plan = append(plan, m)
+		current = m.to
+	}
+
+	if current != SchemaVersion {
+		return nil, fmt.Errorf("%w %s (поддерживается %s)", ErrUnsupportedVersion, version, SchemaVersion)
+	}
+	return plan, nil
+}
+
+// MigrateIfNeeded приводит данные кэша к SchemaVersion, последовательно применяя миграции (см. migrationPlan)
+func MigrateIfNeeded(data *CacheData) error {
+	plan, err := migrationPlan(data.Version)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range plan {
+		for i := range data.Embeddings {
+			m.apply(&data.Embeddings[i])
+		}
+		fmt.Printf("Кэш эмбеддингов обновлен с версии %s до %s\n", m.from, m.to)
+	}
+
+	data.Version = SchemaVersion
+	return nil
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    []string // версии, с которых применяются миграции
+		wantErr bool
+	}{
+		{"без версии", "", []string{"1.0"}, false},
+		{"1.0", "1.0", []string{"1.0"}, false},
+		{"текущая", SchemaVersion, nil, false},
+		{"более новая", "2.0", nil, true},
+		{"неизвестная", "abc", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := migrationPlan(tt.version)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedVersion) {
+					t.Fatalf("ожидалась ошибка ErrUnsupportedVersion, получено %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+
+			if len(plan) != len(tt.want) {
+				t.Fatalf("получено %d миграций, ожидалось %d", len(plan), len(tt.want))
+			}
+			for i, m := range plan {
+				if m.from != tt.want[i] {
+					t.Errorf("миграция %d: from = %s, ожидалось %s", i, m.from, tt.want[i])
+				}
+			}
+			if len(plan) > 0 && plan[len(plan)-1].to != SchemaVersion {
+				t.Errorf("последняя миграция ведет к %s, ожидалось %s", plan[len(plan)-1].to, SchemaVersion)
+			}
+		})
+	}
+}
+
+func TestMigrateIfNeeded(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         CacheData
+		wantLanguage string
+		wantErr      bool
+	}{
+		{
+			name:         "1.0 сбрасывает язык",
+			data:         CacheData{Version: "1.0", Embeddings: []CachedEmbedding{{DocumentID: "a", Language: "ru"}}},
+			wantLanguage: "",
+		},
+		{
+			name:         "текущая версия не меняется",
+			data:         CacheData{Version: SchemaVersion, Embeddings: []CachedEmbedding{{DocumentID: "a", Language: "ru"}}},
+			wantLanguage: "ru",
+		},
+		{
+			name:    "более новая версия",
+			data:    CacheData{Version: "9.9", Embeddings: []CachedEmbedding{{DocumentID: "a", Language: "ru"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			err := MigrateIfNeeded(&data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ожидалась ошибка")
+				}
+				if data.Version != tt.data.Version {
+					t.Errorf("версия изменена на %s при ошибке", data.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if data.Version != SchemaVersion {
+				t.Errorf("версия = %s, ожидалось %s", data.Version, SchemaVersion)
+			}
+			if data.Embeddings[0].Language != tt.wantLanguage {
+				t.Errorf("язык = %q, ожидалось %q", data.Embeddings[0].Language, tt.wantLanguage)
+			}
+		})
+	}
+}
+
+func TestNewerCacheIsNotOverwritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "embeddings.json")
+	original, err := json.Marshal(CacheData{
+		Version:    "9.9",
+		Embeddings: []CachedEmbedding{{DocumentID: "a", ContentHash: "h", Embedding: []float32{1}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ec := NewEmbeddingCache(path)
+	if err := ec.Preload([]string{"a"}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("Preload: ожидалась ErrUnsupportedVersion, получено %v", err)
+	}
+	if err := ec.FlushCache(); !errors.Is(err, ErrCacheNotLoaded) {
+		t.Fatalf("FlushCache: ожидалась ErrCacheNotLoaded, получено %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(original) {
+		t.Errorf("файл кэша более новой версии перезаписан: %s", data)
+	}
+}
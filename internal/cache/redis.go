@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// GetCacheBackend возвращает хранилище кэша эмбеддингов: file (по умолчанию) или redis
+func GetCacheBackend() string {
+	if backend := os.Getenv("CACHE_BACKEND"); backend != "" {
+		return backend
+	}
+	return "file"
+}
+
+// GetRedisURL возвращает адрес Redis в формате redis://[:password@]host:port/db
+func GetRedisURL() string {
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		return url
+	}
+	return "redis://localhost:6379/0"
+}
+
+// GetRedisKeyPrefix возвращает префикс ключей Redis, чтобы несколько ботов могли делить один сервер
+func GetRedisKeyPrefix() string {
+	if prefix := os.Getenv("REDIS_KEY_PREFIX"); prefix != "" {
+		return prefix
+	}
+	return "rag-bot:"
+}
+
+// redisTimeout ограничивает одну операцию с Redis
+const redisTimeout = 30 * time.Second
+
+// RedisEmbeddingCache хранит эмбеддинги в хеше Redis (поле "documentID:contentHash" -> JSON),
+// чтобы несколько экземпляров бота пользовались общим кэшем. Записи добавляются по одной (Put)
+// и удаляются только явно (Delete), хеш никогда не перезаписывается целиком: так экземпляры
+// не затирают эмбеддинги друг друга. Версия схемы хранится в соседнем ключе "<префикс>embeddings:version".
+type RedisEmbeddingCache struct {
+	client     *redis.Client
+	key        string
+	versionKey string
+}
+
+var _ StorageBackend = (*RedisEmbeddingCache)(nil)
+var _ versionedBackend = (*RedisEmbeddingCache)(nil)
+var _ writeThroughBackend = (*RedisEmbeddingCache)(nil)
+var _ sizedBackend = (*RedisEmbeddingCache)(nil)
+
+// NewRedisEmbeddingCache подключается к Redis по адресу url и проверяет соединение
+func NewRedisEmbeddingCache(url, keyPrefix string) (*RedisEmbeddingCache, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный REDIS_URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisEmbeddingCache{
+		client:     client,
+		key:        keyPrefix + "embeddings",
+		versionKey: keyPrefix + "embeddings:version",
+	}, nil
+}
+
+// Close закрывает соединение с Redis
+func (r *RedisEmbeddingCache) Close() error {
+	return r.client.Close()
+}
+
+// Load читает все эмбеддинги одной командой HGETALL
+func (r *RedisEmbeddingCache) Load() ([]CachedEmbedding, error) {
+	data, err := r.LoadData()
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return data.Embeddings, nil
+}
+
+// LoadData читает версию схемы и все эмбеддинги; nil, если в Redis еще нет ни того, ни другого
+func (r *RedisEmbeddingCache) LoadData() (*CacheData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	version, err := r.client.Get(ctx, r.versionKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to load cache version from redis: %w", err)
+	}
+
+	fields, err := r.client.HGetAll(ctx, r.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cache from redis: %w", err)
+	}
+
+	if version == "" && len(fields) == 0 {
+		return nil, nil
+	}
+
+	embeddings := make([]CachedEmbedding, 0, len(fields))
+	for field, value := range fields {
+		var embedding CachedEmbedding
+		if err := json.Unmarshal([]byte(value), &embedding); err != nil {
+			return nil, fmt.Errorf("%w: запись %s: %v", ErrCacheCorrupted, field, err)
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	return &CacheData{Version: version, Embeddings: embeddings}, nil
+}
+
+// Put записывает один эмбеддинг командой HSET. Если версия схемы еще не записана, записывает SchemaVersion.
+func (r *RedisEmbeddingCache) Put(embedding CachedEmbedding) error {
+	data, err := json.Marshal(embedding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.key, embedding.DocumentID+":"+embedding.ContentHash, data)
+		pipe.SetNX(ctx, r.versionKey, SchemaVersion, 0)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save cache entry to redis: %w", err)
+	}
+
+	return nil
+}
+
+// Save добавляет или обновляет переданные эмбеддинги и записывает SchemaVersion в одной транзакции MULTI/EXEC.
+// Остальные записи хеша не удаляются: их могли записать другие экземпляры бота.
+func (r *RedisEmbeddingCache) Save(embeddings []CachedEmbedding) error {
+	values := make([]interface{}, 0, len(embeddings)*2)
+	for _, embedding := range embeddings {
+		data, err := json.Marshal(embedding)
+		if err != nil {
+			return fmt.Errorf("failed to marshal cache entry: %w", err)
+		}
+		values = append(values, embedding.DocumentID+":"+embedding.ContentHash, data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(values) > 0 {
+			pipe.HSet(ctx, r.key, values...)
+		}
+		pipe.Set(ctx, r.versionKey, SchemaVersion, 0)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save cache to redis: %w", err)
+	}
+
+	return nil
+}
+
+// Delete удаляет эмбеддинг командой HDEL
+func (r *RedisEmbeddingCache) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := r.client.HDel(ctx, r.key, key).Err(); err != nil {
+		return fmt.Errorf("failed to delete cache entry from redis: %w", err)
+	}
+
+	return nil
+}
+
+// Size возвращает память, которую занимает хеш эмбеддингов, по команде MEMORY USAGE (оценка по выборке полей).
+// Если Redis не поддерживает команду (например, у облачного провайдера), возвращает ошибку.
+func (r *RedisEmbeddingCache) Size() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	size, err := r.client.MemoryUsage(ctx, r.key).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cache memory usage from redis: %w", err)
+	}
+	return size, nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultDataDir - папка с документами по умолчанию
+const defaultDataDir = "data"
+
+// Config - основные настройки бота после объединения всех источников.
+// Приоритет: флаги командной строки > переменные окружения > YAML-файл > значения по умолчанию.
+type Config struct {
+	TelegramBotToken string
+	DataDir          string
+
+	// FileKeys - переменные окружения, значения которых взяты из YAML-файла
+	FileKeys []string
+}
+
+// GetConfigFile возвращает путь к YAML-файлу настроек из CONFIG_FILE (пусто - файл не используется)
+func GetConfigFile() string {
+	return os.Getenv("CONFIG_FILE")
+}
+
+// Load читает YAML-файл path (если он задан) и собирает конфигурацию.
+//
+// Ключи файла соответствуют переменным окружения: вложенные ключи склеиваются через "_"
+// и переводятся в верхний регистр, например
+//
+//	llm:
+//	  model: llama3  # LLM_MODEL
+//	admin_ids: [1, 2] # ADMIN_IDS=1,2
+//
+// Значение из файла записывается в окружение, только если переменная еще не задана, поэтому
+// все остальные настройки, которые читаются из окружения, тоже учитывают YAML-файл.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения файла настроек: %w", err)
+		}
+
+		var values map[string]any
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("ошибка разбора файла настроек %s: %w", path, err)
+		}
+
+		settings := make(map[string]string)
+		if err := flatten("", values, settings); err != nil {
+			return nil, fmt.Errorf("ошибка в файле настроек %s: %w", path, err)
+		}
+
+		for key, value := range settings {
+			if _, exists := os.LookupEnv(key); exists {
+				continue
+			}
+			if err := os.Setenv(key, value); err != nil {
+				return nil, fmt.Errorf("ошибка установки %s: %w", key, err)
+			}
+			cfg.FileKeys = append(cfg.FileKeys, key)
+		}
+		sort.Strings(cfg.FileKeys)
+	}
+
+	cfg.TelegramBotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+	cfg.DataDir = os.Getenv("DATA_DIR")
+	if cfg.DataDir == "" {
+		cfg.DataDir = defaultDataDir
+	}
+
+	return cfg, nil
+}
+
+// Validate проверяет обязательные настройки и возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.TelegramBotToken == "" {
+		errs = append(errs, errors.New("не задан TELEGRAM_BOT_TOKEN"))
+	}
+
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("не задана папка с документами DATA_DIR"))
+	} else if info, err := os.Stat(c.DataDir); err != nil {
+		errs = append(errs, fmt.Errorf("папка с документами недоступна: %w", err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("%s не является папкой", c.DataDir))
+	}
+
+	return errors.Join(errs...)
+}
+
+// flatten превращает вложенные ключи YAML в имена переменных окружения, списки - в значения через запятую
+func flatten(prefix string, values map[string]any, out map[string]string) error {
+	for key, value := range values {
+		name := strings.ToUpper(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(name, v, out); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, nested := item.(map[string]any); nested {
+					return fmt.Errorf("%s: списки объектов не поддерживаются", name)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			out[name] = strings.Join(items, ",")
+		case nil:
+			// пустое значение не переопределяет значение по умолчанию
+		default:
+			out[name] = fmt.Sprint(v)
+		}
+	}
+
+	return nil
+}
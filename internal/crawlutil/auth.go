@@ -0,0 +1,67 @@
+package crawlutil
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// StringListFlag - флаг, который можно указать несколько раз
+type StringListFlag []string
+
+func (f *StringListFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *StringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// ParseAuthHeaders разбирает заголовки вида "Authorization: Bearer <token>" и cookie вида "session=<value>"
+func ParseAuthHeaders(headers, cookies []string) (http.Header, error) {
+	result := make(http.Header)
+
+	for _, header := range headers {
+		key, value, found := strings.Cut(header, ":")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !found || key == "" || value == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("некорректный заголовок %q, ожидается формат \"Key: Value\"", header)
+		}
+		result.Add(key, value)
+	}
+
+	var cookiePairs []string
+	for _, cookie := range cookies {
+		name, value, found := strings.Cut(cookie, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("некорректная cookie %q, ожидается формат \"name=value\"", cookie)
+		}
+		cookiePairs = append(cookiePairs, name+"="+strings.TrimSpace(value))
+	}
+	if len(cookiePairs) > 0 {
+		result.Add("Cookie", strings.Join(cookiePairs, "; "))
+	}
+
+	return result, nil
+}
+
+// ApplyAuthHeaders добавляет заголовки аутентификации к каждому запросу коллектора
+func ApplyAuthHeaders(c *colly.Collector, headers http.Header) {
+	if len(headers) == 0 {
+		return
+	}
+
+	c.OnRequest(func(r *colly.Request) {
+		for key, values := range headers {
+			r.Headers.Del(key)
+			for _, value := range values {
+				r.Headers.Add(key, value)
+			}
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package crawlutil
+
+import (
+	"fmt"
+	"os"
+)
+
+// PrepareCollyCache готовит папку HTTP-кэша Colly; при clear папка удаляется и создается заново.
+// Кэшируются ответы на GET-запросы, поэтому повторный запуск не скачивает страницы заново.
+func PrepareCollyCache(dir string, clear bool) error {
+	if clear {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("ошибка очистки кэша Colly: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("ошибка создания папки кэша Colly: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Package crawlutil содержит общий код загрузчиков документации (cmd/downloader, cmd/downloader_ai):
+// имена файлов страниц (их же использует бот в /ingest_url), прокси, аутентификацию и HTTP-кэш Colly
+package crawlutil
+
+import (
+	"regexp"
+	"strings"
+)
+
+var invalidFilenameChars = regexp.MustCompile(`[<>:"/\\|?*]`)
+
+// Filename возвращает имя файла (без расширения .md), под которым загрузчик сохраняет страницу.
+// Имя файла становится ID документа, поэтому одна страница всегда должна получать одно имя.
+func Filename(url string) string {
+	// Убираем протокол и домен
+	filename := strings.ReplaceAll(url, "https://nethouse.ru/about/instructions/", "")
+
+	// Заменяем слеши на подчеркивания
+	filename = strings.ReplaceAll(filename, "/", "_")
+
+	// Убираем недопустимые символы для имени файла
+	filename = invalidFilenameChars.ReplaceAllString(filename, "_")
+
+	// Если имя файла пустое, используем случайное
+	if filename == "" || filename == "_" {
+		filename = "page"
+	}
+
+	return filename
+}
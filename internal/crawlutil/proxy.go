@@ -0,0 +1,48 @@
+package crawlutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// proxyEnvVars - переменные окружения с адресом прокси, проверяются по порядку, если --proxy не задан
+var proxyEnvVars = []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"}
+
+// ResolveProxy возвращает адрес прокси из --proxy или переменных окружения; nil - прокси не используется.
+// Поддерживаются схемы http, https и socks5.
+func ResolveProxy(flagValue string) (*url.URL, error) {
+	raw := flagValue
+	for _, name := range proxyEnvVars {
+		if raw != "" {
+			break
+		}
+		raw = os.Getenv(name)
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес прокси: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("неподдерживаемая схема прокси %q (допустимо http, https, socks5)", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("в адресе прокси не указан хост: %s", proxyURL.Redacted())
+	}
+
+	return proxyURL, nil
+}
+
+// ProxyTransport возвращает транспорт со стандартными настройками, отправляющий все запросы через proxyURL
+func ProxyTransport(proxyURL *url.URL) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return transport
+}
@@ -0,0 +1,52 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AtomicWrite записывает данные во временный файл в той же папке и переименовывает его в path.
+// Rename в пределах одной файловой системы атомарен, поэтому при падении процесса
+// path содержит либо прежнее, либо новое содержимое целиком, но не обрезанный файл.
+func AtomicWrite(path string, data []byte, perm os.FileMode) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	// Уникальное имя позволяет нескольким горутинам писать в одну папку одновременно
+	temp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+	tempPath := temp.Name()
+
+	// Удаляем временный файл при любой ошибке до переименования
+	fail := func(err error) error {
+		temp.Close()
+		os.Remove(tempPath)
+		return err
+	}
+
+	if _, err := temp.Write(data); err != nil {
+		return fail(fmt.Errorf("ошибка записи %s: %w", tempPath, err))
+	}
+	if err := temp.Sync(); err != nil {
+		return fail(fmt.Errorf("ошибка сброса %s на диск: %w", tempPath, err))
+	}
+	if err := temp.Chmod(perm); err != nil {
+		return fail(fmt.Errorf("ошибка установки прав %s: %w", tempPath, err))
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("ошибка закрытия %s: %w", tempPath, err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("ошибка переименования %s в %s: %w", tempPath, path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// telegramTags - теги, которые Telegram принимает в сообщениях с ParseMode HTML
+var telegramTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "a": true, "code": true, "pre": true,
+}
+
+// tagNames возвращает имена открывающих и закрывающих тегов документа
+func tagNames(text string) map[string]bool {
+	names := make(map[string]bool)
+	tokenizer := html.NewTokenizer(strings.NewReader(text))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return names
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			names[string(name)] = true
+		}
+	}
+}
+
+func FuzzTelegramSupportedHTML(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"<p>Текст <b>жирный</b> и <i>курсив</i></p>",
+		"<h1>Заголовок</h1><h4>Подзаголовок</h4>",
+		"&amp;&amp;&lt;&gt;&quot;&#39;&amp",
+		`<a href="https://nethouse.ru" onclick="alert(1)">ссылка</a>`,
+		`<pre><code class="language-go">fmt.Println("<b>")</code></pre>`,
+		"<script>alert(1)</script><style>b{}</style>",
+		strings.Repeat("<b><i>", 100) + "глубоко" + strings.Repeat("</i></b>", 100),
+		"<b><i>незакрытые теги",
+		"</b></i>лишние закрывающие",
+		"<h1 <h2>>сломанные<<теги>",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		input := string(data)
+		output := TelegramSupportedHTML(input)
+
+		inputTags := tagNames(input)
+		hasHeadings := false
+		for _, heading := range []string{"h1", "h2", "h3", "h4", "h5", "h6"} {
+			hasHeadings = hasHeadings || inputTags[heading]
+		}
+
+		for name := range tagNames(output) {
+			if !telegramTags[name] {
+				t.Errorf("тег <%s> не поддерживается Telegram: %q -> %q", name, input, output)
+			}
+			// Новые теги могут появиться только при замене заголовков на <b> и <i>
+			if !inputTags[name] && !(hasHeadings && (name == "b" || name == "i")) {
+				t.Errorf("добавлен тег <%s>, которого нет во входных данных: %q -> %q", name, input, output)
+			}
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// TelegramSupportedHTML оставляет в HTML только теги, которые поддерживает Telegram;
+// заголовки заменяются жирным и курсивом
+func TelegramSupportedHTML(htmlText string) string {
+	adjustedHTMLText := adjustHTMLTags(htmlText)
+	p := bluemonday.NewPolicy()
+	p.AllowElements("b", "strong", "i", "em", "u", "ins", "s", "strike", "del", "a", "code", "pre")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("class").OnElements("code")
+	return strings.TrimRight(p.Sanitize(adjustedHTMLText), "\n")
+}
+
+// telegram not allow h1-h6 tags
+// replace these tags with a combination of <b> and <i> for visual distinction
+func adjustHTMLTags(htmlText string) string {
+	buff := strings.Builder{}
+	tokenizer := html.NewTokenizer(strings.NewReader(htmlText))
+	for {
+		if tokenizer.Next() == html.ErrorToken {
+			return buff.String()
+		}
+		token := tokenizer.Token()
+		switch token.Type {
+		case html.StartTagToken, html.EndTagToken:
+			switch token.Data {
+			case "h1", "h2", "h3":
+				if token.Type == html.StartTagToken {
+					buff.WriteString("<b>")
+				}
+				if token.Type == html.EndTagToken {
+					buff.WriteString("</b>")
+				}
+			case "h4", "h5", "h6":
+				if token.Type == html.StartTagToken {
+					buff.WriteString("<i><b>")
+				}
+				if token.Type == html.EndTagToken {
+					buff.WriteString("</b></i>")
+				}
+			default:
+				buff.WriteString(token.String())
+			}
+		default:
+			buff.WriteString(token.String())
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// TruncateHTML обрезает HTML так, чтобы видимый текст занимал не больше maxRunes символов.
+// Теги не разрываются: обрезка идет по границам токенов (текстовый токен может быть
+// укорочен по границе символа), а незакрытые теги закрываются в конце.
+func TruncateHTML(htmlText string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+
+	var buff strings.Builder
+	var openTags []string
+	remaining := maxRunes
+
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(htmlText))
+loop:
+	for {
+		if tokenizer.Next() == xhtml.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		switch token.Type {
+		case xhtml.TextToken:
+			count := utf8.RuneCountInString(token.Data)
+			if count <= remaining {
+				buff.WriteString(html.EscapeString(token.Data))
+				remaining -= count
+			} else {
+				buff.WriteString(html.EscapeString(truncateRunes(token.Data, remaining)))
+				break loop
+			}
+		case xhtml.StartTagToken:
+			buff.WriteString(token.String())
+			if !isVoidElement(token.Data) {
+				openTags = append(openTags, token.Data)
+			}
+		case xhtml.EndTagToken:
+			for i := len(openTags) - 1; i >= 0; i-- {
+				if openTags[i] == token.Data {
+					buff.WriteString(token.String())
+					openTags = openTags[:i]
+					break
+				}
+			}
+		case xhtml.SelfClosingTagToken:
+			buff.WriteString(token.String())
+		}
+
+		if remaining == 0 {
+			break loop
+		}
+	}
+
+	// Закрываем незакрытые теги в обратном порядке
+	for i := len(openTags) - 1; i >= 0; i-- {
+		buff.WriteString("</" + openTags[i] + ">")
+	}
+
+	return buff.String()
+}
+
+func truncateRunes(text string, maxRunes int) string {
+	count := 0
+	for i := range text {
+		if count == maxRunes {
+			return text[:i]
+		}
+		count++
+	}
+	return text
+}
+
+func isVoidElement(tag string) bool {
+	switch tag {
+	case "br", "hr", "img", "input", "meta", "link", "area", "base", "col", "embed", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
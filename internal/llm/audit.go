@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Максимальная длина фрагмента ответа в журнале аудита
+const auditSnippetLength = 200
+
+// GetAuditLogPath возвращает путь к журналу аудита. Пустая строка - аудит выключен.
+func GetAuditLogPath() string {
+	return os.Getenv("LLM_AUDIT_LOG")
+}
+
+// AuditRecord - одна запись журнала аудита
+type AuditRecord struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Method        string    `json:"method"`
+	InputHash     string    `json:"input_hash"`
+	OutputSnippet string    `json:"output_snippet,omitempty"`
+	LatencyMs     int64     `json:"latency_ms"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// AuditingEngine оборачивает LLMEngine и записывает каждый вызов в JSONL-журнал.
+// Сам запрос не сохраняется - только его SHA-256 хеш. Файл ротируется ежедневно.
+type AuditingEngine struct {
+	engine LLMEngine
+	logger *lumberjack.Logger
+	day    string
+	mu     sync.Mutex
+}
+
+func NewAuditingEngine(engine LLMEngine, logPath string) *AuditingEngine {
+	return &AuditingEngine{
+		engine: engine,
+		logger: &lumberjack.Logger{
+			Filename: logPath,
+			MaxAge:   90, // дней хранения ротированных файлов
+		},
+		day: time.Now().Format(time.DateOnly),
+	}
+}
+
+// Close закрывает файл журнала
+func (a *AuditingEngine) Close() error {
+	return a.logger.Close()
+}
+
+func (a *AuditingEngine) record(method, input, output string, started time.Time, callErr error) {
+	hash := sha256.Sum256([]byte(input))
+
+	record := AuditRecord{
+		Timestamp:     started,
+		Method:        method,
+		InputHash:     hex.EncodeToString(hash[:]),
+		OutputSnippet: snippet(output),
+		LatencyMs:     time.Since(started).Milliseconds(),
+	}
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		fmt.Printf("Ошибка сериализации записи аудита: %v\n", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Ежедневная ротация: lumberjack ротирует только по размеру, поэтому переключаем файл при смене даты
+	if today := time.Now().Format(time.DateOnly); today != a.day {
+		if err := a.logger.Rotate(); err != nil {
+			fmt.Printf("Ошибка ротации журнала аудита: %v\n", err)
+		}
+		a.day = today
+	}
+
+	if _, err := a.logger.Write(append(line, '\n')); err != nil {
+		fmt.Printf("Ошибка записи в журнал аудита: %v\n", err)
+	}
+}
+
+func snippet(text string) string {
+	if utf8.RuneCountInString(text) <= auditSnippetLength {
+		return text
+	}
+	return string([]rune(text)[:auditSnippetLength]) + "..."
+}
+
+func (a *AuditingEngine) GenerateResponse(ctx context.Context, prompt string, params map[string]interface{}) (string, error) {
+	started := time.Now()
+	resp, err := a.engine.GenerateResponse(ctx, prompt, params)
+	a.record("GenerateResponse", prompt, resp, started, err)
+	return resp, err
+}
+
+func (a *AuditingEngine) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	started := time.Now()
+	embedding, err := a.engine.GenerateEmbedding(ctx, text)
+	a.record("GenerateEmbedding", text, fmt.Sprintf("[%d dims]", len(embedding)), started, err)
+	return embedding, err
+}
+
+func (a *AuditingEngine) Answer(ctx context.Context, query string, docs []Document) (string, error) {
+	links := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		links = append(links, doc.Link)
+	}
+
+	started := time.Now()
+	resp, err := a.engine.Answer(ctx, query, docs)
+	a.record("Answer", query+"\n"+strings.Join(links, "\n"), resp, started, err)
+	return resp, err
+}
+
+// BatchAnswer отправляет вопросы параллельно через Answer обертки, чтобы каждый ответ попал в журнал
+func (a *AuditingEngine) BatchAnswer(ctx context.Context, queries []string, docs [][]Document) ([]string, error) {
+	return batchAnswer(ctx, queries, docs, a.Answer)
+}
+
+func (a *AuditingEngine) AnswerWithCitations(ctx context.Context, query string, docs []Document) (string, []Citation, error) {
+	links := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		links = append(links, doc.Link)
+	}
+
+	started := time.Now()
+	resp, citations, err := a.engine.AnswerWithCitations(ctx, query, docs)
+
+	// В журнал попадает ответ вместе с цитатами
+	response := resp
+	for _, citation := range citations {
+		response += "\n" + citation.URL + ": " + citation.RelevantSentence
+	}
+	a.record("AnswerWithCitations", query+"\n"+strings.Join(links, "\n"), response, started, err)
+	return resp, citations, err
+}
+
+func (a *AuditingEngine) ExtractEssence(ctx context.Context, query string) (string, error) {
+	started := time.Now()
+	resp, err := a.engine.ExtractEssence(ctx, query)
+	a.record("ExtractEssence", query, resp, started, err)
+	return resp, err
+}
+
+func (a *AuditingEngine) ClassifyQuery(ctx context.Context, query string, categories []string) (string, error) {
+	started := time.Now()
+	resp, err := a.engine.ClassifyQuery(ctx, query, categories)
+	a.record("ClassifyQuery", query, resp, started, err)
+	return resp, err
+}
+
+func (a *AuditingEngine) SuggestFollowUps(ctx context.Context, query string, docs []Document) ([]string, error) {
+	started := time.Now()
+	questions, err := a.engine.SuggestFollowUps(ctx, query, docs)
+	a.record("SuggestFollowUps", query, strings.Join(questions, "\n"), started, err)
+	return questions, err
+}
+
+func (a *AuditingEngine) Rerank(ctx context.Context, query string, docs []Document, topK int) ([]Document, error) {
+	started := time.Now()
+	ranked, err := a.engine.Rerank(ctx, query, docs, topK)
+
+	links := make([]string, 0, len(ranked))
+	for _, doc := range ranked {
+		links = append(links, doc.Link)
+	}
+	a.record("Rerank", query, strings.Join(links, "\n"), started, err)
+	return ranked, err
+}
+
+// HealthCheck не записывается в журнал: это служебный запрос, а не вопрос пользователя
+func (a *AuditingEngine) HealthCheck(ctx context.Context) error {
+	return a.engine.HealthCheck(ctx)
+}
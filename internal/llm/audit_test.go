@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditingEngine(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	mock := NewStaticMock([]float32{1, 2, 3}, strings.Repeat("я", auditSnippetLength+10))
+	engine := NewAuditingEngine(mock, logPath)
+
+	ctx := context.Background()
+	docs := []Document{{Header: "Заголовок", Link: "https://example.com/a", Text: "Текст"}}
+
+	if _, err := engine.GenerateResponse(ctx, "промпт", nil); err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if _, err := engine.GenerateEmbedding(ctx, "текст"); err != nil {
+		t.Fatalf("GenerateEmbedding: %v", err)
+	}
+	if _, err := engine.Answer(ctx, "вопрос", docs); err != nil {
+		t.Fatalf("Answer: %v", err)
+	}
+	if _, err := engine.BatchAnswer(ctx, []string{"первый", "второй"}, [][]Document{docs, docs}); err != nil {
+		t.Fatalf("BatchAnswer: %v", err)
+	}
+	if _, _, err := engine.AnswerWithCitations(ctx, "вопрос", docs); err != nil {
+		t.Fatalf("AnswerWithCitations: %v", err)
+	}
+	if _, err := engine.ExtractEssence(ctx, "вопрос"); err != nil {
+		t.Fatalf("ExtractEssence: %v", err)
+	}
+	if _, err := engine.ClassifyQuery(ctx, "вопрос", nil); err == nil {
+		t.Fatal("ClassifyQuery без категорий должен вернуть ошибку")
+	}
+	if _, err := engine.SuggestFollowUps(ctx, "вопрос", docs); err != nil {
+		t.Fatalf("SuggestFollowUps: %v", err)
+	}
+	if _, err := engine.Rerank(ctx, "вопрос", docs, 1); err != nil {
+		t.Fatalf("Rerank: %v", err)
+	}
+	if err := engine.HealthCheck(ctx); err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+	if err := engine.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	records := readAuditLog(t, logPath)
+	methods := make([]string, 0, len(records))
+	for _, record := range records {
+		methods = append(methods, record.Method)
+	}
+	// HealthCheck в журнал не попадает
+	want := "GenerateResponse GenerateEmbedding Answer Answer Answer AnswerWithCitations ExtractEssence ClassifyQuery SuggestFollowUps Rerank"
+	if got := strings.Join(methods, " "); got != want {
+		t.Fatalf("методы в журнале:\n%s\nожидалось:\n%s", got, want)
+	}
+
+	for _, record := range records {
+		if strings.Contains(record.InputHash, "вопрос") || len(record.InputHash) != 64 {
+			t.Errorf("%s: в журнал попал запрос вместо хеша: %q", record.Method, record.InputHash)
+		}
+	}
+	if snippet := records[0].OutputSnippet; !strings.HasSuffix(snippet, "...") {
+		t.Errorf("длинный ответ не обрезан: %q", snippet)
+	}
+	if records[1].OutputSnippet != "[3 dims]" {
+		t.Errorf("фрагмент эмбеддинга = %q, ожидалось [3 dims]", records[1].OutputSnippet)
+	}
+	if records[7].Error == "" {
+		t.Error("ошибка ClassifyQuery не записана в журнал")
+	}
+}
+
+func TestAuditingEnginePassesErrors(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	engine := NewAuditingEngine(&MockLLMEngine{}, logPath)
+	t.Cleanup(func() { _ = engine.Close() })
+
+	// У мока не заданы функции: ошибка возвращается вызывающему без изменений
+	if _, err := engine.Answer(context.Background(), "вопрос", nil); err == nil {
+		t.Fatal("ожидалась ошибка Answer")
+	}
+	records := readAuditLog(t, logPath)
+	if len(records) != 1 || records[0].Error == "" {
+		t.Fatalf("ожидалась одна запись с ошибкой, получено %+v", records)
+	}
+}
+
+func readAuditLog(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("ошибка открытия журнала: %v", err)
+	}
+	defer file.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("некорректная запись журнала %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("ошибка чтения журнала: %v", err)
+	}
+	return records
+}
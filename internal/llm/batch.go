@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// GetMaxConcurrency возвращает, сколько запросов BatchAnswer одновременно отправляет в Ollama (LLM_MAX_CONCURRENCY)
+func GetMaxConcurrency() int {
+	if concurrency := getEnvInt("LLM_MAX_CONCURRENCY", 0); concurrency > 0 {
+		return concurrency
+	}
+	return 4
+}
+
+// BatchAnswer генерирует ответы на несколько вопросов параллельно, не больше LLM_MAX_CONCURRENCY одновременно.
+// docs[i] - документы для queries[i]. Ответы возвращаются в порядке вопросов; при ошибках
+// ответы на остальные вопросы все равно заполняются, а возвращается ошибка вопроса с меньшим номером.
+func (h *HTTPLLMEngine) BatchAnswer(ctx context.Context, queries []string, docs [][]Document) ([]string, error) {
+	return batchAnswer(ctx, queries, docs, h.Answer)
+}
+
+// batchAnswer распределяет вопросы между пулом воркеров, каждый вызывает answer
+func batchAnswer(ctx context.Context, queries []string, docs [][]Document,
+	answer func(ctx context.Context, query string, docs []Document) (string, error)) ([]string, error) {
+	if len(queries) != len(docs) {
+		return nil, fmt.Errorf("число вопросов (%d) не совпадает с числом наборов документов (%d)", len(queries), len(docs))
+	}
+
+	answers := make([]string, len(queries))
+	errs := make([]error, len(queries))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(GetMaxConcurrency(), len(queries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				answers[i], errs[i] = answer(ctx, queries[i], docs[i])
+			}
+		}()
+	}
+
+	for i := range queries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return answers, fmt.Errorf("ошибка ответа на вопрос %d: %w", i+1, err)
+		}
+	}
+	return answers, nil
+}
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Citation - предложение из документа, на котором основан ответ
+type Citation struct {
+	DocID            string
+	URL              string
+	RelevantSentence string
+}
+
+// citationsResponse - структурированный ответ модели для AnswerWithCitations
+type citationsResponse struct {
+	Answer    string `json:"answer"`
+	Citations []struct {
+		Document int    `json:"document"` // номер документа в промпте, с 1
+		Sentence string `json:"sentence"`
+	} `json:"citations"`
+}
+
+// AnswerWithCitations отвечает на вопрос как Answer, но вместо ссылки в тексте ответа
+// возвращает цитаты: предложения из документов, на которых основан ответ.
+// Модель отвечает в JSON (format: "json"), поэтому цитаты не теряются при генерации.
+func (h *HTTPLLMEngine) AnswerWithCitations(ctx context.Context, query string, docs []Document) (string, []Citation, error) {
+	ctx, span := tracer.Start(ctx, "llm.answer", trace.WithAttributes(
+		attribute.Int("llm.documents", len(docs)),
+		attribute.Bool("llm.citations", true),
+	))
+	answer, citations, err := h.answerWithCitations(ctx, query, docs)
+	telemetry.EndSpan(span, err)
+	return answer, citations, err
+}
+
+func (h *HTTPLLMEngine) answerWithCitations(ctx context.Context, query string, docs []Document) (string, []Citation, error) {
+	modelName := GetLLMModel()
+
+	// Проверяем доступность модели без лишнего логирования
+	if err := h.ensureModelAvailableQuiet(modelName); err != nil {
+		return "", nil, fmt.Errorf("model not available: %w", err)
+	}
+
+	prompt, err := CitationsPromptTemplate.Execute(AnswerPromptData{
+		Query:     query,
+		Documents: trimDocumentsContext(docs, GetMaxDocChars()),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := h.generate(ctx, OllamaRequest{
+		Model:   modelName,
+		Prompt:  prompt,
+		System:  answerSystemPrompt,
+		Format:  "json",
+		Options: GetLLMConfig().Options(nil),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return parseCitations(resp, docs)
+}
+
+// parseCitations разбирает JSON-ответ модели. Цитаты с несуществующим номером документа
+// или пустым предложением отбрасываются.
+func parseCitations(resp string, docs []Document) (string, []Citation, error) {
+	start := strings.Index(resp, "{")
+	end := strings.LastIndex(resp, "}")
+	if start == -1 || end <= start {
+		return "", nil, fmt.Errorf("в ответе модели нет JSON-объекта: %q", resp)
+	}
+
+	var parsed citationsResponse
+	if err := json.Unmarshal([]byte(resp[start:end+1]), &parsed); err != nil {
+		return "", nil, fmt.Errorf("ошибка разбора ответа с цитатами: %w", err)
+	}
+
+	answer := strings.TrimSpace(parsed.Answer)
+	if answer == "" {
+		return "", nil, fmt.Errorf("в ответе модели нет текста ответа: %q", resp)
+	}
+
+	var citations []Citation
+	for _, item := range parsed.Citations {
+		i := item.Document - 1
+		sentence := strings.TrimSpace(item.Sentence)
+		if i < 0 || i >= len(docs) || sentence == "" {
+			continue
+		}
+
+		citations = append(citations, Citation{
+			DocID:            docs[i].ID,
+			URL:              docs[i].Link,
+			RelevantSentence: sentence,
+		})
+	}
+
+	return answer, citations, nil
+}
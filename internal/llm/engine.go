@@ -0,0 +1,22 @@
+package llm
+
+import "context"
+
+// LLMEngine - операции с языковой моделью, используемые ботом и поиском
+type LLMEngine interface {
+	// Во всех методах контекст несет родительский span трассировки и отменяет запрос к модели
+	GenerateResponse(ctx context.Context, prompt string, params map[string]interface{}) (string, error)
+	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
+	Answer(ctx context.Context, query string, docs []Document) (string, error)
+	// BatchAnswer отвечает на несколько вопросов (docs[i] - документы для queries[i]), ответы в порядке вопросов
+	BatchAnswer(ctx context.Context, queries []string, docs [][]Document) ([]string, error)
+	AnswerWithCitations(ctx context.Context, query string, docs []Document) (string, []Citation, error)
+	ExtractEssence(ctx context.Context, query string) (string, error)
+	ClassifyQuery(ctx context.Context, query string, categories []string) (string, error)
+	SuggestFollowUps(ctx context.Context, query string, docs []Document) ([]string, error)
+	Rerank(ctx context.Context, query string, docs []Document, topK int) ([]Document, error)
+	// HealthCheck проверяет, что модель генерации отвечает на запросы
+	HealthCheck(ctx context.Context) error
+}
+
+var _ LLMEngine = (*HTTPLLMEngine)(nil)
@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// healthCheckTimeout - сколько HealthCheck ждет ответа модели
+const healthCheckTimeout = 10 * time.Second
+
+const healthCheckPrompt = "Ответь одним словом: ok"
+
+// HealthCheck проверяет, что модель генерации действительно отвечает: отправляет минимальный
+// промпт и ждет непустой ответ не дольше healthCheckTimeout. checkModelAvailability проверяет
+// только наличие модели в списке Ollama, но не то, что она загружается и генерирует текст.
+func (h *HTTPLLMEngine) HealthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	resp, err := h.generateWithModel(ctx, GetLLMModel(), healthCheckPrompt, map[string]interface{}{
+		"num_predict": 10,
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return fmt.Errorf("модель %s не ответила: %w", GetLLMModel(), err)
+	}
+
+	if strings.TrimSpace(resp) == "" {
+		return errors.New("модель " + GetLLMModel() + " вернула пустой ответ")
+	}
+
+	return nil
+}
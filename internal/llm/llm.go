@@ -2,19 +2,35 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/ad/rag-bot/internal/telemetry"
 	_ "github.com/joho/godotenv/autoload"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/singleflight"
 )
 
+// tracer создает spans вызовов модели (см. telemetry.Setup)
+var tracer = otel.Tracer("github.com/ad/rag-bot/internal/llm")
+
 func GetLLMModel() string {
 	model := os.Getenv("LLM_MODEL")
 	if model == "" {
@@ -23,6 +39,15 @@ func GetLLMModel() string {
 	return model
 }
 
+// GetLLMEssenceModel возвращает модель для выделения сути вопроса (LLM_ESSENCE_MODEL, по умолчанию LLM_MODEL).
+// Это простая задача, с которой быстро справляется небольшая модель.
+func GetLLMEssenceModel() string {
+	if model := os.Getenv("LLM_ESSENCE_MODEL"); model != "" {
+		return model
+	}
+	return GetLLMModel()
+}
+
 func GetLLMEmbeddingsModel() string {
 	embedModel := os.Getenv("LLM_EMBEDDINGS_MODEL")
 	if embedModel == "" {
@@ -39,59 +64,202 @@ func GetApiURL() string {
 	return apiURL
 }
 
+// LLMConfig содержит параметры генерации, общие для всех запросов к LLM
+type LLMConfig struct {
+	MaxTokens     int
+	Temperature   float64
+	TopK          int
+	TopP          float64
+	RepeatPenalty float64
+}
+
+// GetLLMConfig читает параметры генерации из переменных окружения
+func GetLLMConfig() LLMConfig {
+	maxTokens := getEnvInt("LLM_MAX_TOKENS", 0)
+	if maxTokens <= 0 {
+		maxTokens = getEnvInt("MAX_RESPONSE_TOKENS", 800)
+	}
+
+	return LLMConfig{
+		MaxTokens:     maxTokens,
+		Temperature:   getEnvFloat("LLM_TEMPERATURE", 0.3),
+		TopK:          getEnvInt("LLM_TOP_K", 40),
+		TopP:          getEnvFloat("LLM_TOP_P", 0.95),
+		RepeatPenalty: getEnvFloat("LLM_REPEAT_PENALTY", 1.1),
+	}
+}
+
+// Options формирует параметры запроса Ollama: значения из конфигурации,
+// поверх них переопределения конкретного вызова. num_predict никогда не превышает MaxTokens.
+func (c LLMConfig) Options(overrides map[string]interface{}) map[string]interface{} {
+	options := map[string]interface{}{
+		"temperature":    c.Temperature,
+		"num_predict":    c.MaxTokens,
+		"top_k":          c.TopK,
+		"top_p":          c.TopP,
+		"repeat_penalty": c.RepeatPenalty,
+	}
+
+	for key, value := range overrides {
+		options[key] = value
+	}
+
+	if numPredict, ok := options["num_predict"].(int); !ok || numPredict <= 0 || numPredict > c.MaxTokens {
+		options["num_predict"] = c.MaxTokens
+	}
+
+	return options
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+const defaultUserAgent = "rag-bot/1.0"
+
 type HTTPLLMEngine struct {
-	apiURL     string
-	client     *http.Client
-	sf         singleflight.Group
-	modelCache map[string]bool // кэш для проверки доступности моделей
-	cacheMutex sync.RWMutex    // мьютекс для безопасного доступа к кэшу
-}
-
-func NewHTTPLLM(apiURL string) *HTTPLLMEngine {
-	return &HTTPLLMEngine{
-		apiURL: apiURL,
-		client: &http.Client{
-			Timeout: 600 * time.Second,
-		},
+	apiURL      string
+	client      *http.Client
+	embedClient *http.Client // общий клиент для эмбеддингов с коротким таймаутом и тем же пулом соединений
+	transport   *http.Transport
+	userAgent   string
+	retries     int           // повторы запроса при ответе 5xx или сетевой ошибке, кроме таймаута
+	retryDelay  time.Duration // пауза перед первым повтором, удваивается с каждым следующим
+	sf          singleflight.Group
+	modelCache  map[string]bool // кэш для проверки доступности моделей
+	cacheMutex  sync.RWMutex    // мьютекс для безопасного доступа к кэшу
+}
+
+// Option настраивает HTTPLLMEngine при создании
+type Option func(*HTTPLLMEngine)
+
+// WithUserAgent задает заголовок User-Agent для всех запросов к Ollama (например, для прокси с проверкой клиента)
+func WithUserAgent(ua string) Option {
+	return func(h *HTTPLLMEngine) {
+		h.userAgent = ua
+	}
+}
+
+// WithHTTPTransport задает транспорт с пулом соединений к Ollama (MaxIdleConnsPerHost, MaxConnsPerHost, IdleConnTimeout)
+func WithHTTPTransport(t *http.Transport) Option {
+	return func(h *HTTPLLMEngine) {
+		h.transport = t
+	}
+}
+
+// Повторы запросов к Ollama по умолчанию: модель может временно отвечать 503 во время загрузки
+const (
+	defaultRetries    = 2
+	defaultRetryDelay = 500 * time.Millisecond
+)
+
+// WithRetry задает число повторов запроса при ответе 5xx или сетевой ошибке (кроме таймаута) и паузу перед первым повтором
+func WithRetry(retries int, delay time.Duration) Option {
+	return func(h *HTTPLLMEngine) {
+		h.retries = max(retries, 0)
+		h.retryDelay = delay
+	}
+}
+
+// newDefaultTransport создает транспорт с явно заданными лимитами пула соединений:
+// у http.DefaultTransport MaxIdleConnsPerHost равен 2, чего мало для параллельных запросов эмбеддингов
+func newDefaultTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = 32
+	t.MaxConnsPerHost = 64
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
+
+func NewHTTPLLM(apiURL string, opts ...Option) *HTTPLLMEngine {
+	h := &HTTPLLMEngine{
+		apiURL:     apiURL,
+		userAgent:  defaultUserAgent,
+		retries:    defaultRetries,
+		retryDelay: defaultRetryDelay,
 		modelCache: make(map[string]bool),
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if h.transport == nil {
+		h.transport = newDefaultTransport()
+	}
+
+	transport := &userAgentTransport{
+		base:      h.transport,
+		userAgent: h.userAgent,
+	}
+
+	h.client = &http.Client{
+		Timeout:   600 * time.Second,
+		Transport: transport,
+	}
+	h.embedClient = &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: transport,
+	}
+
+	return h
+}
+
+// userAgentTransport добавляет заголовок User-Agent к каждому запросу
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	// Передаем контекст трассировки в Ollama (заголовок traceparent)
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.base.RoundTrip(req)
 }
 
 // ...existing structs...
 
-func (h *HTTPLLMEngine) GenerateResponse(prompt string, params map[string]interface{}) (string, error) {
-	modelName := GetLLMModel()
+func (h *HTTPLLMEngine) GenerateResponse(ctx context.Context, prompt string, params map[string]interface{}) (string, error) {
+	return h.generateWithModel(ctx, GetLLMModel(), prompt, params)
+}
 
+// generateWithModel генерирует ответ указанной моделью
+func (h *HTTPLLMEngine) generateWithModel(ctx context.Context, modelName, prompt string, params map[string]interface{}) (string, error) {
 	// Проверяем доступность модели без лишнего логирования
 	if err := h.ensureModelAvailableQuiet(modelName); err != nil {
 		return "", fmt.Errorf("model not available: %w", err)
 	}
 
-	if params == nil {
-		params = map[string]interface{}{
-			"temperature":    0.7,
-			"num_predict":    1024,
-			"top_k":          40,
-			"top_p":          0.95,
-			"repeat_penalty": 1.1,
-		}
-	}
-
 	// Подготовка запроса для Ollama
-	reqBody := OllamaRequest{
+	return h.generate(ctx, OllamaRequest{
 		Model:   modelName,
 		Prompt:  prompt,
 		Stream:  false,
-		Options: params,
-	}
+		Options: GetLLMConfig().Options(params),
+	})
+}
 
+// generate отправляет запрос в /api/generate и возвращает текст ответа модели
+func (h *HTTPLLMEngine) generate(ctx context.Context, reqBody OllamaRequest) (string, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", fmt.Errorf("ошибка сериализации запроса: %w", err)
 	}
 
 	// Отправка запроса к Ollama API
-	resp, err := h.client.Post(h.apiURL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := h.post(ctx, h.client, "/api/generate", jsonData)
 	if err != nil {
 		return "", fmt.Errorf("ошибка HTTP запроса: %w", err)
 	}
@@ -118,6 +286,51 @@ func (h *HTTPLLMEngine) GenerateResponse(prompt string, params map[string]interf
 	return respBody.Response, nil
 }
 
+// post отправляет JSON-запрос к Ollama API; контекст несет родительский span трассировки
+func (h *HTTPLLMEngine) post(ctx context.Context, client *http.Client, path string, body []byte) (*http.Response, error) {
+	delay := h.retryDelay
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.apiURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if !isRetryable(ctx, resp, err) || attempt >= h.retries {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Printf("Ошибка запроса %s к Ollama, повтор через %v: %v", path, delay, err)
+		} else {
+			log.Printf("Ollama ответила %d на %s, повтор через %v", resp.StatusCode, path, delay)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isRetryable сообщает, стоит ли повторить запрос: при ответе 5xx или сетевой ошибке.
+// Таймаут не повторяется: запрос генерации уже ждал до 10 минут, и повторы растянули бы ожидание в разы.
+func isRetryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err == nil {
+		return resp.StatusCode >= http.StatusInternalServerError
+	}
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return !errors.As(err, &netErr) || !netErr.Timeout()
+}
+
 // Проверка модели из кэша
 func (h *HTTPLLMEngine) isModelCached(modelName string) bool {
 	h.cacheMutex.RLock()
@@ -161,7 +374,7 @@ func (h *HTTPLLMEngine) checkModelAvailability(modelName string) error {
 		}
 	}
 
-	return fmt.Errorf("model %s not found in available models", modelName)
+	return fmt.Errorf("model %s: %w", modelName, errModelNotFound)
 }
 
 type OllamaPullRequest struct {
@@ -192,9 +405,11 @@ type OllamaRequest struct {
 	Options  map[string]interface{} `json:"options,omitempty"`
 	System   string                 `json:"system,omitempty"`   // Для системных инструкций
 	Template string                 `json:"template,omitempty"` // Для поддержки шаблонов
+	Format   string                 `json:"format,omitempty"`   // "json" - структурированный ответ (если модель поддерживает)
 }
 type OllamaResponse struct {
 	Response string `json:"response"`
+	Done     bool   `json:"done"` // последний фрагмент потокового ответа
 	Usage    struct {
 		PromptTokens     int `json:"prompt_tokens"`
 		CompletionTokens int `json:"completion_tokens"`
@@ -291,32 +506,144 @@ func (h *HTTPLLMEngine) ensureModelAvailableQuiet(modelName string) error {
 
 // Document represents a document with header, link, and keywords
 type Document struct {
-	Header string
-	Link   string
-	Text   string
+	ID           string // ID документа в хранилище, нужен для цитат
+	Header       string
+	Link         string
+	Text         string
+	CodeSnippets []string // команды и фрагменты кода, передаются в контекст без изменений
+
+	ReadingTimeSeconds int // время чтения статьи, 0 - неизвестно
+
+	Embedding []float32 // эмбеддинг документа, в промпт не попадает; нужен DeduplicateDocs
 }
 
-func (h *HTTPLLMEngine) Answer(query string, docs []Document) (string, error) {
-	modelName := GetLLMModel()
+// ReadingTimeMinutes возвращает время чтения статьи в минутах с округлением вверх
+func (d Document) ReadingTimeMinutes() int {
+	return (d.ReadingTimeSeconds + 59) / 60
+}
 
-	// Проверяем доступность модели без лишнего логирования
-	if err := h.ensureModelAvailableQuiet(modelName); err != nil {
-		return "", fmt.Errorf("model not available: %w", err)
+// GetMaxDocChars возвращает лимит символов текста одного документа в контексте LLM
+func GetMaxDocChars() int {
+	if maxChars := getEnvInt("LLM_MAX_DOC_CHARS", 0); maxChars > 0 {
+		return maxChars
+	}
+	return 1500
+}
+
+// TrimDocumentContext обрезает текст документа до maxChars символов, добавляя "..." при обрезке
+func TrimDocumentContext(doc Document, maxChars int) Document {
+	runes := []rune(doc.Text)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return doc
+	}
+
+	doc.Text = strings.TrimSpace(string(runes[:maxChars])) + "..."
+	return doc
+}
+
+// trimDocumentsContext распределяет общий бюджет (maxChars на документ) между документами:
+// документы короче равной доли оставшегося бюджета передаются целиком, а то, что они не израсходовали,
+// поровну делится между длинными документами. Обрезаются только длинные документы.
+func trimDocumentsContext(docs []Document, maxChars int) []Document {
+	lengths := make([]int, len(docs))
+	total := 0
+	for i, doc := range docs {
+		lengths[i] = utf8.RuneCountInString(doc.Text)
+		total += lengths[i]
+	}
+
+	budget := maxChars * len(docs)
+	if total <= budget {
+		return docs
+	}
+
+	// Документы перебираются от коротких к длинным; первый, который не помещается в равную долю,
+	// и все следующие за ним получают эту долю
+	order := make([]int, len(docs))
+	for i := range order {
+		order[i] = i
 	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return lengths[order[a]] < lengths[order[b]]
+	})
+
+	limits := make([]int, len(docs))
+	remaining := len(docs)
+	for k, i := range order {
+		share := budget / remaining
+		if lengths[i] > share {
+			for _, j := range order[k:] {
+				limits[j] = share
+			}
+			break
+		}
+		limits[i] = lengths[i]
+		budget -= lengths[i]
+		remaining--
+	}
+
+	trimmed := make([]Document, len(docs))
+	for i, doc := range docs {
+		trimmed[i] = TrimDocumentContext(doc, limits[i])
+	}
+
+	return trimmed
+}
+
+// duplicateSimilarity - косинусное сходство, начиная с которого документы считаются копиями
+const duplicateSimilarity = 0.99
+
+// DeduplicateDocs убирает повторы документов перед передачей в LLM, сохраняя первое вхождение:
+// сначала документы с одинаковой ссылкой, затем документы с почти одинаковым эмбеддингом (сходство > 0.99).
+// Повторы появляются, когда документ найден и векторным поиском, и по ключевым словам.
+func DeduplicateDocs(docs []Document) []Document {
+	seenLinks := make(map[string]bool, len(docs))
+	result := make([]Document, 0, len(docs))
 
-	// Формирование контекста из документов
-	context := ""
 	for _, doc := range docs {
-		context += fmt.Sprintf("ЗАГОЛОВОК: %s\nССЫЛКА: %s\nТЕКСТ: %s\n\n----------\n\n",
-			doc.Header, doc.Link, doc.Text)
+		if doc.Link != "" {
+			if seenLinks[doc.Link] {
+				continue
+			}
+			seenLinks[doc.Link] = true
+		}
+
+		duplicate := false
+		for _, kept := range result {
+			if cosineSimilarity(doc.Embedding, kept.Embedding) > duplicateSimilarity {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			result = append(result, doc)
+		}
 	}
 
-	// Подготовка запроса для Ollama
-	reqBody := OllamaRequest{
-		Model:  modelName,
-		Stream: false,
-		Prompt: fmt.Sprintf("ДОКУМЕНТЫ:\n%s\n\nВОПРОС ПОЛЬЗОВАТЕЛЯ: %s\n\nОТВЕТ:", context, query),
-		System: `Ты - специалист технической поддержки компании Nethouse(Нетхаус). Анализируй предоставленные документы и отвечай на вопросы пользователей.
+	return result
+}
+
+// cosineSimilarity возвращает косинусное сходство векторов; 0 для пустых векторов и разной размерности
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// answerSystemPrompt - системные инструкции для ответов на вопросы пользователей
+const answerSystemPrompt = `Ты - специалист технической поддержки компании Nethouse(Нетхаус). Анализируй предоставленные документы и отвечай на вопросы пользователей.
 
 ОБЯЗАТЕЛЬНЫЕ ПРАВИЛА:
 1. ВЫБЕРИ только ОДИН наиболее подходящий ДОКУМЕНТ из списка (ДОКУМЕНТ N)
@@ -333,14 +660,77 @@ func (h *HTTPLLMEngine) Answer(query string, docs []Document) (string, error) {
 - Прямой ответ на вопрос
 - Конкретные шаги или инструкции
 
-НЕ ОТКАЗЫВАЙСЯ отвечать если есть хоть какая-то релевантная информация в документах.`,
-		Options: map[string]interface{}{
-			"temperature":    0.3,
-			"num_predict":    512,
-			"top_k":          20,
-			"top_p":          0.8,
-			"repeat_penalty": 1.3,
-		},
+НЕ ОТКАЗЫВАЙСЯ отвечать если есть хоть какая-то релевантная информация в документах.`
+
+func (h *HTTPLLMEngine) Answer(ctx context.Context, query string, docs []Document) (string, error) {
+	ctx, span := tracer.Start(ctx, "llm.answer", trace.WithAttributes(attribute.Int("llm.documents", len(docs))))
+	response, err := h.answer(ctx, query, docs)
+	telemetry.EndSpan(span, err)
+	return response, err
+}
+
+// answerRequest готовит запрос к Ollama для ответа на вопрос по документам.
+// Модели из GetJSONModeModels отвечают в формате JSON (кроме потокового режима, где нужен текст).
+func (h *HTTPLLMEngine) answerRequest(query string, docs []Document, stream bool) (OllamaRequest, error) {
+	modelName := GetLLMModel()
+
+	// Проверяем доступность модели без лишнего логирования
+	if err := h.ensureModelAvailableQuiet(modelName); err != nil {
+		return OllamaRequest{}, fmt.Errorf("model not available: %w", err)
+	}
+
+	promptTemplate, format := AnswerPromptTemplate, ""
+	if !stream && supportsJSONMode(modelName) {
+		promptTemplate, format = AnswerJSONPromptTemplate, "json"
+	}
+
+	prompt, err := promptTemplate.Execute(AnswerPromptData{
+		Query:     query,
+		Documents: trimDocumentsContext(docs, GetMaxDocChars()),
+	})
+	if err != nil {
+		return OllamaRequest{}, err
+	}
+
+	return OllamaRequest{
+		Model:   modelName,
+		Stream:  stream,
+		Prompt:  prompt,
+		System:  answerSystemPrompt,
+		Format:  format,
+		Options: GetLLMConfig().Options(nil),
+	}, nil
+}
+
+// answerLabels - служебные метки формата промпта, которые модель иногда повторяет в ответе
+var answerLabels = []string{"[ЗАГОЛОВОК]: ", "ЗАГОЛОВОК: ", "[ССЫЛКА]: ", "ССЫЛКА: ", "[Источник]: ", "**Источник:** ", "[СОДЕРЖАНИЕ]:", "СОДЕРЖАНИЕ:", "Прямой ответ на вопрос: "}
+
+// emptyAnswer заменяет пустой ответ модели
+const emptyAnswer = "Пожалуйста, уточните вопрос или напишите на support@nethouse.ru"
+
+// removeAnswerLabels убирает из текста служебные метки answerLabels
+func removeAnswerLabels(response string) string {
+	for _, label := range answerLabels {
+		response = strings.ReplaceAll(response, label, "")
+	}
+	return response
+}
+
+// cleanAnswer убирает из ответа служебные метки формата промпта
+func cleanAnswer(response string) string {
+	response = removeAnswerLabels(response)
+
+	if response == "" {
+		return emptyAnswer
+	}
+
+	return response
+}
+
+func (h *HTTPLLMEngine) answer(ctx context.Context, query string, docs []Document) (string, error) {
+	reqBody, err := h.answerRequest(query, docs, false)
+	if err != nil {
+		return "", err
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -349,7 +739,7 @@ func (h *HTTPLLMEngine) Answer(query string, docs []Document) (string, error) {
 	}
 
 	// Отправка запроса к Ollama API
-	resp, err := h.client.Post(h.apiURL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := h.post(ctx, h.client, "/api/generate", jsonData)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %w", err)
 	}
@@ -373,21 +763,27 @@ func (h *HTTPLLMEngine) Answer(query string, docs []Document) (string, error) {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	// Post-processing: проверяем наличие источников и добавляем их при необходимости
-	response := respBody.Response
-	removeWords := []string{"[ЗАГОЛОВОК]: ", "ЗАГОЛОВОК: ", "[ССЫЛКА]: ", "ССЫЛКА: ", "[Источник]: ", "**Источник:** ", "[СОДЕРЖАНИЕ]:", "СОДЕРЖАНИЕ:", "Прямой ответ на вопрос: "}
-	for _, word := range removeWords {
-		response = strings.ReplaceAll(response, word, "")
+	if reqBody.Format == "json" {
+		structured, err := parseStructuredAnswer(respBody.Response, docs)
+		if err == nil {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Float64("llm.confidence", structured.Confidence))
+			return structured.Text(), nil
+		}
+		// Модель не справилась с форматом - используем ответ как текст
+		log.Printf("Ошибка структурированного ответа, используется текст: %v", err)
 	}
 
-	if response == "" {
-		return "Пожалуйста, уточните вопрос или напишите на support@nethouse.ru", nil
-	}
+	return cleanAnswer(respBody.Response), nil
+}
 
-	return response, nil
+func (h *HTTPLLMEngine) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	ctx, span := tracer.Start(ctx, "llm.generate_embedding", trace.WithAttributes(attribute.Int("llm.text_length", len(text))))
+	embedding, err := h.generateEmbedding(ctx, text)
+	telemetry.EndSpan(span, err)
+	return embedding, err
 }
 
-func (h *HTTPLLMEngine) GenerateEmbedding(text string) ([]float32, error) {
+func (h *HTTPLLMEngine) generateEmbedding(ctx context.Context, text string) ([]float32, error) {
 	// Проверяем входной текст
 	if strings.TrimSpace(text) == "" {
 		return nil, fmt.Errorf("входной текст пустой")
@@ -408,8 +804,7 @@ func (h *HTTPLLMEngine) GenerateEmbedding(text string) ([]float32, error) {
 		return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
 	}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Post(GetApiURL()+"/api/embed", "application/json", bytes.NewBuffer(reqBody))
+	resp, err := h.post(ctx, h.embedClient, "/api/embed", reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка HTTP запроса: %w", err)
 	}
@@ -457,17 +852,25 @@ type EmbeddingResponse struct {
 }
 
 // ExtractEssence выделяет суть запроса, используя Ollama через HTTP API.
-func (h *HTTPLLMEngine) ExtractEssence(query string) (string, error) {
-	// Пример промпта для ollama
-	prompt := "Выдели кратко суть следующего вопроса пользователя, сохранив только ключевые слова и смысл:\n\n" + query
+func (h *HTTPLLMEngine) ExtractEssence(ctx context.Context, query string) (string, error) {
+	ctx, span := tracer.Start(ctx, "llm.extract_essence", trace.WithAttributes(attribute.String("llm.model", GetLLMEssenceModel())))
+	essence, err := h.extractEssence(ctx, query)
+	telemetry.EndSpan(span, err)
+	return essence, err
+}
+
+func (h *HTTPLLMEngine) extractEssence(ctx context.Context, query string) (string, error) {
+	prompt, err := EssencePromptTemplate.Execute(EssencePromptData{Query: query})
+	if err != nil {
+		return "", err
+	}
 
 	params := map[string]interface{}{
 		"temperature": 0.1,
-		"max_tokens":  50,
+		"num_predict": 50,
 	}
 
-	// Пример вызова ollama (замените на ваш реальный вызов)
-	resp, err := h.GenerateResponse(prompt, params)
+	resp, err := h.generateWithModel(ctx, GetLLMEssenceModel(), prompt, params)
 	if err != nil {
 		return "", err
 	}
@@ -477,3 +880,183 @@ func (h *HTTPLLMEngine) ExtractEssence(query string) (string, error) {
 	}
 	return essence, nil
 }
+
+// ClassifyQuery относит запрос пользователя к одной из переданных категорий (zero-shot классификация).
+func (h *HTTPLLMEngine) ClassifyQuery(ctx context.Context, query string, categories []string) (string, error) {
+	if len(categories) == 0 {
+		return "", fmt.Errorf("список категорий пуст")
+	}
+
+	prompt := fmt.Sprintf(`Определи категорию вопроса пользователя.
+Доступные категории: %s
+
+Ответь ТОЛЬКО одним названием категории из списка, без пояснений.
+
+ВОПРОС: %s
+
+КАТЕГОРИЯ:`, strings.Join(categories, ", "), query)
+
+	params := map[string]interface{}{
+		"temperature": 0.0,
+		"num_predict": 10,
+	}
+
+	resp, err := h.GenerateResponse(ctx, prompt, params)
+	if err != nil {
+		return "", err
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(resp))
+	answer = strings.Trim(answer, " .\"'`")
+
+	// Сначала ищем точное совпадение, затем вхождение названия категории в ответ
+	for _, category := range categories {
+		if answer == strings.ToLower(category) {
+			return category, nil
+		}
+	}
+	for _, category := range categories {
+		if strings.Contains(answer, strings.ToLower(category)) {
+			return category, nil
+		}
+	}
+
+	return "", fmt.Errorf("не удалось определить категорию по ответу модели: %q", resp)
+}
+
+// maxFollowUps - сколько дополнительных вопросов предлагать пользователю
+const maxFollowUps = 3
+
+// SuggestFollowUps предлагает до трех связанных вопросов, которые пользователь может задать после ответа
+func (h *HTTPLLMEngine) SuggestFollowUps(ctx context.Context, query string, docs []Document) ([]string, error) {
+	docsContext := ""
+	for _, doc := range trimDocumentsContext(docs, GetMaxDocChars()/2) {
+		docsContext += fmt.Sprintf("ЗАГОЛОВОК: %s\nТЕКСТ: %s\n\n", doc.Header, doc.Text)
+	}
+
+	prompt := fmt.Sprintf(`ДОКУМЕНТЫ:
+%s
+ВОПРОС ПОЛЬЗОВАТЕЛЯ: %s
+
+На основе этих документов придумай 3 связанных вопроса, которые пользователь может задать следующими.
+Вопросы должны быть короткими (до 60 символов) и на русском языке.
+Ответь ТОЛЬКО JSON-массивом строк, без пояснений.`, docsContext, query)
+
+	params := map[string]interface{}{
+		"temperature": 0.5,
+		"num_predict": 150,
+	}
+
+	resp, err := h.GenerateResponse(ctx, prompt, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFollowUps(resp)
+}
+
+// parseFollowUps извлекает JSON-массив строк из ответа модели
+func parseFollowUps(resp string) ([]string, error) {
+	start := strings.Index(resp, "[")
+	end := strings.LastIndex(resp, "]")
+	if start == -1 || end <= start {
+		return nil, fmt.Errorf("в ответе модели нет JSON-массива: %q", resp)
+	}
+
+	var questions []string
+	if err := json.Unmarshal([]byte(resp[start:end+1]), &questions); err != nil {
+		return nil, fmt.Errorf("ошибка разбора вопросов: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, question := range questions {
+		question = strings.TrimSpace(question)
+		if question == "" || seen[question] {
+			continue
+		}
+		seen[question] = true
+		result = append(result, question)
+
+		if len(result) == maxFollowUps {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// Rerank упорядочивает документы по релевантности запросу одним запросом к LLM и возвращает topK лучших.
+// Документы, которые модель не упомянула, сохраняют исходный порядок в конце списка.
+func (h *HTTPLLMEngine) Rerank(ctx context.Context, query string, docs []Document, topK int) ([]Document, error) {
+	if topK <= 0 || topK > len(docs) {
+		topK = len(docs)
+	}
+	if len(docs) <= 1 {
+		return docs[:topK], nil
+	}
+
+	var list strings.Builder
+	for i, doc := range trimDocumentsContext(docs, GetMaxDocChars()/2) {
+		fmt.Fprintf(&list, "ДОКУМЕНТ %d\nЗАГОЛОВОК: %s\nТЕКСТ: %s\n\n", i+1, doc.Header, doc.Text)
+	}
+
+	prompt := fmt.Sprintf(`%s
+ВОПРОС ПОЛЬЗОВАТЕЛЯ: %s
+
+Упорядочи документы по убыванию релевантности вопросу.
+Ответь ТОЛЬКО JSON-массивом номеров документов, например [2, 1, 3], без пояснений.`, list.String(), query)
+
+	params := map[string]interface{}{
+		"temperature": 0.0,
+		"num_predict": 100,
+	}
+
+	resp, err := h.GenerateResponse(ctx, prompt, params)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := parseRanking(resp, len(docs))
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]Document, 0, len(docs))
+	for _, i := range order {
+		ranked = append(ranked, docs[i])
+	}
+
+	return ranked[:topK], nil
+}
+
+// parseRanking разбирает JSON-массив номеров документов (с 1) и возвращает индексы всех n документов:
+// сначала в порядке модели, затем неупомянутые в исходном порядке
+func parseRanking(resp string, n int) ([]int, error) {
+	start := strings.Index(resp, "[")
+	end := strings.LastIndex(resp, "]")
+	if start == -1 || end <= start {
+		return nil, fmt.Errorf("в ответе модели нет JSON-массива: %q", resp)
+	}
+
+	var numbers []int
+	if err := json.Unmarshal([]byte(resp[start:end+1]), &numbers); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ранжирования: %w", err)
+	}
+
+	used := make([]bool, n)
+	order := make([]int, 0, n)
+	for _, number := range numbers {
+		if i := number - 1; i >= 0 && i < n && !used[i] {
+			used[i] = true
+			order = append(order, i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !used[i] {
+			order = append(order, i)
+		}
+	}
+
+	return order, nil
+}
@@ -0,0 +1,919 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockOllama - тестовый сервер, имитирующий Ollama API
+type mockOllama struct {
+	models     []string
+	generate   func(req OllamaRequest) (int, string)
+	embed      func(req EmbeddingRequest) (int, string)
+	tagsCalls  atomic.Int32
+	pullCalls  atomic.Int32
+	lastPrompt atomic.Value
+}
+
+func newMockOllama(t *testing.T, m *mockOllama) *httptest.Server {
+	t.Helper()
+
+	t.Setenv("LLM_MODEL", "test-model")
+	t.Setenv("LLM_EMBEDDINGS_MODEL", "test-embed")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		m.tagsCalls.Add(1)
+		var resp OllamaModelsResponse
+		for _, name := range m.models {
+			resp.Models = append(resp.Models, OllamaModel{Name: name})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/api/pull", func(w http.ResponseWriter, r *http.Request) {
+		m.pullCalls.Add(1)
+		var req OllamaPullRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		m.models = append(m.models, req.Name)
+		_ = json.NewEncoder(w).Encode(OllamaPullResponse{Status: "success"})
+	})
+	mux.HandleFunc("/api/generate", func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("некорректный запрос генерации: %v", err)
+		}
+		m.lastPrompt.Store(req.Prompt)
+		status, body := m.generate(req)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+	mux.HandleFunc("/api/embed", func(w http.ResponseWriter, r *http.Request) {
+		var req EmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("некорректный запрос эмбеддинга: %v", err)
+		}
+		status, body := m.embed(req)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	t.Setenv("LLM_API_URL", srv.URL)
+
+	return srv
+}
+
+func generateResponse(text string) (int, string) {
+	body, _ := json.Marshal(OllamaResponse{Response: text})
+	return http.StatusOK, string(body)
+}
+
+func TestGenerateResponseSuccess(t *testing.T) {
+	t.Setenv("LLM_MAX_TOKENS", "100")
+
+	var options map[string]interface{}
+	m := &mockOllama{
+		models: []string{"test-model:latest"},
+		generate: func(req OllamaRequest) (int, string) {
+			if req.Model != "test-model" {
+				t.Errorf("модель = %q, ожидалась test-model", req.Model)
+			}
+			options = req.Options
+			return generateResponse("привет")
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	resp, err := NewHTTPLLM(srv.URL).GenerateResponse(context.Background(), "тест", map[string]interface{}{"num_predict": 500})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if resp != "привет" {
+		t.Errorf("ответ = %q, ожидался %q", resp, "привет")
+	}
+	// num_predict ограничивается LLM_MAX_TOKENS
+	if options["num_predict"] != float64(100) {
+		t.Errorf("num_predict = %v, ожидалось 100", options["num_predict"])
+	}
+}
+
+func TestGenerateResponseHTTPError(t *testing.T) {
+	var calls atomic.Int32
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			calls.Add(1)
+			return http.StatusInternalServerError, "internal error"
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	_, err := NewHTTPLLM(srv.URL, WithRetry(2, time.Millisecond)).GenerateResponse(context.Background(), "тест", nil)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("ожидалась ошибка с кодом 500, получено: %v", err)
+	}
+	// Первый запрос и два повтора
+	if got := calls.Load(); got != 3 {
+		t.Errorf("запросов к Ollama %d, ожидалось 3", got)
+	}
+}
+
+func TestGenerateResponseRetry(t *testing.T) {
+	var calls atomic.Int32
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			// Модель еще загружается: первый запрос получает 503
+			if calls.Add(1) == 1 {
+				return http.StatusServiceUnavailable, "loading model"
+			}
+			return generateResponse("привет")
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	resp, err := NewHTTPLLM(srv.URL, WithRetry(2, time.Millisecond)).GenerateResponse(context.Background(), "тест", nil)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if resp != "привет" {
+		t.Errorf("ответ = %q, ожидался %q", resp, "привет")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("запросов к Ollama %d, ожидалось 2", got)
+	}
+}
+
+func TestGenerateResponseContextCanceled(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var calls atomic.Int32
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			calls.Add(1)
+			close(started)
+			<-release // Ollama "думает", пока вызывающий не отменит запрос
+			return generateResponse("поздно")
+		},
+	}
+	srv := newMockOllama(t, m)
+	// Регистрируется после newMockOllama, чтобы обработчик завершился до закрытия сервера
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := NewHTTPLLM(srv.URL, WithRetry(2, time.Millisecond)).Answer(ctx, "вопрос", []Document{{Header: "Заголовок", Text: "Текст"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ожидалась ошибка context.Canceled, получено: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("запрос прерван через %v после отмены", elapsed)
+	}
+	// Отмененный запрос не повторяется
+	if got := calls.Load(); got != 1 {
+		t.Errorf("запросов к Ollama %d, ожидался 1", got)
+	}
+}
+
+func TestPostTimeoutIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	h := NewHTTPLLM(srv.URL, WithRetry(2, time.Millisecond))
+	client := &http.Client{Timeout: 20 * time.Millisecond}
+
+	if _, err := h.post(context.Background(), client, "/api/generate", []byte("{}")); err == nil {
+		t.Fatal("ожидалась ошибка таймаута")
+	}
+	// Таймаут клиента не повторяется
+	if got := calls.Load(); got != 1 {
+		t.Errorf("запросов к Ollama %d, ожидался 1", got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		err    error
+		want   bool
+	}{
+		{"ответ 200", context.Background(), http.StatusOK, nil, false},
+		{"ответ 404", context.Background(), http.StatusNotFound, nil, false},
+		{"ответ 503", context.Background(), http.StatusServiceUnavailable, nil, true},
+		{"сетевая ошибка", context.Background(), 0, errors.New("connection refused"), true},
+		{"истек дедлайн", context.Background(), 0, fmt.Errorf("запрос: %w", context.DeadlineExceeded), false},
+		{"отмененный контекст", canceled, 0, errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := isRetryable(tt.ctx, resp, tt.err); got != tt.want {
+				t.Errorf("isRetryable = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateResponseInvalidJSON(t *testing.T) {
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			return http.StatusOK, "{не json"
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	if _, err := NewHTTPLLM(srv.URL).GenerateResponse(context.Background(), "тест", nil); err == nil {
+		t.Fatal("ожидалась ошибка десериализации")
+	}
+}
+
+func TestModelAvailabilityIsCached(t *testing.T) {
+	m := &mockOllama{
+		models:   []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) { return generateResponse("ok") },
+	}
+	srv := newMockOllama(t, m)
+
+	engine := NewHTTPLLM(srv.URL)
+	for i := 0; i < 3; i++ {
+		if _, err := engine.GenerateResponse(context.Background(), "тест", nil); err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+	}
+
+	if calls := m.tagsCalls.Load(); calls != 1 {
+		t.Errorf("список моделей запрошен %d раз, ожидался 1", calls)
+	}
+}
+
+func TestMissingModelIsPulled(t *testing.T) {
+	m := &mockOllama{
+		generate: func(req OllamaRequest) (int, string) { return generateResponse("ok") },
+	}
+	srv := newMockOllama(t, m)
+
+	if _, err := NewHTTPLLM(srv.URL).GenerateResponse(context.Background(), "тест", nil); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	if calls := m.pullCalls.Load(); calls != 1 {
+		t.Errorf("скачивание модели вызвано %d раз, ожидался 1", calls)
+	}
+}
+
+func TestGenerateEmbedding(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		status  int
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{name: "успех", text: "текст", status: http.StatusOK, body: `{"embeddings":[[0.1,0.2,0.3]]}`, want: 3},
+		{name: "пустой массив эмбеддингов", text: "текст", status: http.StatusOK, body: `{"embeddings":[]}`, wantErr: true},
+		{name: "пустой эмбеддинг", text: "текст", status: http.StatusOK, body: `{"embeddings":[[]]}`, wantErr: true},
+		{name: "ошибка HTTP", text: "текст", status: http.StatusInternalServerError, body: "error", wantErr: true},
+		{name: "пустой текст", text: "   ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockOllama{
+				models: []string{"test-embed"},
+				embed: func(req EmbeddingRequest) (int, string) {
+					if req.Model != "test-embed" {
+						t.Errorf("модель = %q, ожидалась test-embed", req.Model)
+					}
+					return tt.status, tt.body
+				},
+			}
+			srv := newMockOllama(t, m)
+
+			embedding, err := NewHTTPLLM(srv.URL, WithRetry(0, 0)).GenerateEmbedding(context.Background(), tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ожидалась ошибка")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if len(embedding) != tt.want {
+				t.Errorf("размер эмбеддинга = %d, ожидался %d", len(embedding), tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{name: "удаляет служебные префиксы", response: "ЗАГОЛОВОК: Ответ. ССЫЛКА: https://example.com", want: "Ответ. https://example.com"},
+		{name: "пустой ответ", response: "", want: "Пожалуйста, уточните вопрос или напишите на support@nethouse.ru"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockOllama{
+				models: []string{"test-model"},
+				generate: func(req OllamaRequest) (int, string) {
+					// Режим JSON выключен, пока модель не указана в LLM_JSON_MODE_MODELS
+					if req.Format != "" {
+						t.Errorf("format = %q, ожидался текстовый ответ", req.Format)
+					}
+					return generateResponse(tt.response)
+				},
+			}
+			srv := newMockOllama(t, m)
+
+			docs := []Document{{Header: "Заголовок", Link: "https://example.com", Text: "Текст документа"}}
+			answer, err := NewHTTPLLM(srv.URL).Answer(context.Background(), "вопрос", docs)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if answer != tt.want {
+				t.Errorf("ответ = %q, ожидался %q", answer, tt.want)
+			}
+
+			prompt, _ := m.lastPrompt.Load().(string)
+			if !strings.Contains(prompt, "Текст документа") || !strings.Contains(prompt, "вопрос") {
+				t.Errorf("промпт не содержит документ или вопрос: %q", prompt)
+			}
+		})
+	}
+}
+
+func TestAnswerUsesSamplingConfig(t *testing.T) {
+	t.Setenv("LLM_TOP_K", "7")
+	t.Setenv("LLM_TOP_P", "0.5")
+	t.Setenv("LLM_REPEAT_PENALTY", "1.05")
+
+	var options map[string]interface{}
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			options = req.Options
+			return generateResponse("ответ")
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	if _, err := NewHTTPLLM(srv.URL).Answer(context.Background(), "вопрос", []Document{{Header: "Заголовок", Text: "Текст"}}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	// Параметры выборки ответа берутся из переменных окружения
+	want := map[string]float64{"top_k": 7, "top_p": 0.5, "repeat_penalty": 1.05}
+	for key, value := range want {
+		if options[key] != value {
+			t.Errorf("%s = %v, ожидалось %v", key, options[key], value)
+		}
+	}
+}
+
+func TestAnswerJSONMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{
+			name:     "структурированный ответ",
+			response: `{"answer": "Откройте настройки.", "confidence": 1.4, "sources": [2, 5, 2]}`,
+			want:     "Откройте настройки.\n\nВторой https://example.com/2",
+		},
+		{
+			name:     "ответ не в JSON",
+			response: "ЗАГОЛОВОК: Откройте настройки.",
+			want:     "Откройте настройки.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockOllama{
+				models: []string{"llama3.2"},
+				generate: func(req OllamaRequest) (int, string) {
+					if req.Format != "json" {
+						t.Errorf("format = %q, ожидался json", req.Format)
+					}
+					return generateResponse(tt.response)
+				},
+			}
+			srv := newMockOllama(t, m)
+			t.Setenv("LLM_MODEL", "llama3.2")
+			t.Setenv("LLM_JSON_MODE_MODELS", "mistral, llama3")
+
+			docs := []Document{
+				{Header: "Первый", Link: "https://example.com/1", Text: "Текст"},
+				{Header: "Второй", Link: "https://example.com/2", Text: "Текст"},
+			}
+			answer, err := NewHTTPLLM(srv.URL).Answer(context.Background(), "вопрос", docs)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if answer != tt.want {
+				t.Errorf("ответ = %q, ожидался %q", answer, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStructuredAnswerConfidence(t *testing.T) {
+	answer, err := parseStructuredAnswer(`{"answer": "да", "confidence": -3}`, nil)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if answer.Confidence != 0 {
+		t.Errorf("confidence = %v, ожидался 0", answer.Confidence)
+	}
+
+	if _, err := parseStructuredAnswer(`{"confidence": 0.5}`, nil); err == nil {
+		t.Error("ожидалась ошибка для ответа без текста")
+	}
+}
+
+func TestAnswerStream(t *testing.T) {
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			if !req.Stream {
+				t.Error("запрос отправлен без stream")
+			}
+			var body strings.Builder
+			// Метка приходит по частям в двух фрагментах
+			for _, token := range []string{"ЗАГО", "ЛОВОК: Откройте ", "настройки"} {
+				line, _ := json.Marshal(OllamaResponse{Response: token})
+				body.Write(line)
+				body.WriteByte('\n')
+			}
+			body.WriteString(`{"response":"","done":true}`)
+			return http.StatusOK, body.String()
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	var tokens []string
+	docs := []Document{{Header: "Заголовок", Link: "https://example.com", Text: "Текст документа"}}
+	answer, err := NewHTTPLLM(srv.URL).AnswerStream(context.Background(), "вопрос", docs, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if streamed := strings.Join(tokens, ""); streamed != "Откройте настройки" {
+		t.Errorf("клиенту отправлено %q, ожидалось %q", streamed, "Откройте настройки")
+	}
+	if answer != "Откройте настройки" {
+		t.Errorf("ответ = %q, ожидался %q", answer, "Откройте настройки")
+	}
+}
+
+func TestExtractEssenceFallback(t *testing.T) {
+	m := &mockOllama{
+		models:   []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) { return generateResponse("  ") },
+	}
+	srv := newMockOllama(t, m)
+
+	essence, err := NewHTTPLLM(srv.URL).ExtractEssence(context.Background(), "исходный вопрос")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if essence != "исходный вопрос" {
+		t.Errorf("суть = %q, ожидался исходный запрос", essence)
+	}
+}
+
+func TestExtractEssenceUsesEssenceModel(t *testing.T) {
+	var essenceModel string
+	m := &mockOllama{
+		models: []string{"test-model", "tiny-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			essenceModel = req.Model
+			return generateResponse("суть")
+		},
+	}
+	srv := newMockOllama(t, m)
+	t.Setenv("LLM_ESSENCE_MODEL", "tiny-model")
+
+	if _, err := NewHTTPLLM(srv.URL).ExtractEssence(context.Background(), "вопрос"); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if essenceModel != "tiny-model" {
+		t.Errorf("суть выделена моделью %q, ожидалась tiny-model", essenceModel)
+	}
+}
+
+func TestClassifyQuery(t *testing.T) {
+	categories := []string{"technical", "billing", "greeting", "off-topic"}
+
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{name: "точное совпадение", response: "billing", want: "billing"},
+		{name: "регистр и пунктуация", response: " Greeting.\n", want: "greeting"},
+		{name: "категория внутри ответа", response: "Категория: off-topic", want: "off-topic"},
+		{name: "неизвестная категория", response: "weather", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockOllama{
+				models:   []string{"test-model"},
+				generate: func(req OllamaRequest) (int, string) { return generateResponse(tt.response) },
+			}
+			srv := newMockOllama(t, m)
+
+			category, err := NewHTTPLLM(srv.URL).ClassifyQuery(context.Background(), "вопрос", categories)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получено %q", category)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if category != tt.want {
+				t.Errorf("категория = %q, ожидалась %q", category, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimDocumentContext(t *testing.T) {
+	doc := Document{Text: "абвгдеёжзи"}
+
+	if got := TrimDocumentContext(doc, 20).Text; got != doc.Text {
+		t.Errorf("короткий текст изменен: %q", got)
+	}
+	if got := TrimDocumentContext(doc, 3).Text; got != "абв..." {
+		t.Errorf("обрезанный текст = %q, ожидался %q", got, "абв...")
+	}
+}
+
+func TestTrimDocumentsContext(t *testing.T) {
+	short := Document{Text: "абвг"}
+	medium := Document{Text: strings.Repeat("м", 6)}
+	long := Document{Text: strings.Repeat("д", 40)}
+
+	// Бюджет 3*10 = 30: короткие документы (4 и 6 символов) целиком, длинному - оставшиеся 20
+	got := trimDocumentsContext([]Document{long, short, medium}, 10)
+	if got[1].Text != short.Text || got[2].Text != medium.Text {
+		t.Errorf("короткие документы обрезаны: %q, %q", got[1].Text, got[2].Text)
+	}
+	if want := strings.Repeat("д", 20) + "..."; got[0].Text != want {
+		t.Errorf("длинный документ = %q, ожидался %q", got[0].Text, want)
+	}
+
+	// Два длинных документа делят остаток бюджета поровну
+	got = trimDocumentsContext([]Document{long, short, long}, 10)
+	if got[1].Text != short.Text {
+		t.Errorf("короткий документ обрезан: %q", got[1].Text)
+	}
+	if want := strings.Repeat("д", 13) + "..."; got[0].Text != want || got[2].Text != want {
+		t.Errorf("длинные документы = %q, %q, ожидалось %q", got[0].Text, got[2].Text, want)
+	}
+
+	// Все помещается в бюджет - документы не меняются
+	got = trimDocumentsContext([]Document{short, medium}, 10)
+	if got[0].Text != short.Text || got[1].Text != medium.Text {
+		t.Errorf("документы в пределах бюджета изменены: %q, %q", got[0].Text, got[1].Text)
+	}
+}
+
+func TestDeduplicateDocs(t *testing.T) {
+	docs := []Document{
+		{ID: "a", Link: "https://example.com/a", Embedding: []float32{1, 0}},
+		{ID: "a-keyword", Link: "https://example.com/a", Embedding: []float32{1, 0}},
+		{ID: "copy", Link: "https://example.com/copy", Embedding: []float32{1000, 1}},
+		{ID: "b", Link: "https://example.com/b", Embedding: []float32{1, 1}},
+		{ID: "no-link-1"},
+		{ID: "no-link-2"},
+	}
+
+	var got []string
+	for _, doc := range DeduplicateDocs(docs) {
+		got = append(got, doc.ID)
+	}
+
+	want := []string{"a", "b", "no-link-1", "no-link-2"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("DeduplicateDocs = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestWarmupModel(t *testing.T) {
+	var embedModel, generateModel string
+	m := &mockOllama{
+		models: []string{"test-model", "test-embed"},
+		generate: func(req OllamaRequest) (int, string) {
+			generateModel = req.Model
+			return generateResponse("")
+		},
+		embed: func(req EmbeddingRequest) (int, string) {
+			embedModel = req.Model
+			return http.StatusOK, `{"embeddings":[]}`
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	if err := NewHTTPLLM(srv.URL).WarmupModel(context.Background()); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if embedModel != "test-embed" {
+		t.Errorf("прогрета модель эмбеддингов %q, ожидалась test-embed", embedModel)
+	}
+	if generateModel != "test-model" {
+		t.Errorf("прогрета модель генерации %q, ожидалась test-model", generateModel)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr bool
+	}{
+		{"модель отвечает", "ok", false},
+		{"пустой ответ", "  \n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockOllama{
+				models: []string{"test-model"},
+				generate: func(req OllamaRequest) (int, string) {
+					return generateResponse(tt.reply)
+				},
+			}
+			srv := newMockOllama(t, m)
+
+			err := NewHTTPLLM(srv.URL).HealthCheck(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ошибка = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if prompt, _ := m.lastPrompt.Load().(string); prompt != healthCheckPrompt {
+				t.Errorf("промпт = %q, ожидался %q", prompt, healthCheckPrompt)
+			}
+		})
+	}
+}
+
+func TestWaitForOllama(t *testing.T) {
+	t.Setenv("LLM_EMBEDDINGS_MODEL", "test-embed")
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Первые две проверки Ollama еще запускается
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(OllamaModelsResponse{})
+	}))
+	defer srv.Close()
+
+	if err := NewHTTPLLM(srv.URL).WaitForOllama(context.Background(), time.Millisecond, time.Second); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("проверок Ollama %d, ожидалось 3", got)
+	}
+}
+
+func TestWaitForOllamaTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if err := NewHTTPLLM(srv.URL).WaitForOllama(context.Background(), time.Millisecond, 20*time.Millisecond); err == nil {
+		t.Error("ожидалась ошибка после истечения времени ожидания")
+	}
+}
+
+func TestSuggestFollowUps(t *testing.T) {
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			return generateResponse("Вот вопросы:\n[\"Как сменить тариф?\", \"\", \"Как сменить тариф?\", \"Где найти счет?\", \"Как продлить домен?\", \"Лишний\"]")
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	questions, err := NewHTTPLLM(srv.URL).SuggestFollowUps(context.Background(), "оплата", []Document{{Header: "Оплата", Text: "Текст"}})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	want := []string{"Как сменить тариф?", "Где найти счет?", "Как продлить домен?"}
+	if strings.Join(questions, "|") != strings.Join(want, "|") {
+		t.Errorf("вопросы = %q, ожидались %q", questions, want)
+	}
+}
+
+func TestRerank(t *testing.T) {
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			return generateResponse("[3, 1, 3, 7]")
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	docs := []Document{{Header: "A"}, {Header: "B"}, {Header: "C"}}
+	ranked, err := NewHTTPLLM(srv.URL).Rerank(context.Background(), "вопрос", docs, 3)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	var headers []string
+	for _, doc := range ranked {
+		headers = append(headers, doc.Header)
+	}
+	// Повторы и несуществующие номера игнорируются, неупомянутые документы идут в конце
+	if got := strings.Join(headers, ","); got != "C,A,B" {
+		t.Errorf("порядок = %s, ожидался C,A,B", got)
+	}
+}
+
+func TestWithHTTPTransport(t *testing.T) {
+	transport := &http.Transport{MaxIdleConnsPerHost: 7, MaxConnsPerHost: 9, IdleConnTimeout: time.Second}
+	h := NewHTTPLLM("http://localhost", WithHTTPTransport(transport))
+
+	// Оба клиента должны использовать один пул соединений
+	for _, client := range []*http.Client{h.client, h.embedClient} {
+		ua, ok := client.Transport.(*userAgentTransport)
+		if !ok || ua.base != transport {
+			t.Fatalf("клиент не использует переданный транспорт")
+		}
+	}
+}
+
+func TestLoadPromptTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "essence.tmpl"), []byte("Суть: {{.Query}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	builtin := `Выдели кратко суть следующего вопроса пользователя, сохранив только ключевые слова и смысл:
+
+{{.Query}}`
+	t.Cleanup(func() { _ = EssencePromptTemplate.Parse(builtin) })
+
+	loaded, err := LoadPromptTemplates(dir)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0] != "essence" {
+		t.Errorf("загружены шаблоны %v, ожидался только essence", loaded)
+	}
+
+	prompt, err := EssencePromptTemplate.Execute(EssencePromptData{Query: "вопрос"})
+	if err != nil || prompt != "Суть: вопрос" {
+		t.Errorf("промпт = %q, ошибка %v", prompt, err)
+	}
+
+	// Шаблоны без файла остаются встроенными
+	prompt, _ = AnswerPromptTemplate.Execute(AnswerPromptData{Query: "вопрос"})
+	if !strings.HasPrefix(prompt, "ДОКУМЕНТЫ:") {
+		t.Errorf("встроенный шаблон ответа заменен: %q", prompt)
+	}
+
+	// Шаблон с ошибкой не заменяет встроенный
+	if err := os.WriteFile(filepath.Join(dir, "summarize.tmpl"), []byte("{{.HTML"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPromptTemplates(dir); err == nil {
+		t.Error("ожидалась ошибка разбора шаблона")
+	}
+}
+
+func TestAnswerWithCitations(t *testing.T) {
+	m := &mockOllama{
+		models: []string{"test-model"},
+		generate: func(req OllamaRequest) (int, string) {
+			if req.Format != "json" {
+				t.Errorf("format = %q, ожидался json", req.Format)
+			}
+			return generateResponse(`{"answer": "Откройте настройки.", "citations": [
+				{"document": 2, "sentence": "Настройки находятся в меню."},
+				{"document": 5, "sentence": "Несуществующий документ."},
+				{"document": 1, "sentence": " "}
+			]}`)
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	docs := []Document{{ID: "a", Link: "https://a"}, {ID: "b", Link: "https://b"}}
+	answer, citations, err := NewHTTPLLM(srv.URL).AnswerWithCitations(context.Background(), "вопрос", docs)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	if answer != "Откройте настройки." {
+		t.Errorf("ответ = %q", answer)
+	}
+	// Цитаты с неверным номером документа и пустым предложением отбрасываются
+	want := []Citation{{DocID: "b", URL: "https://b", RelevantSentence: "Настройки находятся в меню."}}
+	if len(citations) != 1 || citations[0] != want[0] {
+		t.Errorf("цитаты = %+v, ожидались %+v", citations, want)
+	}
+}
+
+func TestBatchAnswer(t *testing.T) {
+	t.Setenv("LLM_MAX_CONCURRENCY", "2")
+
+	queries := []string{"вопрос-1", "вопрос-2", "вопрос-3", "вопрос-4", "вопрос-5"}
+
+	var inFlight, maxInFlight atomic.Int32
+	m := &mockOllama{
+		models: []string{"test-model:latest"},
+		generate: func(req OllamaRequest) (int, string) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				seen := maxInFlight.Load()
+				if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			for _, query := range queries {
+				if strings.Contains(req.Prompt, query) {
+					return generateResponse("ответ на " + query)
+				}
+			}
+			return generateResponse("неизвестный вопрос")
+		},
+	}
+	srv := newMockOllama(t, m)
+
+	docs := make([][]Document, len(queries))
+	for i := range docs {
+		docs[i] = []Document{{Header: "Документ", Link: "https://example.com", Text: "Текст"}}
+	}
+
+	answers, err := NewHTTPLLM(srv.URL).BatchAnswer(context.Background(), queries, docs)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	for i, query := range queries {
+		if answers[i] != "ответ на "+query {
+			t.Errorf("ответ %d = %q, ожидался ответ на %s", i, answers[i], query)
+		}
+	}
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("одновременно выполнялось %d запросов, ожидалось не больше 2", got)
+	}
+
+	if _, err := NewHTTPLLM(srv.URL).BatchAnswer(context.Background(), queries, docs[:1]); err == nil {
+		t.Error("ожидалась ошибка при разном числе вопросов и наборов документов")
+	}
+}
@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// MockLLMEngine - LLMEngine для тестов с настраиваемыми ответами.
+// Незаданные функции возвращают ошибку (GenerateResponse, GenerateEmbedding, Answer)
+// или нейтральный результат (ExtractEssence - исходный запрос, ClassifyQuery - первая категория).
+type MockLLMEngine struct {
+	ResponseFn  func(prompt string, params map[string]interface{}) (string, error)
+	EmbeddingFn func(text string) ([]float32, error)
+	AnswerFn    func(query string, docs []Document) (string, error)
+
+	Calls atomic.Int32 // общее число вызовов методов
+}
+
+var _ LLMEngine = (*MockLLMEngine)(nil)
+
+// NewStaticMock возвращает мок, который на любой запрос отдает один и тот же эмбеддинг и ответ
+func NewStaticMock(embedding []float32, response string) *MockLLMEngine {
+	return &MockLLMEngine{
+		ResponseFn: func(string, map[string]interface{}) (string, error) {
+			return response, nil
+		},
+		EmbeddingFn: func(string) ([]float32, error) {
+			return embedding, nil
+		},
+		AnswerFn: func(string, []Document) (string, error) {
+			return response, nil
+		},
+	}
+}
+
+func (m *MockLLMEngine) GenerateResponse(ctx context.Context, prompt string, params map[string]interface{}) (string, error) {
+	m.Calls.Add(1)
+	if m.ResponseFn == nil {
+		return "", fmt.Errorf("MockLLMEngine: ResponseFn не задана")
+	}
+	return m.ResponseFn(prompt, params)
+}
+
+func (m *MockLLMEngine) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	m.Calls.Add(1)
+	if m.EmbeddingFn == nil {
+		return nil, fmt.Errorf("MockLLMEngine: EmbeddingFn не задана")
+	}
+	return m.EmbeddingFn(text)
+}
+
+func (m *MockLLMEngine) Answer(ctx context.Context, query string, docs []Document) (string, error) {
+	m.Calls.Add(1)
+	if m.AnswerFn == nil {
+		return "", fmt.Errorf("MockLLMEngine: AnswerFn не задана")
+	}
+	return m.AnswerFn(query, docs)
+}
+
+// BatchAnswer отвечает на вопросы последовательно через Answer
+func (m *MockLLMEngine) BatchAnswer(ctx context.Context, queries []string, docs [][]Document) ([]string, error) {
+	if len(queries) != len(docs) {
+		return nil, fmt.Errorf("число вопросов (%d) не совпадает с числом наборов документов (%d)", len(queries), len(docs))
+	}
+
+	answers := make([]string, 0, len(queries))
+	for i, query := range queries {
+		answer, err := m.Answer(ctx, query, docs[i])
+		if err != nil {
+			return answers, err
+		}
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
+
+// AnswerWithCitations отвечает через AnswerFn и не возвращает цитат
+func (m *MockLLMEngine) AnswerWithCitations(ctx context.Context, query string, docs []Document) (string, []Citation, error) {
+	answer, err := m.Answer(ctx, query, docs)
+	return answer, nil, err
+}
+
+func (m *MockLLMEngine) ExtractEssence(ctx context.Context, query string) (string, error) {
+	m.Calls.Add(1)
+	return query, nil
+}
+
+func (m *MockLLMEngine) ClassifyQuery(ctx context.Context, query string, categories []string) (string, error) {
+	m.Calls.Add(1)
+	if len(categories) == 0 {
+		return "", fmt.Errorf("список категорий пуст")
+	}
+	return categories[0], nil
+}
+
+func (m *MockLLMEngine) SuggestFollowUps(ctx context.Context, query string, docs []Document) ([]string, error) {
+	m.Calls.Add(1)
+	return nil, nil
+}
+
+// Rerank возвращает документы в исходном порядке, не больше topK
+func (m *MockLLMEngine) Rerank(ctx context.Context, query string, docs []Document, topK int) ([]Document, error) {
+	m.Calls.Add(1)
+	if topK > 0 && topK < len(docs) {
+		return docs[:topK], nil
+	}
+	return docs, nil
+}
+
+func (m *MockLLMEngine) HealthCheck(ctx context.Context) error {
+	m.Calls.Add(1)
+	return nil
+}
@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// PromptTemplate - шаблон промпта на text/template. Встроенный текст можно заменить
+// файлом <имя>.tmpl из PROMPT_TEMPLATE_DIR (см. LoadPromptTemplates).
+type PromptTemplate struct {
+	name string
+
+	mu   sync.RWMutex
+	tmpl *template.Template
+}
+
+// promptFuncs - функции, доступные в шаблонах промптов
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"inc":  func(i int) int { return i + 1 },
+}
+
+// NewPromptTemplate создает шаблон; паникует при синтаксической ошибке, как template.Must
+func NewPromptTemplate(name, text string) *PromptTemplate {
+	return &PromptTemplate{
+		name: name,
+		tmpl: template.Must(template.New(name).Funcs(promptFuncs).Parse(text)),
+	}
+}
+
+// Name возвращает имя шаблона (и имя файла без расширения .tmpl)
+func (p *PromptTemplate) Name() string {
+	return p.name
+}
+
+// Parse заменяет текст шаблона; при ошибке разбора остается прежний шаблон
+func (p *PromptTemplate) Parse(text string) error {
+	tmpl, err := template.New(p.name).Funcs(promptFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("ошибка разбора шаблона %s: %w", p.name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tmpl = tmpl
+	return nil
+}
+
+// Execute формирует промпт из шаблона и данных
+func (p *PromptTemplate) Execute(data any) (string, error) {
+	p.mu.RLock()
+	tmpl := p.tmpl
+	p.mu.RUnlock()
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("ошибка заполнения шаблона %s: %w", p.name, err)
+	}
+	return sb.String(), nil
+}
+
+// AnswerPromptData - данные для AnswerPromptTemplate, AnswerJSONPromptTemplate и CitationsPromptTemplate
+type AnswerPromptData struct {
+	Query     string
+	Documents []Document
+}
+
+// EssencePromptData - данные для EssencePromptTemplate
+type EssencePromptData struct {
+	Query string
+}
+
+// SummarizePromptData - данные для SummarizePromptTemplate
+type SummarizePromptData struct {
+	HTML string
+}
+
+// AnswerPromptTemplate - промпт ответа на вопрос по найденным документам
+var AnswerPromptTemplate = NewPromptTemplate("answer", `ДОКУМЕНТЫ:
+{{range .Documents}}ЗАГОЛОВОК: {{.Header}}
+ССЫЛКА: {{.Link}}
+{{with .ReadingTimeMinutes}}ВРЕМЯ ЧТЕНИЯ СТАТЬИ: примерно {{.}} мин.
+{{end}}ТЕКСТ: {{.Text}}
+{{if .CodeSnippets}}КОМАНДЫ:
+{{join .CodeSnippets "\n"}}
+{{end}}
+----------
+
+{{end}}
+
+ВОПРОС ПОЛЬЗОВАТЕЛЯ: {{.Query}}
+
+ОТВЕТ:`)
+
+// AnswerJSONPromptTemplate - промпт ответа в формате JSON для моделей из GetJSONModeModels
+var AnswerJSONPromptTemplate = NewPromptTemplate("answer_json", `ДОКУМЕНТЫ:
+{{range $i, $doc := .Documents}}ДОКУМЕНТ {{inc $i}}
+ЗАГОЛОВОК: {{$doc.Header}}
+{{with $doc.ReadingTimeMinutes}}ВРЕМЯ ЧТЕНИЯ СТАТЬИ: примерно {{.}} мин.
+{{end}}ТЕКСТ: {{$doc.Text}}
+{{if $doc.CodeSnippets}}КОМАНДЫ:
+{{join $doc.CodeSnippets "\n"}}
+{{end}}
+{{end}}
+ВОПРОС ПОЛЬЗОВАТЕЛЯ: {{.Query}}
+
+Ответь на вопрос, используя только документы. Ответ верни JSON-объектом:
+{"answer": "текст ответа без ссылок", "confidence": 0.8, "sources": [1]}
+В confidence укажи уверенность в ответе от 0 до 1, в sources - номера документов, на которых основан ответ.`)
+
+// CitationsPromptTemplate - промпт ответа с цитатами из документов в формате JSON (AnswerWithCitations)
+var CitationsPromptTemplate = NewPromptTemplate("citations", `ДОКУМЕНТЫ:
+{{range $i, $doc := .Documents}}ДОКУМЕНТ {{inc $i}}
+ЗАГОЛОВОК: {{$doc.Header}}
+ТЕКСТ: {{$doc.Text}}
+
+{{end}}
+ВОПРОС ПОЛЬЗОВАТЕЛЯ: {{.Query}}
+
+Ответь на вопрос, используя только документы. Ответ верни JSON-объектом:
+{"answer": "текст ответа", "citations": [{"document": 1, "sentence": "предложение из документа"}]}
+В citations перечисли номера документов и дословные предложения из них, на которых основан ответ.`)
+
+// EssencePromptTemplate - промпт выделения сути вопроса
+var EssencePromptTemplate = NewPromptTemplate("essence", `Выдели кратко суть следующего вопроса пользователя, сохранив только ключевые слова и смысл:
+
+{{.Query}}`)
+
+// SummarizePromptTemplate - промпт извлечения содержательного текста из HTML страницы (downloader_ai)
+var SummarizePromptTemplate = NewPromptTemplate("summarize", `Проанализируй следующий HTML-документ.
+Извлеки только важный и содержательный текст: факты, определения, инструкции, ключевые выводы.
+Не добавляй вступлений, объяснений или комментариев.
+Результат представь в виде простого, чистого текста без форматирования и выделения заголовков.
+Не используй markdown и HTML для разметки.
+
+HTML:
+{{.HTML}}`)
+
+// GetPromptTemplateDir возвращает папку с пользовательскими шаблонами промптов (PROMPT_TEMPLATE_DIR)
+func GetPromptTemplateDir() string {
+	return os.Getenv("PROMPT_TEMPLATE_DIR")
+}
+
+// LoadPromptTemplates заменяет встроенные шаблоны файлами answer.tmpl, answer_json.tmpl, citations.tmpl, essence.tmpl и summarize.tmpl
+// из папки dir. Для отсутствующих файлов остаются встроенные шаблоны. Возвращает имена загруженных шаблонов.
+func LoadPromptTemplates(dir string) ([]string, error) {
+	var loaded []string
+	var errs []error
+
+	for _, prompt := range []*PromptTemplate{AnswerPromptTemplate, AnswerJSONPromptTemplate, CitationsPromptTemplate, EssencePromptTemplate, SummarizePromptTemplate} {
+		data, err := os.ReadFile(filepath.Join(dir, prompt.Name()+".tmpl"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ошибка чтения шаблона %s: %w", prompt.Name(), err))
+			continue
+		}
+
+		if err := prompt.Parse(string(data)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		loaded = append(loaded, prompt.Name())
+	}
+
+	return loaded, errors.Join(errs...)
+}
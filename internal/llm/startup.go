@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// errModelNotFound - Ollama доступна, но модели нет в списке загруженных
+var errModelNotFound = errors.New("model not found in available models")
+
+// GetStartupRetry возвращает интервал проверки Ollama при запуске и общее время ожидания
+// (STARTUP_RETRY_INTERVAL, по умолчанию 10s; STARTUP_RETRY_TIMEOUT, по умолчанию 5m)
+func GetStartupRetry() (interval, timeout time.Duration) {
+	interval, err := time.ParseDuration(os.Getenv("STARTUP_RETRY_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	timeout, err = time.ParseDuration(os.Getenv("STARTUP_RETRY_TIMEOUT"))
+	if err != nil || timeout < 0 {
+		timeout = 5 * time.Minute
+	}
+
+	return interval, timeout
+}
+
+// WaitForOllama ждет, пока Ollama начнет отвечать: каждые interval проверяет доступность модели эмбеддингов,
+// пока не истечет timeout. Отсутствие модели ошибкой не считается - она скачивается при первом обращении.
+// Нужна при запуске в Docker Compose, где Ollama может стартовать дольше бота.
+func (h *HTTPLLMEngine) WaitForOllama(ctx context.Context, interval, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for attempt := 1; ; attempt++ {
+		err := h.checkModelAvailability(GetLLMEmbeddingsModel())
+		if err == nil || errors.Is(err, errModelNotFound) {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("Ollama недоступна по адресу %s дольше %v: %w", h.apiURL, timeout, err)
+		}
+
+		wait := min(interval, remaining)
+		log.Printf("Ollama недоступна (попытка %d): %v. Повтор через %v, до отказа осталось %v",
+			attempt, err, wait.Round(time.Second), remaining.Round(time.Second))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
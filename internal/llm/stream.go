@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AnswerStreamer - движок, умеющий отдавать ответ по мере генерации.
+// onToken вызывается для каждого фрагмента ответа; ошибка из onToken прерывает генерацию.
+type AnswerStreamer interface {
+	AnswerStream(ctx context.Context, query string, docs []Document, onToken func(token string) error) (string, error)
+}
+
+var _ AnswerStreamer = (*HTTPLLMEngine)(nil)
+
+// AnswerStream отвечает на вопрос как Answer, передавая фрагменты ответа из потокового API Ollama в onToken.
+// Служебные метки (см. answerLabels) вырезаются еще до onToken, поэтому клиент получает тот же текст,
+// что и полный ответ, который возвращается после окончания генерации.
+func (h *HTTPLLMEngine) AnswerStream(ctx context.Context, query string, docs []Document, onToken func(token string) error) (string, error) {
+	ctx, span := tracer.Start(ctx, "llm.answer_stream", trace.WithAttributes(attribute.Int("llm.documents", len(docs))))
+	response, err := h.answerStream(ctx, query, docs, onToken)
+	telemetry.EndSpan(span, err)
+	return response, err
+}
+
+func (h *HTTPLLMEngine) answerStream(ctx context.Context, query string, docs []Document, onToken func(token string) error) (string, error) {
+	reqBody, err := h.answerRequest(query, docs, true)
+	if err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := h.post(ctx, h.client, "/api/generate", jsonData)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	// Ollama отдает поток JSON-объектов, по одному на фрагмент ответа
+	decoder := json.NewDecoder(resp.Body)
+	var response []byte
+	var filter labelFilter
+	emitted := false
+	emit := func(text string) error {
+		if text == "" {
+			return nil
+		}
+		emitted = true
+		return onToken(text)
+	}
+
+	for {
+		var chunk OllamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if chunk.Response != "" {
+			response = append(response, chunk.Response...)
+			if err := emit(filter.Write(chunk.Response)); err != nil {
+				return "", err
+			}
+		}
+
+		if chunk.Done {
+			break
+		}
+	}
+
+	if err := emit(filter.Flush()); err != nil {
+		return "", err
+	}
+
+	answer := cleanAnswer(string(response))
+	// Ответ состоял только из меток: клиент получает ту же замену, что и в полном ответе
+	if !emitted {
+		if err := onToken(answer); err != nil {
+			return "", err
+		}
+	}
+
+	return answer, nil
+}
+
+// labelFilter вырезает служебные метки из потока фрагментов ответа. Метка может прийти
+// по частям в нескольких фрагментах, поэтому хвост, с которого может начинаться метка,
+// придерживается до следующего фрагмента.
+type labelFilter struct {
+	pending string
+}
+
+// Write принимает очередной фрагмент и возвращает текст, который уже можно отдать клиенту
+func (f *labelFilter) Write(chunk string) string {
+	f.pending = removeAnswerLabels(f.pending + chunk)
+
+	hold := 0
+	for _, label := range answerLabels {
+		for n := min(len(label)-1, len(f.pending)); n > hold; n-- {
+			if strings.HasSuffix(f.pending, label[:n]) {
+				hold = n
+				break
+			}
+		}
+	}
+
+	text := f.pending[:len(f.pending)-hold]
+	f.pending = f.pending[len(f.pending)-hold:]
+	return text
+}
+
+// Flush возвращает придержанный хвост после окончания генерации
+func (f *labelFilter) Flush() string {
+	text := f.pending
+	f.pending = ""
+	return text
+}
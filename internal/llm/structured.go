@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// GetJSONModeModels возвращает модели (начало имени, через запятую в LLM_JSON_MODE_MODELS), для которых
+// Answer запрашивает структурированный ответ в режиме format: "json" вместо текста с метками.
+// По умолчанию список пуст: режим включается явно для моделей, которые надежно соблюдают формат.
+func GetJSONModeModels() []string {
+	var models []string
+	for _, model := range strings.Split(os.Getenv("LLM_JSON_MODE_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// supportsJSONMode сообщает, есть ли модель в списке GetJSONModeModels
+func supportsJSONMode(model string) bool {
+	for _, prefix := range GetJSONModeModels() {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// StructuredAnswer - ответ модели в режиме JSON
+type StructuredAnswer struct {
+	Answer     string
+	Confidence float64    // уверенность модели от 0 до 1
+	Sources    []Document // документы, на которых основан ответ
+}
+
+// structuredAnswerResponse - JSON, который модель возвращает по AnswerJSONPromptTemplate
+type structuredAnswerResponse struct {
+	Answer     string  `json:"answer"`
+	Confidence float64 `json:"confidence"`
+	Sources    []int   `json:"sources"` // номера документов в промпте, с 1
+}
+
+// parseStructuredAnswer разбирает JSON-ответ модели. Источники с несуществующим номером документа
+// и повторы отбрасываются, уверенность ограничивается диапазоном [0, 1].
+func parseStructuredAnswer(resp string, docs []Document) (StructuredAnswer, error) {
+	start := strings.Index(resp, "{")
+	end := strings.LastIndex(resp, "}")
+	if start == -1 || end <= start {
+		return StructuredAnswer{}, fmt.Errorf("в ответе модели нет JSON-объекта: %q", resp)
+	}
+
+	var parsed structuredAnswerResponse
+	if err := json.Unmarshal([]byte(resp[start:end+1]), &parsed); err != nil {
+		return StructuredAnswer{}, fmt.Errorf("ошибка разбора структурированного ответа: %w", err)
+	}
+
+	answer := StructuredAnswer{
+		Answer:     strings.TrimSpace(parsed.Answer),
+		Confidence: min(max(parsed.Confidence, 0), 1),
+	}
+	if answer.Answer == "" {
+		return StructuredAnswer{}, fmt.Errorf("в ответе модели нет текста ответа: %q", resp)
+	}
+
+	seen := make(map[int]bool, len(parsed.Sources))
+	for _, number := range parsed.Sources {
+		i := number - 1
+		if i < 0 || i >= len(docs) || seen[i] {
+			continue
+		}
+		seen[i] = true
+		answer.Sources = append(answer.Sources, docs[i])
+	}
+
+	return answer, nil
+}
+
+// Text возвращает ответ с источниками в том же виде, что и текстовый ответ Answer: текст и ссылки под ним
+func (a StructuredAnswer) Text() string {
+	var sb strings.Builder
+	sb.WriteString(a.Answer)
+
+	for i, doc := range a.Sources {
+		if doc.Link == "" {
+			continue
+		}
+		if i == 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+		sb.WriteString(strings.TrimSpace(doc.Header + " " + doc.Link))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// GetKeepAlive возвращает, сколько Ollama держит модели в памяти после прогрева
+func GetKeepAlive() string {
+	keepAlive := os.Getenv("LLM_KEEP_ALIVE")
+	if keepAlive == "" {
+		return "24h"
+	}
+	return keepAlive
+}
+
+// warmupRequest - запрос без текста: Ollama только загружает модель в память
+type warmupRequest struct {
+	Model     string `json:"model"`
+	Input     string `json:"input,omitempty"`
+	KeepAlive string `json:"keep_alive"`
+}
+
+// WarmupModel загружает модели эмбеддингов, генерации и выделения сути в память Ollama,
+// чтобы первый запрос пользователя не ждал загрузки модели
+func (h *HTTPLLMEngine) WarmupModel(ctx context.Context) error {
+	if err := h.ensureModelAvailableQuiet(GetLLMEmbeddingsModel()); err != nil {
+		return fmt.Errorf("model not available: %w", err)
+	}
+
+	if err := h.warmup(ctx, "/api/embed", warmupRequest{
+		Model:     GetLLMEmbeddingsModel(),
+		Input:     "",
+		KeepAlive: GetKeepAlive(),
+	}); err != nil {
+		return fmt.Errorf("ошибка прогрева модели эмбеддингов: %w", err)
+	}
+
+	if err := h.ensureModelAvailableQuiet(GetLLMModel()); err != nil {
+		return fmt.Errorf("model not available: %w", err)
+	}
+
+	if err := h.warmup(ctx, "/api/generate", warmupRequest{
+		Model:     GetLLMModel(),
+		KeepAlive: GetKeepAlive(),
+	}); err != nil {
+		return fmt.Errorf("ошибка прогрева модели генерации: %w", err)
+	}
+
+	if essenceModel := GetLLMEssenceModel(); essenceModel != GetLLMModel() {
+		if err := h.ensureModelAvailableQuiet(essenceModel); err != nil {
+			return fmt.Errorf("model not available: %w", err)
+		}
+
+		if err := h.warmup(ctx, "/api/generate", warmupRequest{
+			Model:     essenceModel,
+			KeepAlive: GetKeepAlive(),
+		}); err != nil {
+			return fmt.Errorf("ошибка прогрева модели выделения сути: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *HTTPLLMEngine) warmup(ctx context.Context, path string, request warmupRequest) error {
+	reqBody, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации запроса: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.apiURL+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP ошибка: %d, ответ: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
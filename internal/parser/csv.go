@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// ParseCSV читает CSV-файл с заголовками и превращает каждую строку в документ.
+// Заголовок и текст берутся из колонок titleCol и contentCol, ссылка - из p.CSVURLColumn (если задана).
+// При p.CSVMetadataColumns остальные колонки сохраняются в Metadata.
+func (p *MarkdownParser) ParseCSV(filePath string, titleCol, contentCol string) ([]types.Document, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения заголовков CSV: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	titleIdx, ok := columns[titleCol]
+	if !ok {
+		return nil, fmt.Errorf("колонка %q не найдена в %s", titleCol, filePath)
+	}
+	contentIdx, ok := columns[contentCol]
+	if !ok {
+		return nil, fmt.Errorf("колонка %q не найдена в %s", contentCol, filePath)
+	}
+	urlIdx := -1
+	if p.CSVURLColumn != "" {
+		if urlIdx, ok = columns[p.CSVURLColumn]; !ok {
+			return nil, fmt.Errorf("колонка %q не найдена в %s", p.CSVURLColumn, filePath)
+		}
+	}
+
+	baseID := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+
+	var documents []types.Document
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return documents, fmt.Errorf("ошибка чтения строки %d: %w", row, err)
+		}
+
+		field := func(idx int) string {
+			if idx < 0 || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+
+		title := field(titleIdx)
+		content := field(contentIdx)
+		if title == "" && content == "" {
+			continue
+		}
+
+		// Собираем документ в формате загрузчика, чтобы он прошел обычный конвейер
+		var markdown strings.Builder
+		markdown.WriteString("# " + title + "\n\n")
+		if url := field(urlIdx); url != "" {
+			markdown.WriteString("**URL:** " + url + "\n\n")
+		}
+		markdown.WriteString(content)
+
+		doc, err := p.process(types.Document{
+			ID:      fmt.Sprintf("%s-%d", baseID, row),
+			Content: markdown.String(),
+		})
+		if err != nil {
+			return documents, fmt.Errorf("ошибка обработки строки %d: %w", row, err)
+		}
+
+		if p.CSVMetadataColumns {
+			for i, name := range header {
+				if i == titleIdx || i == contentIdx || i == urlIdx {
+					continue
+				}
+				if value := field(i); value != "" {
+					if doc.Metadata == nil {
+						doc.Metadata = make(map[string]string)
+					}
+					doc.Metadata[strings.TrimSpace(name)] = value
+				}
+			}
+		}
+
+		documents = append(documents, doc)
+	}
+
+	return documents, nil
+}
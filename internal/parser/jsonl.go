@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/crawlutil"
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// jsonlRecord - страница в JSONL-файле загрузчика (--output-format jsonl)
+type jsonlRecord struct {
+	ID        string `json:"id"` // необязательно, по умолчанию строится из URL
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Content   string `json:"content"`
+	ScrapedAt string `json:"scraped_at"`
+}
+
+// ParseJSONL читает JSONL-файл загрузчика: по одной странице {"title", "url", "content", "scraped_at"} в строке.
+// ID документа строится из URL так же, как имя markdown-файла загрузчика, поэтому при смене формата
+// загрузки ID и кэш эмбеддингов сохраняются. Записи без URL нумеруются по строкам файла.
+func (p *MarkdownParser) ParseJSONL(filePath string) ([]types.Document, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	baseID := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+
+	var documents []types.Document
+	decoder := json.NewDecoder(file)
+	for row := 1; ; row++ {
+		var record jsonlRecord
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return documents, fmt.Errorf("ошибка чтения записи %d: %w", row, err)
+		}
+
+		if strings.TrimSpace(record.Title) == "" && strings.TrimSpace(record.Content) == "" {
+			continue
+		}
+
+		id := record.ID
+		switch {
+		case id != "":
+		case record.URL != "":
+			id = crawlutil.Filename(record.URL)
+		default:
+			id = fmt.Sprintf("%s-%d", baseID, row)
+		}
+
+		// Собираем документ в формате загрузчика, чтобы он прошел обычный конвейер
+		var markdown strings.Builder
+		markdown.WriteString("# " + strings.TrimSpace(record.Title) + "\n\n")
+		if record.URL != "" {
+			markdown.WriteString("**URL:** " + record.URL + "\n\n")
+		}
+		if record.ScrapedAt != "" {
+			markdown.WriteString("**ScrapedAt:** " + record.ScrapedAt + "\n\n")
+		}
+		markdown.WriteString(record.Content)
+
+		doc, err := p.process(types.Document{ID: id, Content: markdown.String()})
+		if err != nil {
+			return documents, fmt.Errorf("ошибка обработки записи %d: %w", row, err)
+		}
+		documents = append(documents, doc)
+	}
+
+	return documents, nil
+}
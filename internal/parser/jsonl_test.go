@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDirectoryJSONL(t *testing.T) {
+	dir := t.TempDir()
+	lines := []string{
+		`{"title":"Оплата счета","url":"https://nethouse.ru/about/instructions/pay","content":"Оплатите счет картой.","scraped_at":"2024-01-15T10:30:00Z"}`,
+		`{"title":"","url":"https://example.com/empty","content":""}`,
+		`{"title":"Без ссылки","content":"Текст без URL."}`,
+	}
+	if err := os.WriteFile(filepath.Join(dir, "documents.jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	documents, stats, err := NewMarkdownParser().ParseDirectory(dir)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if stats.Total != 1 {
+		t.Errorf("найдено файлов %d, ожидался 1", stats.Total)
+	}
+	if len(documents) != 2 {
+		t.Fatalf("документов %d, ожидалось 2 (пустая запись пропускается)", len(documents))
+	}
+
+	pay := documents[0]
+	if pay.ID != "pay" || pay.Title != "Оплата счета" || pay.URL != "https://nethouse.ru/about/instructions/pay" {
+		t.Errorf("документ: id %q, заголовок %q, ссылка %q", pay.ID, pay.Title, pay.URL)
+	}
+	if pay.Content != "Оплатите счет картой." {
+		t.Errorf("содержимое = %q", pay.Content)
+	}
+	if pay.CreatedAt.IsZero() {
+		t.Error("дата загрузки не разобрана")
+	}
+	// Запись без URL нумеруется по строке файла
+	if documents[1].ID != "documents-3" {
+		t.Errorf("id записи без ссылки = %q, ожидалось documents-3", documents[1].ID)
+	}
+}
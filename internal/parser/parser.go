@@ -2,42 +2,197 @@ package parser
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/ad/rag-bot/internal/types"
 )
 
-type MarkdownParser struct{}
+type MarkdownParser struct {
+	pipeline *ProcessorPipeline
+
+	UserAgent    string        // User-Agent для ParseURL
+	FetchTimeout time.Duration // таймаут загрузки страницы для ParseURL
+
+	ExtractCodeSnippets bool // сохранять фрагменты кода в Metadata["code_snippets"]
+
+	// Колонки CSV-файлов для ParseDirectory; .csv файлы пропускаются, если заголовок или текст не заданы
+	CSVTitleColumn     string
+	CSVContentColumn   string
+	CSVURLColumn       string // необязательная колонка со ссылкой
+	CSVMetadataColumns bool   // сохранять остальные колонки в Metadata
+
+	SkipHidden   bool             // пропускать файлы и папки, имя которых начинается с "." или "_"
+	SkipPatterns []*regexp.Regexp // пропускать пути (относительно папки ParseDirectory, через "/"), подходящие под шаблон
+
+	DeduplicateContent bool // пропускать .md файлы, текст которых совпадает с уже разобранным (одна статья по двум URL)
+
+	// SortFiles - разбирать файлы ParseDirectory в алфавитном порядке путей, а не в порядке обхода папки:
+	// документы получают одинаковые позиции в VectorStore на любой ОС, и снимки индекса переносимы
+	SortFiles bool
+
+	transformers     map[string]ContentTransformer // зарегистрированные преобразователи, см. RegisterTransformer
+	transformerNames []string                      // преобразователи, применяемые к документам
+}
 
 func NewMarkdownParser() *MarkdownParser {
-	return &MarkdownParser{}
+	return &MarkdownParser{
+		pipeline:     NewDefaultPipeline(),
+		UserAgent:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+		FetchTimeout: 30 * time.Second,
+		SkipHidden:   true,
+		transformers: map[string]ContentTransformer{
+			"strip_html":        StripHTMLTransformer{},
+			"normalize_unicode": NormalizeUnicodeTransformer{},
+		},
+		transformerNames: GetContentTransformers(),
+	}
 }
 
-func (p *MarkdownParser) ParseDirectory(dirPath string) ([]types.Document, error) {
+// Pipeline возвращает конвейер предобработки, чтобы можно было добавить свои шаги
+func (p *MarkdownParser) Pipeline() *ProcessorPipeline {
+	return p.pipeline
+}
+
+// ParseStats - итоги разбора папки документов
+type ParseStats struct {
+	Total            int // найдено файлов .md, .csv и .jsonl
+	Skipped          int // пропущено файлов: скрытые, по SkipPatterns, с ошибкой разбора, дубликаты, CSV без заданных колонок
+	ZeroContent      int // документов с пустым текстом
+	AverageWordCount int // среднее число слов в документе
+	TotalWordCount   int
+}
+
+func (p *MarkdownParser) ParseDirectory(dirPath string) ([]types.Document, ParseStats, error) {
 	var documents []types.Document
+	var stats ParseStats
+
+	seen := make(map[string]string) // хеш текста -> первый файл с таким текстом
+	duplicates := 0
 
+	var paths []string
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if filepath.Ext(path) == ".md" {
+		ext := filepath.Ext(path)
+		isDocument := !info.IsDir() && (ext == ".md" || ext == ".csv" || ext == ".jsonl")
+		if isDocument {
+			stats.Total++
+		}
+
+		if path != dirPath && p.shouldSkip(dirPath, path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			if isDocument {
+				stats.Skipped++
+			}
+			return nil
+		}
+
+		if isDocument {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+
+	if p.SortFiles {
+		sort.Strings(paths)
+	}
+
+	for _, path := range paths {
+		switch filepath.Ext(path) {
+		case ".md":
 			doc, err := p.ParseFile(path)
 			if err != nil {
 				fmt.Printf("Ошибка парсинга файла %s: %v\n", path, err)
-				return nil
+				stats.Skipped++
+				continue
+			}
+			if p.DeduplicateContent {
+				hash := sha256.Sum256([]byte(doc.Content))
+				key := hex.EncodeToString(hash[:])
+				if first, exists := seen[key]; exists {
+					fmt.Printf("Пропуск дубликата: %s совпадает с %s\n", path, first)
+					duplicates++
+					stats.Skipped++
+					continue
+				}
+				seen[key] = path
 			}
 			documents = append(documents, doc)
+		case ".csv":
+			if p.CSVTitleColumn == "" || p.CSVContentColumn == "" {
+				stats.Skipped++
+				continue
+			}
+			docs, err := p.ParseCSV(path, p.CSVTitleColumn, p.CSVContentColumn)
+			if err != nil {
+				fmt.Printf("Ошибка парсинга файла %s: %v\n", path, err)
+				stats.Skipped++
+				continue
+			}
+			documents = append(documents, docs...)
+		case ".jsonl":
+			// Записи до ошибки сохраняются: файл мог быть оборван на последней строке, если загрузчик прервали
+			docs, err := p.ParseJSONL(path)
+			if err != nil {
+				fmt.Printf("Ошибка парсинга файла %s: %v\n", path, err)
+			}
+			documents = append(documents, docs...)
 		}
+	}
 
-		return nil
-	})
+	if duplicates > 0 {
+		fmt.Printf("Пропущено дубликатов: %d\n", duplicates)
+	}
+
+	for _, doc := range documents {
+		words := len(strings.Fields(doc.Content))
+		if words == 0 {
+			stats.ZeroContent++
+		}
+		stats.TotalWordCount += words
+	}
+	if len(documents) > 0 {
+		stats.AverageWordCount = stats.TotalWordCount / len(documents)
+	}
+
+	return documents, stats, err
+}
+
+// shouldSkip проверяет, нужно ли пропустить файл или папку при обходе директории
+func (p *MarkdownParser) shouldSkip(root, path string) bool {
+	if name := filepath.Base(path); p.SkipHidden && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+		return true
+	}
+
+	if len(p.SkipPatterns) == 0 {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range p.SkipPatterns {
+		if pattern.MatchString(rel) {
+			return true
+		}
+	}
 
-	return documents, err
+	return false
 }
 
 func (p *MarkdownParser) ParseFile(filePath string) (types.Document, error) {
@@ -49,9 +204,7 @@ func (p *MarkdownParser) ParseFile(filePath string) (types.Document, error) {
 
 	scanner := bufio.NewScanner(file)
 
-	var title, url, content string
 	var lines []string
-
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
 	}
@@ -60,43 +213,40 @@ func (p *MarkdownParser) ParseFile(filePath string) (types.Document, error) {
 		return types.Document{}, err
 	}
 
-	// Парсим заголовок
-	for i, line := range lines {
-		if strings.HasPrefix(line, "# ") {
-			title = strings.TrimPrefix(line, "# ")
-			lines = lines[i+1:]
-			break
-		}
+	id := strings.TrimSuffix(filepath.Base(filePath), ".md")
+
+	return p.process(types.Document{
+		ID:      id,
+		Content: strings.Join(lines, "\n"),
+	})
+}
+
+// process прогоняет документ через конвейер и дополнительные шаги, включенные полями парсера
+func (p *MarkdownParser) process(doc types.Document) (types.Document, error) {
+	doc, err := p.pipeline.Run(doc)
+	if err != nil {
+		return doc, err
 	}
 
-	// Парсим URL
-	urlRegex := regexp.MustCompile(`\*\*URL:\*\*\s+(.+)`)
-	for i, line := range lines {
-		if match := urlRegex.FindStringSubmatch(line); len(match) > 1 {
-			url = strings.TrimSpace(match[1])
-			lines = lines[i+1:]
-			break
-		}
+	doc.Content, err = p.transform(doc.Content)
+	if err != nil {
+		return doc, fmt.Errorf("документ %s: %w", doc.ID, err)
 	}
 
-	content = strings.TrimSpace(strings.Join(lines, "\n"))
+	doc.ReadingTimeSeconds = ReadingTimeSeconds(doc.Content)
+	doc.SimHash = types.SimHash(doc.Content)
 
-	// Заменяем html-ссылки на markdown-ссылки
-	htmlLinkRegex := regexp.MustCompile(`<a\s+href="([^"]+)"[^>]*>(.*?)<\/a>`)
-	content = htmlLinkRegex.ReplaceAllStringFunc(content, func(s string) string {
-		matches := htmlLinkRegex.FindStringSubmatch(s)
-		if len(matches) == 3 {
-			return "[" + matches[2] + "](" + matches[1] + ")"
-		}
-		return s
-	})
+	if p.ExtractCodeSnippets {
+		return ExtractCodeSnippets(doc)
+	}
 
-	id := strings.TrimSuffix(filepath.Base(filePath), ".md")
+	return doc, nil
+}
 
-	return types.Document{
-		ID:      id,
-		Title:   title,
-		URL:     url,
-		Content: content,
-	}, nil
+// wordsPerMinute - средняя скорость чтения для расчета времени чтения статьи
+const wordsPerMinute = 200
+
+// ReadingTimeSeconds оценивает время чтения текста в секундах
+func ReadingTimeSeconds(content string) int {
+	return len(strings.Fields(content)) * 60 / wordsPerMinute
 }
@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// Processor - шаг предобработки документа
+type Processor func(types.Document) (types.Document, error)
+
+// ProcessorPipeline последовательно применяет шаги предобработки к документу
+type ProcessorPipeline struct {
+	processors []Processor
+}
+
+func NewProcessorPipeline(processors ...Processor) *ProcessorPipeline {
+	return &ProcessorPipeline{
+		processors: processors,
+	}
+}
+
+// AddProcessor добавляет шаг в конец конвейера
+func (pp *ProcessorPipeline) AddProcessor(fn func(types.Document) (types.Document, error)) {
+	pp.processors = append(pp.processors, fn)
+}
+
+// Run прогоняет документ через все шаги по порядку, останавливаясь на первой ошибке.
+// В ошибке указывается ID исходного документа: шаг с ошибкой может вернуть неполный документ.
+func (pp *ProcessorPipeline) Run(doc types.Document) (types.Document, error) {
+	id := doc.ID
+	for i, process := range pp.processors {
+		processed, err := process(doc)
+		if err != nil {
+			return types.Document{}, fmt.Errorf("ошибка шага %d предобработки документа %s: %w", i+1, id, err)
+		}
+		doc = processed
+	}
+
+	return doc, nil
+}
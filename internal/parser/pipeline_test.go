@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+func TestProcessorPipelineRun(t *testing.T) {
+	errStep := errors.New("сбой шага")
+	appendStep := func(suffix string) Processor {
+		return func(doc types.Document) (types.Document, error) {
+			doc.Content += suffix
+			return doc, nil
+		}
+	}
+	// Шаг с ошибкой возвращает пустой документ без ID
+	failingStep := func(types.Document) (types.Document, error) {
+		return types.Document{}, errStep
+	}
+
+	tests := []struct {
+		name        string
+		processors  []Processor
+		wantContent string
+		wantErr     string
+	}{
+		{"без шагов", nil, "текст", ""},
+		{"шаги по порядку", []Processor{appendStep(" 1"), appendStep(" 2")}, "текст 1 2", ""},
+		{"ошибка на втором шаге", []Processor{appendStep(" 1"), failingStep, appendStep(" 3")}, "", "ошибка шага 2 предобработки документа doc-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := NewProcessorPipeline(tt.processors...)
+			doc, err := pipeline.Run(types.Document{ID: "doc-1", Content: "текст"})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, errStep) {
+					t.Fatalf("ошибка = %v, ожидалась %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if doc.Content != tt.wantContent {
+				t.Errorf("содержимое = %q, ожидалось %q", doc.Content, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestDefaultPipeline(t *testing.T) {
+	content := strings.Join([]string{
+		"# Оплата счета",
+		"**URL:** https://example.com/pay",
+		"**Tags:** billing, счета",
+		"**ScrapedAt:** 2024-01-15T10:30:00Z",
+		"",
+		`Подробнее на <a href="https://example.com/help">странице помощи</a>.`,
+	}, "\n")
+
+	doc, err := NewDefaultPipeline().Run(types.Document{ID: "pay", Content: content})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	if doc.Title != "Оплата счета" || doc.URL != "https://example.com/pay" {
+		t.Errorf("заголовок %q, ссылка %q", doc.Title, doc.URL)
+	}
+	if strings.Join(doc.Tags, ",") != "billing,счета" {
+		t.Errorf("теги = %v", doc.Tags)
+	}
+	if doc.CreatedAt.Format("2006-01-02") != "2024-01-15" {
+		t.Errorf("CreatedAt = %v", doc.CreatedAt)
+	}
+	if want := "Подробнее на [странице помощи](https://example.com/help)."; doc.Content != want {
+		t.Errorf("содержимое = %q, ожидалось %q", doc.Content, want)
+	}
+	if len(doc.ExternalLinks) != 1 || doc.ExternalLinks[0] != "https://example.com/help" {
+		t.Errorf("внешние ссылки = %v", doc.ExternalLinks)
+	}
+}
+
+func TestExtractScrapedAt(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantCreated string // пусто - нулевое время
+	}{
+		{"корректная дата", "**ScrapedAt:** 2024-01-15T10:30:00Z\nТекст", "2024-01-15T10:30:00Z"},
+		{"некорректная дата", "**ScrapedAt:** 15.01.2024\nТекст", ""},
+		{"без даты", "Текст", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ExtractScrapedAt(types.Document{ID: "doc", Content: tt.content})
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if doc.Content != "Текст" {
+				t.Errorf("содержимое = %q, ожидалось %q", doc.Content, "Текст")
+			}
+
+			got := ""
+			if !doc.CreatedAt.IsZero() {
+				got = doc.CreatedAt.Format(time.RFC3339)
+			}
+			if got != tt.wantCreated {
+				t.Errorf("CreatedAt = %q, ожидалось %q", got, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestExtractSectionPath(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string // разделы через "/"
+	}{
+		{"вложенные разделы", "https://example.com/help/billing/invoice.html", "help/billing"},
+		{"закодированный раздел", "https://example.com/%D0%BE%D0%BF%D0%BB%D0%B0%D1%82%D0%B0/pay", "оплата"},
+		{"страница в корне", "https://example.com/pay", ""},
+		{"без ссылки", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ExtractSectionPath(types.Document{ID: "doc", URL: tt.url})
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if got := strings.Join(doc.SectionPath, "/"); got != tt.want {
+				t.Errorf("SectionPath = %v, ожидалось %q", doc.SectionPath, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+var (
+	urlRegex        = regexp.MustCompile(`\*\*URL:\*\*\s+(.+)`)
+	tagsRegex       = regexp.MustCompile(`^\*\*Tags:\*\*\s+(.+)`)
+	scrapedAtRegex  = regexp.MustCompile(`^\*\*ScrapedAt:\*\*\s+(\S+)`)
+	updatedAtRegex  = regexp.MustCompile(`^\*\*UpdatedAt:\*\*\s+(\S+)`)
+	codeBlockRegex  = regexp.MustCompile("(?s)```[a-zA-Z0-9_+-]*\n?(.*?)```")
+	inlineCodeRegex = regexp.MustCompile("`([^`\n]+)`")
+	htmlLinkRegex   = regexp.MustCompile(`<a\s+href="([^"]+)"[^>]*>(.*?)<\/a>`)
+	mdLinkRegex     = regexp.MustCompile(`(!?)\[[^\]]*\]\(([^)\s]+)[^)]*\)`)
+	htmlHrefRegex   = regexp.MustCompile(`<a\s[^>]*?href=["']([^"']+)["']`)
+
+	// Шаблоны персональных данных: российский мобильный проверяется раньше общего международного
+	piiRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`),                     // email
+		regexp.MustCompile(`(?:\+7|\b8)[\s\-]?\(?9\d{2}\)?[\s\-]?\d{3}[\s\-]?\d{2}[\s\-]?\d{2}\b`), // российский мобильный
+		regexp.MustCompile(`\+\d{1,3}[\s\-]?\(?\d{1,4}\)?(?:[\s\-]?\d{2,4}){2,4}\b`),               // международный телефон
+	}
+)
+
+const redactedPlaceholder = "[REDACTED]"
+
+// NewDefaultPipeline возвращает стандартный конвейер предобработки markdown-документов
+// Удаление персональных данных включается переменной окружения REDACT_PII=true
+func NewDefaultPipeline() *ProcessorPipeline {
+	pipeline := NewProcessorPipeline(
+		ExtractTitle,
+		ExtractURL,
+		ExtractSectionPath,
+		ExtractTags,
+		ExtractScrapedAt,
+		ExtractUpdatedAt,
+		TrimContent,
+		ExtractExternalLinks,
+		ConvertHTMLLinks,
+	)
+
+	if os.Getenv("REDACT_PII") == "true" {
+		pipeline.AddProcessor(RedactPII)
+	}
+
+	return pipeline
+}
+
+// ExtractTitle берёт заголовок из первой строки вида "# Заголовок" и удаляет её из содержимого
+func ExtractTitle(doc types.Document) (types.Document, error) {
+	lines := strings.Split(doc.Content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "# ") {
+			doc.Title = strings.TrimPrefix(line, "# ")
+			doc.Content = strings.Join(lines[i+1:], "\n")
+			break
+		}
+	}
+
+	return doc, nil
+}
+
+// ExtractURL берёт ссылку из строки вида "**URL:** ..." и удаляет её из содержимого
+func ExtractURL(doc types.Document) (types.Document, error) {
+	lines := strings.Split(doc.Content, "\n")
+	for i, line := range lines {
+		if match := urlRegex.FindStringSubmatch(line); len(match) > 1 {
+			doc.URL = strings.TrimSpace(match[1])
+			doc.Content = strings.Join(lines[i+1:], "\n")
+			break
+		}
+	}
+
+	return doc, nil
+}
+
+// ExtractSectionPath заполняет SectionPath папками пути URL документа: для
+// https://example.com/help/billing/invoice.html это ["help", "billing"]. Документ без URL пропускается.
+func ExtractSectionPath(doc types.Document) (types.Document, error) {
+	if doc.URL == "" {
+		return doc, nil
+	}
+	parsed, err := url.Parse(doc.URL)
+	if err != nil {
+		return doc, nil
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments) < 2 {
+		return doc, nil
+	}
+
+	doc.SectionPath = nil
+	for _, segment := range segments[:len(segments)-1] {
+		if segment, err := url.PathUnescape(segment); err == nil && segment != "" {
+			doc.SectionPath = append(doc.SectionPath, segment)
+		}
+	}
+	return doc, nil
+}
+
+// ExtractTags берёт теги из строки вида "**Tags:** billing, domains" и удаляет её из содержимого
+func ExtractTags(doc types.Document) (types.Document, error) {
+	lines := strings.Split(doc.Content, "\n")
+	for i, line := range lines {
+		if match := tagsRegex.FindStringSubmatch(strings.TrimSpace(line)); len(match) > 1 {
+			for _, tag := range strings.Split(match[1], ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					doc.Tags = append(doc.Tags, tag)
+				}
+			}
+			doc.Content = strings.Join(append(lines[:i:i], lines[i+1:]...), "\n")
+			break
+		}
+	}
+
+	return doc, nil
+}
+
+// ExtractScrapedAt берёт время загрузки страницы из строки вида "**ScrapedAt:** 2024-01-15T10:30:00Z"
+// (формат RFC3339) и удаляет её из содержимого. Некорректная дата не мешает загрузке документа:
+// она записывается в лог, а CreatedAt остается нулевым.
+func ExtractScrapedAt(doc types.Document) (types.Document, error) {
+	lines := strings.Split(doc.Content, "\n")
+	for i, line := range lines {
+		if match := scrapedAtRegex.FindStringSubmatch(strings.TrimSpace(line)); len(match) > 1 {
+			if createdAt, err := time.Parse(time.RFC3339, match[1]); err != nil {
+				fmt.Printf("Документ %s: некорректная дата ScrapedAt %q: %v\n", doc.ID, match[1], err)
+			} else {
+				doc.CreatedAt = createdAt
+			}
+			doc.Content = strings.Join(append(lines[:i:i], lines[i+1:]...), "\n")
+			break
+		}
+	}
+
+	return doc, nil
+}
+
+// ExtractUpdatedAt берёт время повторной загрузки страницы с изменившимся содержимым
+// из строки вида "**UpdatedAt:** 2024-02-01T08:00:00Z" (формат RFC3339) и удаляет её из содержимого
+func ExtractUpdatedAt(doc types.Document) (types.Document, error) {
+	lines := strings.Split(doc.Content, "\n")
+	for i, line := range lines {
+		if match := updatedAtRegex.FindStringSubmatch(strings.TrimSpace(line)); len(match) > 1 {
+			updatedAt, err := time.Parse(time.RFC3339, match[1])
+			if err != nil {
+				return doc, fmt.Errorf("некорректная дата UpdatedAt %q: %w", match[1], err)
+			}
+			doc.UpdatedAt = &updatedAt
+			doc.Content = strings.Join(append(lines[:i:i], lines[i+1:]...), "\n")
+			break
+		}
+	}
+
+	return doc, nil
+}
+
+// TrimContent убирает пробелы в начале и конце содержимого
+func TrimContent(doc types.Document) (types.Document, error) {
+	doc.Content = strings.TrimSpace(doc.Content)
+	return doc, nil
+}
+
+// ExtractExternalLinks собирает в ExternalLinks ссылки из текста: markdown [текст](url) и html <a href="url">.
+// Относительные ссылки разрешаются относительно URL документа; изображения, якоря на ту же страницу
+// и ссылки не по http(s) пропускаются, повторы убираются.
+func ExtractExternalLinks(doc types.Document) (types.Document, error) {
+	base, _ := url.Parse(doc.URL)
+
+	var raw []string
+	for _, match := range mdLinkRegex.FindAllStringSubmatch(doc.Content, -1) {
+		if match[1] != "!" {
+			raw = append(raw, match[2])
+		}
+	}
+	for _, match := range htmlHrefRegex.FindAllStringSubmatch(doc.Content, -1) {
+		raw = append(raw, match[1])
+	}
+
+	seen := map[string]bool{doc.URL: true}
+	for _, link := range raw {
+		parsed, err := url.Parse(strings.TrimSpace(link))
+		if err != nil {
+			continue
+		}
+		if base != nil {
+			parsed = base.ResolveReference(parsed)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			continue
+		}
+		parsed.Fragment = ""
+
+		if link := parsed.String(); !seen[link] {
+			seen[link] = true
+			doc.ExternalLinks = append(doc.ExternalLinks, link)
+		}
+	}
+
+	return doc, nil
+}
+
+// ConvertHTMLLinks заменяет html-ссылки на markdown-ссылки
+func ConvertHTMLLinks(doc types.Document) (types.Document, error) {
+	doc.Content = htmlLinkRegex.ReplaceAllStringFunc(doc.Content, func(s string) string {
+		matches := htmlLinkRegex.FindStringSubmatch(s)
+		if len(matches) == 3 {
+			return "[" + matches[2] + "](" + matches[1] + ")"
+		}
+		return s
+	})
+
+	return doc, nil
+}
+
+// RedactPII заменяет адреса электронной почты и номера телефонов в содержимом на [REDACTED]
+func RedactPII(doc types.Document) (types.Document, error) {
+	for _, re := range piiRegexes {
+		doc.Content = re.ReplaceAllString(doc.Content, redactedPlaceholder)
+	}
+
+	return doc, nil
+}
+
+// ExtractCodeSnippets сохраняет содержимое блоков ```код``` и инлайн-фрагментов `код`
+// в Metadata["code_snippets"] в виде JSON-массива строк
+func ExtractCodeSnippets(doc types.Document) (types.Document, error) {
+	var snippets []string
+
+	content := codeBlockRegex.ReplaceAllStringFunc(doc.Content, func(block string) string {
+		if match := codeBlockRegex.FindStringSubmatch(block); len(match) > 1 {
+			if snippet := strings.TrimSpace(match[1]); snippet != "" {
+				snippets = append(snippets, snippet)
+			}
+		}
+		return ""
+	})
+
+	for _, match := range inlineCodeRegex.FindAllStringSubmatch(content, -1) {
+		if snippet := strings.TrimSpace(match[1]); snippet != "" {
+			snippets = append(snippets, snippet)
+		}
+	}
+
+	if len(snippets) == 0 {
+		return doc, nil
+	}
+
+	data, err := json.Marshal(snippets)
+	if err != nil {
+		return doc, fmt.Errorf("ошибка сериализации фрагментов кода: %w", err)
+	}
+
+	if doc.Metadata == nil {
+		doc.Metadata = make(map[string]string)
+	}
+	doc.Metadata["code_snippets"] = string(data)
+
+	return doc, nil
+}
+
+// CodeSnippets возвращает фрагменты кода, сохраненные ExtractCodeSnippets
+func CodeSnippets(doc types.Document) []string {
+	var snippets []string
+	if data, ok := doc.Metadata["code_snippets"]; ok {
+		_ = json.Unmarshal([]byte(data), &snippets)
+	}
+	return snippets
+}
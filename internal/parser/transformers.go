@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ContentTransformer преобразует текст документа после конвейера предобработки
+// (например, удаляет юридические оговорки или обезличивает имена пользователей)
+type ContentTransformer interface {
+	Transform(content string) (string, error)
+}
+
+// ContentTransformerFunc позволяет использовать обычную функцию как ContentTransformer
+type ContentTransformerFunc func(content string) (string, error)
+
+func (f ContentTransformerFunc) Transform(content string) (string, error) {
+	return f(content)
+}
+
+// GetContentTransformers возвращает имена преобразователей из CONTENT_TRANSFORMERS (через запятую, в порядке применения)
+func GetContentTransformers() []string {
+	var names []string
+	for _, name := range strings.Split(os.Getenv("CONTENT_TRANSFORMERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var (
+	htmlTagRegex    = regexp.MustCompile(`<[^>]*>`)
+	blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+)
+
+// StripHTMLTransformer удаляет HTML-теги, оставшиеся в тексте, и раскрывает HTML-сущности (&nbsp;, &amp;)
+type StripHTMLTransformer struct{}
+
+func (StripHTMLTransformer) Transform(content string) (string, error) {
+	content = htmlTagRegex.ReplaceAllString(content, "")
+	content = html.UnescapeString(content)
+	return strings.TrimSpace(blankLinesRegex.ReplaceAllString(content, "\n\n")), nil
+}
+
+// NormalizeUnicodeTransformer приводит текст к форме NFC и заменяет неразрывные и нулевой ширины пробелы,
+// чтобы одинаковые слова с разной кодировкой давали одинаковые эмбеддинги и совпадения по ключевым словам
+type NormalizeUnicodeTransformer struct{}
+
+var unicodeSpaceReplacer = strings.NewReplacer(
+	"\u00a0", " ", // неразрывный пробел
+	"\u202f", " ", // узкий неразрывный пробел
+	"\u200b", "", // пробел нулевой ширины
+	"\ufeff", "", // BOM
+)
+
+func (NormalizeUnicodeTransformer) Transform(content string) (string, error) {
+	return unicodeSpaceReplacer.Replace(norm.NFC.String(content)), nil
+}
+
+// RegisterTransformer добавляет преобразователь под именем name (для CONTENT_TRANSFORMERS и UseTransformers).
+// Встроенные преобразователи strip_html и normalize_unicode зарегистрированы заранее.
+func (p *MarkdownParser) RegisterTransformer(name string, t ContentTransformer) {
+	p.transformers[name] = t
+}
+
+// UseTransformers задает преобразователи, которые применяются к каждому документу в указанном порядке.
+// По умолчанию используется список из CONTENT_TRANSFORMERS.
+func (p *MarkdownParser) UseTransformers(names ...string) error {
+	p.transformerNames = names
+	return p.CheckTransformers()
+}
+
+// CheckTransformers проверяет, что все выбранные преобразователи зарегистрированы.
+// Вызывается после регистрации своих преобразователей, чтобы опечатка в CONTENT_TRANSFORMERS
+// обнаружилась при запуске, а не ошибкой разбора каждого файла.
+func (p *MarkdownParser) CheckTransformers() error {
+	for _, name := range p.transformerNames {
+		if _, ok := p.transformers[name]; !ok {
+			return fmt.Errorf("неизвестный преобразователь содержимого: %s", name)
+		}
+	}
+	return nil
+}
+
+// transform применяет выбранные преобразователи к тексту
+func (p *MarkdownParser) transform(content string) (string, error) {
+	for _, name := range p.transformerNames {
+		t, ok := p.transformers[name]
+		if !ok {
+			return "", fmt.Errorf("неизвестный преобразователь содержимого: %s", name)
+		}
+
+		var err error
+		content, err = t.Transform(content)
+		if err != nil {
+			return "", fmt.Errorf("ошибка преобразователя %s: %w", name, err)
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+var invalidIDChars = regexp.MustCompile(`[^\p{L}\p{N}_\-]+`)
+
+// ScraperConfig - настройки загрузки отдельных страниц (команда /ingest_url)
+type ScraperConfig struct {
+	ContentSelector string   // CSS-селектор основного содержимого страницы
+	AllowedPrefixes []string // загружать можно только URL, начинающиеся с одного из префиксов
+}
+
+// GetScraperConfig читает SCRAPER_CONTENT_SELECTOR (по умолчанию div.help-article__main, как у загрузчика)
+// и SCRAPER_ALLOWED_PREFIXES через запятую (по умолчанию https://nethouse.ru/)
+func GetScraperConfig() ScraperConfig {
+	config := ScraperConfig{
+		ContentSelector: os.Getenv("SCRAPER_CONTENT_SELECTOR"),
+	}
+	if config.ContentSelector == "" {
+		config.ContentSelector = "div.help-article__main"
+	}
+
+	for _, prefix := range strings.Split(os.Getenv("SCRAPER_ALLOWED_PREFIXES"), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			config.AllowedPrefixes = append(config.AllowedPrefixes, prefix)
+		}
+	}
+	if len(config.AllowedPrefixes) == 0 {
+		config.AllowedPrefixes = []string{"https://nethouse.ru/"}
+	}
+
+	return config
+}
+
+// IsAllowed проверяет, что URL начинается с одного из разрешенных префиксов
+func (c ScraperConfig) IsAllowed(pageURL string) bool {
+	for _, prefix := range c.AllowedPrefixes {
+		if strings.HasPrefix(pageURL, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseURL скачивает страницу, извлекает содержимое по CSS-селектору и преобразует его в документ.
+// Страница приводится к тому же markdown-формату, что сохраняет загрузчик, и проходит тот же конвейер предобработки.
+func (p *MarkdownParser) ParseURL(pageURL string, selector string) (types.Document, error) {
+	markdown, err := p.FetchMarkdown(pageURL, selector)
+	if err != nil {
+		return types.Document{}, err
+	}
+
+	return p.process(types.Document{
+		ID:      DocumentIDFromURL(pageURL),
+		Content: markdown,
+	})
+}
+
+// FetchMarkdown скачивает страницу и возвращает её в markdown-формате загрузчика:
+// заголовок, строки **URL:** и **ScrapedAt:**, затем содержимое
+func (p *MarkdownParser) FetchMarkdown(pageURL string, selector string) (string, error) {
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil || parsedURL.Host == "" {
+		return "", fmt.Errorf("некорректный URL: %s", pageURL)
+	}
+
+	client := &http.Client{Timeout: p.FetchTimeout}
+
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP ошибка: %d", resp.StatusCode)
+	}
+
+	page, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ошибка разбора HTML: %w", err)
+	}
+
+	title := strings.TrimSpace(page.Find("h1").First().Text())
+	if title == "" {
+		title = strings.TrimSpace(page.Find("title").First().Text())
+	}
+
+	selection := page.Find(selector)
+	if selection.Length() == 0 {
+		return "", fmt.Errorf("на странице не найдено содержимое по селектору %q", selector)
+	}
+
+	var html strings.Builder
+	selection.Each(func(_ int, s *goquery.Selection) {
+		if outer, err := goquery.OuterHtml(s); err == nil {
+			html.WriteString(outer)
+		}
+	})
+
+	converter := md.NewConverter(parsedURL.Host, true, nil)
+	content, err := converter.ConvertString(html.String())
+	if err != nil {
+		return "", fmt.Errorf("ошибка преобразования HTML в markdown: %w", err)
+	}
+
+	return fmt.Sprintf("# %s\n\n**URL:** %s\n\n**ScrapedAt:** %s\n\n%s\n",
+		title, pageURL, time.Now().UTC().Format(time.RFC3339), strings.TrimSpace(content)), nil
+}
+
+// DocumentIDFromURL формирует ID документа из пути URL, аналогично именам файлов загрузчика
+func DocumentIDFromURL(pageURL string) string {
+	id := pageURL
+	if parsedURL, err := url.Parse(pageURL); err == nil && parsedURL.Path != "" {
+		id = parsedURL.Path
+	}
+
+	id = invalidIDChars.ReplaceAllString(strings.Trim(id, "/"), "_")
+	if id == "" || id == "_" {
+		id = "page"
+	}
+
+	return id
+}
@@ -0,0 +1,118 @@
+package querylog
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// topQueriesTTL - как долго кэшируется результат TopQueries
+const topQueriesTTL = 5 * time.Minute
+
+// GetQueryLogPath возвращает путь к базе SQLite журнала запросов (QUERY_LOG_PATH); пустая строка - журнал выключен
+func GetQueryLogPath() string {
+	return os.Getenv("QUERY_LOG_PATH")
+}
+
+// QueryCount - сколько раз задавался вопрос с данной сутью
+type QueryCount struct {
+	Essence string
+	Count   int
+}
+
+// QueryLog хранит запросы пользователей в SQLite для анализа популярных тем
+type QueryLog struct {
+	db *sql.DB
+
+	mu          sync.Mutex
+	top         []QueryCount
+	topLimit    int
+	topCachedAt time.Time
+}
+
+// Open открывает (или создает) базу журнала запросов
+func Open(path string) (*QueryLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("ошибка создания папки журнала запросов: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия журнала запросов: %w", err)
+	}
+
+	// SQLite не поддерживает параллельную запись, одно соединение избавляет от SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS queries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		query TEXT NOT NULL,
+		essence TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS queries_essence ON queries (essence);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка создания таблицы журнала запросов: %w", err)
+	}
+
+	return &QueryLog{db: db}, nil
+}
+
+// Record сохраняет запрос пользователя. Суть приводится к нижнему регистру,
+// чтобы перефразированные вопросы с одинаковой сутью группировались вместе.
+func (l *QueryLog) Record(userID int64, query, essence string) error {
+	_, err := l.db.Exec(`INSERT INTO queries (user_id, query, essence, created_at) VALUES (?, ?, ?, ?)`,
+		userID, query, strings.ToLower(strings.TrimSpace(essence)), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал запросов: %w", err)
+	}
+	return nil
+}
+
+// TopQueries возвращает limit самых частых вопросов (по сути запроса).
+// Результат кэшируется на 5 минут, чтобы не нагружать SQLite при каждом вызове.
+func (l *QueryLog) TopQueries(limit int) ([]QueryCount, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.top != nil && l.topLimit == limit && time.Since(l.topCachedAt) < topQueriesTTL {
+		return l.top, nil
+	}
+
+	rows, err := l.db.Query(`SELECT essence, COUNT(*) AS count FROM queries GROUP BY essence ORDER BY count DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения журнала запросов: %w", err)
+	}
+	defer rows.Close()
+
+	top := make([]QueryCount, 0, limit)
+	for rows.Next() {
+		var item QueryCount
+		if err := rows.Scan(&item.Essence, &item.Count); err != nil {
+			return nil, fmt.Errorf("ошибка чтения журнала запросов: %w", err)
+		}
+		top = append(top, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения журнала запросов: %w", err)
+	}
+
+	l.top = top
+	l.topLimit = limit
+	l.topCachedAt = time.Now()
+
+	return top, nil
+}
+
+// Close закрывает базу
+func (l *QueryLog) Close() error {
+	return l.db.Close()
+}
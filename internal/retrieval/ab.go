@@ -0,0 +1,152 @@
+package retrieval
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// GetABRetrievalMode возвращает режим поиска для стратегии B A/B-теста (RETRIEVAL_AB_MODE);
+// пустая строка - A/B-тест выключен
+func GetABRetrievalMode() string {
+	return os.Getenv("RETRIEVAL_AB_MODE")
+}
+
+// abLastQueryTTL - сколько хранится последний запрос пользователя, к которому может относиться оценка
+const abLastQueryTTL = 24 * time.Hour
+
+// abLastQuery - последний запрос пользователя и время, когда он задан
+type abLastQuery struct {
+	query string
+	at    time.Time
+}
+
+// ABStrategyStats - статистика одной стратегии A/B-теста
+type ABStrategyStats struct {
+	Strategy string  `json:"strategy"`
+	Name     string  `json:"name"`
+	Queries  int     `json:"queries"`
+	Positive int     `json:"positive"`
+	Negative int     `json:"negative"`
+	Score    float64 `json:"score"` // средняя оценка от -1 до 1; 0, если оценок нет
+}
+
+// ABRetrieval сравнивает две стратегии поиска на реальных запросах.
+// Стратегия выбирается по ID пользователя (userID % 2): четные получают A, нечетные - B,
+// поэтому пользователь всегда видит ответы одной стратегии и его оценки относятся к ней.
+// Запросы без пользователя (HTTP API) выполняются стратегией A и в статистику не попадают.
+type ABRetrieval struct {
+	a, b         RetrievalEngine
+	nameA, nameB string
+
+	mu        sync.Mutex
+	stats     [2]ABStrategyStats
+	lastQuery map[int64]abLastQuery // последний запрос пользователя, к которому относится оценка
+	lastSweep time.Time             // когда из lastQuery последний раз удалялись старые запросы
+}
+
+func NewABRetrieval(a RetrievalEngine, nameA string, b RetrievalEngine, nameB string) *ABRetrieval {
+	return &ABRetrieval{
+		a:     a,
+		b:     b,
+		nameA: nameA,
+		nameB: nameB,
+		stats: [2]ABStrategyStats{
+			{Strategy: "A", Name: nameA},
+			{Strategy: "B", Name: nameB},
+		},
+		lastQuery: make(map[int64]abLastQuery),
+	}
+}
+
+// strategy возвращает индекс стратегии пользователя (0 - A, 1 - B)
+func strategy(userID int64) int {
+	if userID%2 == 0 {
+		return 0
+	}
+	return 1
+}
+
+// choose выбирает стратегию для запроса пользователя и записывает его в статистику
+func (ab *ABRetrieval) choose(userID int64, query string) RetrievalEngine {
+	if userID == 0 {
+		return ab.a
+	}
+
+	index := strategy(userID)
+
+	ab.mu.Lock()
+	ab.stats[index].Queries++
+	ab.lastQuery[userID] = abLastQuery{query: query, at: time.Now()}
+	if time.Since(ab.lastSweep) > abLastQueryTTL {
+		ab.sweep()
+	}
+	ab.mu.Unlock()
+
+	log.Printf("A/B: пользователь id%d, стратегия %s (%s), запрос: %s", userID, ab.stats[index].Strategy, ab.stats[index].Name, query)
+
+	if index == 0 {
+		return ab.a
+	}
+	return ab.b
+}
+
+// sweep удаляет запросы пользователей, не задававших вопросов дольше abLastQueryTTL. Вызывается под ab.mu.
+func (ab *ABRetrieval) sweep() {
+	for userID, last := range ab.lastQuery {
+		if time.Since(last.at) > abLastQueryTTL {
+			delete(ab.lastQuery, userID)
+		}
+	}
+	ab.lastSweep = time.Now()
+}
+
+// FindRelevantDocuments выполняется без пользователя: стратегия A, без учета в статистике
+func (ab *ABRetrieval) FindRelevantDocuments(query string, limit int) ([]types.Document, error) {
+	return ab.choose(0, query).FindRelevantDocuments(query, limit)
+}
+
+// FindWithContext выбирает стратегию по opts.UserID
+func (ab *ABRetrieval) FindWithContext(ctx context.Context, query string, opts SearchOptions, limit int) ([]types.Document, error) {
+	return ab.choose(opts.UserID, query).FindWithContext(ctx, query, opts, limit)
+}
+
+// RecordFeedback учитывает оценку ответа пользователю: положительная rating - полезный ответ, отрицательная - нет
+func (ab *ABRetrieval) RecordFeedback(userID int64, rating int) {
+	index := strategy(userID)
+
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	stats := &ab.stats[index]
+	switch {
+	case rating > 0:
+		stats.Positive++
+	case rating < 0:
+		stats.Negative++
+	default:
+		return
+	}
+
+	log.Printf("A/B: пользователь id%d, стратегия %s (%s), оценка %d, запрос: %s",
+		userID, stats.Strategy, stats.Name, rating, ab.lastQuery[userID].query)
+}
+
+// Report возвращает статистику обеих стратегий
+func (ab *ABRetrieval) Report() []ABStrategyStats {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	report := make([]ABStrategyStats, 0, len(ab.stats))
+	for _, stats := range ab.stats {
+		if rated := stats.Positive + stats.Negative; rated > 0 {
+			stats.Score = float64(stats.Positive-stats.Negative) / float64(rated)
+		}
+		report = append(report, stats)
+	}
+	return report
+}
@@ -0,0 +1,70 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// explainSnippetLength - сколько символов текста документа передается LLM для объяснения
+const explainSnippetLength = 200
+
+// IsExplainEnabled сообщает, включены ли объяснения результатов поиска (EXPLAIN_RETRIEVAL=true).
+// Объяснение требует отдельного вызова LLM на каждый найденный документ.
+func IsExplainEnabled() bool {
+	return os.Getenv("EXPLAIN_RETRIEVAL") == "true"
+}
+
+// ExplainedResult - найденный документ с объяснением, почему он подходит к запросу
+type ExplainedResult struct {
+	types.Document
+	Reason string
+}
+
+// FindWithExplanation ищет документы по запросу и фильтрам (см. SearchOptions.Filters) и, если включен
+// EXPLAIN_RETRIEVAL, добавляет к каждому одно предложение от LLM о том, почему документ релевантен запросу
+func (vr *VectorRetrieval) FindWithExplanation(ctx context.Context, query string, filters map[string]string, limit int) ([]ExplainedResult, error) {
+	documents, err := vr.findShared(ctx, query, filters, nil, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ExplainedResult, len(documents))
+	for i, doc := range documents {
+		results[i] = ExplainedResult{Document: doc}
+		if !IsExplainEnabled() {
+			continue
+		}
+
+		reason, err := vr.explain(ctx, query, doc)
+		if err != nil {
+			log.Printf("Ошибка объяснения результата %s: %v", doc.ID, err)
+			continue
+		}
+		results[i].Reason = reason
+	}
+
+	return results, nil
+}
+
+func (vr *VectorRetrieval) explain(ctx context.Context, query string, doc types.Document) (string, error) {
+	content := []rune(doc.Content)
+	if len(content) > explainSnippetLength {
+		content = content[:explainSnippetLength]
+	}
+
+	prompt := fmt.Sprintf("Одним предложением объясни, почему этот документ относится к запросу '%s': %s - %s", query, doc.Title, string(content))
+	resp, err := vr.llmEngine.GenerateResponse(ctx, prompt, map[string]interface{}{
+		"temperature": 0.1,
+		"num_predict": 80,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(resp), nil
+}
@@ -0,0 +1,51 @@
+package retrieval
+
+import (
+	"context"
+	"os"
+
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// IsGraphExpansionEnabled сообщает, включено ли расширение выдачи по ссылкам (RETRIEVAL_GRAPH_EXPANSION=true)
+func IsGraphExpansionEnabled() bool {
+	return os.Getenv("RETRIEVAL_GRAPH_EXPANSION") == "true"
+}
+
+// maxLinkedDocuments - сколько связанных документов добавляет расширение выдачи по ссылкам
+const maxLinkedDocuments = 2
+
+// expandByLinks добавляет к результатам поиска документы, на которые ссылается лучший результат
+// (types.Document.ExternalLinks). Связанные документы оцениваются тем же эмбеддингом запроса:
+// в выдачу после основных результатов попадают не больше maxLinkedDocuments прошедших порог сходства.
+func expandByLinks(ctx context.Context, store *vectorstore.VectorStore, queryEmbedding []float32,
+	results []vectorstore.SearchResult) []vectorstore.SearchResult {
+	if len(results) == 0 || len(results[0].Document.ExternalLinks) == 0 {
+		return results
+	}
+
+	linked := make(map[string]bool, len(results[0].Document.ExternalLinks))
+	for _, link := range results[0].Document.ExternalLinks {
+		linked[link] = true
+	}
+	found := make(map[string]bool, len(results))
+	for _, result := range results {
+		found[result.Document.ID] = true
+	}
+
+	neighbours := store.Filter(func(doc types.Document) bool {
+		return linked[doc.URL] && !found[doc.ID]
+	})
+	if neighbours.GetDocumentCount() == 0 {
+		return results
+	}
+
+	// Ошибка означает, что ни один связанный документ не прошел порог сходства
+	linkedResults, err := neighbours.SearchWithBoost(ctx, queryEmbedding, nil, maxLinkedDocuments)
+	if err != nil {
+		return results
+	}
+
+	return append(results, linkedResults...)
+}
@@ -0,0 +1,63 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// historyTurns - сколько последних реплик диалога добавляется к запросу
+const historyTurns = 2
+
+// QueryWithHistory добавляет к запросу последние реплики диалога, чтобы уточняющий вопрос
+// ("А возврат?") получил эмбеддинг с учетом темы разговора
+func QueryWithHistory(query string, history []string) string {
+	if len(history) > historyTurns {
+		history = history[len(history)-historyTurns:]
+	}
+
+	parts := make([]string, 0, len(history)+1)
+	for _, turn := range history {
+		if turn = strings.TrimSpace(turn); turn != "" {
+			parts = append(parts, turn)
+		}
+	}
+	parts = append(parts, query)
+
+	return strings.Join(parts, " ")
+}
+
+// searchQuery добавляет к запросу историю диалога (QueryWithHistory). Пустой запрос с фильтрами
+// остается пустым: по нему возвращаются отфильтрованные документы без векторного поиска.
+func searchQuery(query string, opts SearchOptions) string {
+	if len(opts.Filters) > 0 && strings.TrimSpace(query) == "" {
+		return ""
+	}
+	return QueryWithHistory(query, opts.History)
+}
+
+func (vr *VectorRetrieval) FindWithContext(ctx context.Context, query string, opts SearchOptions, limit int) ([]types.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vr.findShared(ctx, searchQuery(query, opts), opts.Filters, opts.BoostDocIDs, limit)
+}
+
+func (hr *HybridRetrieval) FindWithContext(ctx context.Context, query string, opts SearchOptions, limit int) ([]types.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return hr.findRelevantDocuments(ctx, searchQuery(query, opts), opts.Filters, opts.BoostDocIDs, limit)
+}
+
+func (qr *QdrantRetrieval) FindWithContext(ctx context.Context, query string, opts SearchOptions, limit int) ([]types.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(opts.Filters) > 0 {
+		return nil, fmt.Errorf("%w: фильтры field:value не поддерживаются при поиске в Qdrant", ErrInvalidQuery)
+	}
+	return qr.findRelevantDocuments(ctx, QueryWithHistory(query, opts.History), limit)
+}
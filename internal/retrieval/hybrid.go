@@ -0,0 +1,128 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// Минимальная длина слова запроса для поиска по точному вхождению
+const minKeywordLength = 3
+
+// defaultHybridRadius - минимальное косинусное сходство кандидата векторного поиска в гибридном режиме
+const defaultHybridRadius float32 = 0.3
+
+// HybridRetrieval объединяет векторный поиск с поиском по точному вхождению слов запроса.
+// Поиск по ключевым словам помогает находить документы по редким токенам (коды, названия функций),
+// которые слабо влияют на эмбеддинг.
+type HybridRetrieval struct {
+	vectorStore   *vectorstore.VectorStore
+	llmEngine     llm.LLMEngine
+	KeywordWeight float32 // вес нормированного скора поиска по ключевым словам
+	Radius        float32 // порог сходства кандидатов векторного поиска (см. VectorStore.SearchRadius)
+}
+
+func NewHybridRetrieval(vs *vectorstore.VectorStore, llm llm.LLMEngine) *HybridRetrieval {
+	return &HybridRetrieval{
+		vectorStore:   vs,
+		llmEngine:     llm,
+		KeywordWeight: 0.3,
+		Radius:        defaultHybridRadius,
+	}
+}
+
+// FindRelevantDocuments поддерживает фильтры field:value, как VectorRetrieval.FindRelevantDocuments
+func (hr *HybridRetrieval) FindRelevantDocuments(query string, limit int) ([]types.Document, error) {
+	freeText, filters, err := ParseStructuredQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return hr.findRelevantDocuments(context.Background(), freeText, filters, nil, limit)
+}
+
+func (hr *HybridRetrieval) findRelevantDocuments(ctx context.Context, query string, filters map[string]string, boostDocIDs []string, limit int) ([]types.Document, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	candidates := limit * 2
+
+	store := filterStore(hr.vectorStore, filters)
+	if len(filters) > 0 && strings.TrimSpace(query) == "" {
+		return firstDocuments(store, limit), nil
+	}
+
+	scores := make(map[string]float32)
+	documents := make(map[string]types.Document)
+
+	// Сигнал 1: векторная близость
+	queryEmbedding, err := hr.vectorStore.QueryEmbedding(ctx, query, hr.llmEngine)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации эмбеддинга для запроса: %w", err)
+	}
+
+	// Векторный поиск отдает всех кандидатов в радиусе, окончательный топ-K определяется вместе с ключевыми словами
+	vectorResults, vectorErr := store.SearchRadiusWithBoost(ctx, queryEmbedding, boostDocIDs, hr.Radius)
+	for _, result := range vectorResults {
+		scores[result.Document.ID] += result.Score
+		documents[result.Document.ID] = result.Document
+	}
+
+	// Сигнал 2: точное вхождение слов запроса
+	keywordScores := make(map[string]float32)
+	var maxKeywordScore float32
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Trim(word, ".,!?;:\"'()«»")
+		if utf8.RuneCountInString(word) < minKeywordLength {
+			continue
+		}
+
+		results, err := store.SearchByKeyword(word, candidates)
+		if err != nil {
+			continue
+		}
+
+		for _, result := range results {
+			keywordScores[result.Document.ID] += result.Score
+			documents[result.Document.ID] = result.Document
+			if keywordScores[result.Document.ID] > maxKeywordScore {
+				maxKeywordScore = keywordScores[result.Document.ID]
+			}
+		}
+	}
+
+	for id, score := range keywordScores {
+		scores[id] += hr.KeywordWeight * score / maxKeywordScore
+	}
+
+	if len(scores) == 0 {
+		if vectorErr != nil {
+			return nil, fmt.Errorf("ошибка векторного поиска: %w", vectorErr)
+		}
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return scores[ids[i]] > scores[ids[j]]
+	})
+
+	if limit > len(ids) {
+		limit = len(ids)
+	}
+
+	result := make([]types.Document, 0, limit)
+	for _, id := range ids[:limit] {
+		result = append(result, documents[id])
+	}
+
+	return result, nil
+}
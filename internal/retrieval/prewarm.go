@@ -0,0 +1,78 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ClockTime - время суток в расписании прогрева
+type ClockTime struct {
+	Hour, Minute int
+}
+
+// GetPrewarmSchedule возвращает время прогрева эмбеддингов из PREWARM_SCHEDULE ("08:45" или "08:45,13:30");
+// пустой список - прогрев по расписанию выключен
+func GetPrewarmSchedule() ([]ClockTime, error) {
+	value := strings.TrimSpace(os.Getenv("PREWARM_SCHEDULE"))
+	if value == "" {
+		return nil, nil
+	}
+
+	var schedule []ClockTime
+	for _, item := range strings.Split(value, ",") {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("некорректное время %q в PREWARM_SCHEDULE (ожидается ЧЧ:ММ): %w", item, err)
+		}
+		schedule = append(schedule, ClockTime{Hour: parsed.Hour(), Minute: parsed.Minute()})
+	}
+	return schedule, nil
+}
+
+// NextPrewarm возвращает ближайшее после now время из расписания (в часовом поясе now)
+func NextPrewarm(now time.Time, schedule []ClockTime) time.Time {
+	var next time.Time
+	for _, clock := range schedule {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour, clock.Minute, 0, 0, now.Location())
+		if !at.After(now) {
+			at = time.Date(now.Year(), now.Month(), now.Day()+1, clock.Hour, clock.Minute, 0, 0, now.Location())
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return next
+}
+
+// PrewarmEmbeddings заранее генерирует и кэширует эмбеддинги ожидаемых запросов (например, перед утренним
+// пиком обращений), чтобы первые пользователи не ждали модель эмбеддингов. Запросы проходят через
+// QueryRewriter так же, как при поиске, затем прогреваются vectorstore.WarmSearchCache.
+// Возвращает число новых эмбеддингов.
+func (vr *VectorRetrieval) PrewarmEmbeddings(ctx context.Context, queries []string) int {
+	var missing []string
+	for _, query := range queries {
+		if ctx.Err() != nil {
+			break
+		}
+		if strings.TrimSpace(query) == "" {
+			continue
+		}
+
+		if vr.QueryRewriter != nil {
+			if rewritten, err := vr.QueryRewriter(ctx, query); err == nil {
+				query = rewritten
+			}
+		}
+		if !vr.vectorStore.HasQueryEmbedding(query) {
+			missing = append(missing, query)
+		}
+	}
+
+	added := vr.vectorStore.WarmSearchCache(ctx, missing, vr.llmEngine)
+	log.Printf("Прогрев эмбеддингов запросов: %d новых из %d", added, len(queries))
+	return added
+}
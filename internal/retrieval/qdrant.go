@@ -0,0 +1,69 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// GetQdrantURL возвращает адрес HTTP API Qdrant
+func GetQdrantURL() string {
+	if endpoint := os.Getenv("QDRANT_URL"); endpoint != "" {
+		return endpoint
+	}
+	return "http://localhost:6333"
+}
+
+// GetQdrantCollection возвращает имя коллекции документов в Qdrant
+func GetQdrantCollection() string {
+	if collection := os.Getenv("QDRANT_COLLECTION"); collection != "" {
+		return collection
+	}
+	return "rag-bot"
+}
+
+// QdrantRetrieval ищет документы в Qdrant вместо хранилища в памяти
+type QdrantRetrieval struct {
+	client     *vectorstore.QdrantClient
+	collection string
+	llmEngine  llm.LLMEngine
+}
+
+func NewQdrantRetrieval(endpoint, collection string, llm llm.LLMEngine) *QdrantRetrieval {
+	return &QdrantRetrieval{
+		client:     vectorstore.NewQdrantClient(endpoint),
+		collection: collection,
+		llmEngine:  llm,
+	}
+}
+
+func (qr *QdrantRetrieval) FindRelevantDocuments(query string, limit int) ([]types.Document, error) {
+	return qr.findRelevantDocuments(context.Background(), query, limit)
+}
+
+func (qr *QdrantRetrieval) findRelevantDocuments(ctx context.Context, query string, limit int) ([]types.Document, error) {
+	queryEmbedding, err := qr.llmEngine.GenerateEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации эмбеддинга для запроса: %w", err)
+	}
+
+	if limit <= 0 {
+		limit = 5
+	}
+
+	results, err := qr.client.Search(qr.collection, queryEmbedding, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска в Qdrant: %w", err)
+	}
+
+	var documents []types.Document
+	for _, result := range results {
+		documents = append(documents, result.Document)
+	}
+
+	return documents, nil
+}
@@ -0,0 +1,152 @@
+package retrieval
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+// Поля, поддерживаемые в запросах вида "tag:billing title:invoice"
+var structuredQueryFields = map[string]bool{
+	"tag":   true,
+	"title": true,
+	"url":   true,
+	"id":    true,
+}
+
+// ErrInvalidQuery - синтаксическая ошибка в запросе с фильтрами
+var ErrInvalidQuery = errors.New("некорректный запрос")
+
+// ParseStructuredQuery выделяет из запроса фильтры вида field:value (значение можно взять в кавычки:
+// title:"счет на оплату"). Токены с неизвестными полями остаются в тексте запроса.
+// Незакрытая кавычка и фильтр с пустым значением - ошибка ErrInvalidQuery.
+// Разбирать нужно исходный текст пользователя: выжимка LLM (ExtractEssence) переформулирует запрос и теряет фильтры.
+func ParseStructuredQuery(input string) (freeText string, filters map[string]string, err error) {
+	tokens, err := splitQueryTokens(input)
+	if err != nil {
+		return "", nil, err
+	}
+
+	filters = make(map[string]string)
+	var words []string
+	for _, token := range tokens {
+		field, value, found := strings.Cut(token, ":")
+		field = strings.ToLower(field)
+		if !found || !structuredQueryFields[field] {
+			words = append(words, token)
+			continue
+		}
+
+		value = strings.Trim(value, `"`)
+		if value == "" {
+			return "", nil, fmt.Errorf("%w: пустое значение фильтра %s", ErrInvalidQuery, field)
+		}
+		filters[field] = value
+	}
+
+	return strings.Join(words, " "), filters, nil
+}
+
+// splitQueryTokens разбивает запрос по пробелам, не разрывая значения в кавычках
+func splitQueryTokens(input string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case (r == ' ' || r == '\t' || r == '\n') && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("%w: незакрытая кавычка", ErrInvalidQuery)
+	}
+
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens, nil
+}
+
+// filterKey возвращает фильтры в виде строки с постоянным порядком полей (для ключа singleflight)
+func filterKey(filters map[string]string) string {
+	fields := make([]string, 0, len(filters))
+	for field := range filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field+"="+filters[field])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// filterStore сужает хранилище до документов, подходящих под все фильтры; без фильтров возвращает store
+func filterStore(store *vectorstore.VectorStore, filters map[string]string) *vectorstore.VectorStore {
+	if len(filters) == 0 {
+		return store
+	}
+	return store.Filter(func(doc types.Document) bool {
+		return matchFilters(doc, filters)
+	})
+}
+
+// firstDocuments возвращает не больше limit документов хранилища - ответ на запрос из одних фильтров
+func firstDocuments(store *vectorstore.VectorStore, limit int) []types.Document {
+	documents := store.Snapshot()
+	if limit > 0 && len(documents) > limit {
+		documents = documents[:limit]
+	}
+	return documents
+}
+
+// matchFilters проверяет, что документ удовлетворяет всем фильтрам
+func matchFilters(doc types.Document, filters map[string]string) bool {
+	for field, value := range filters {
+		value = strings.ToLower(value)
+		switch field {
+		case "tag":
+			matched := false
+			for _, tag := range doc.Tags {
+				if strings.ToLower(tag) == value {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "title":
+			if !strings.Contains(strings.ToLower(doc.Title), value) {
+				return false
+			}
+		case "url":
+			if !strings.Contains(strings.ToLower(doc.URL), value) {
+				return false
+			}
+		case "id":
+			if strings.ToLower(doc.ID) != value {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,48 @@
+package retrieval
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// IsRerankEnabled сообщает, включено ли переупорядочивание найденных документов моделью (RETRIEVAL_RERANK=true)
+func IsRerankEnabled() bool {
+	return os.Getenv("RETRIEVAL_RERANK") == "true"
+}
+
+// rerankCandidates - во сколько раз больше документов векторный поиск отбирает для переупорядочивания
+const rerankCandidates = 3
+
+// rerank переупорядочивает документы через LLMEngine.Rerank и возвращает не больше limit лучших.
+// При ошибке модели остается порядок векторного поиска.
+func rerank(ctx context.Context, engine llm.LLMEngine, query string, documents []types.Document, limit int) []types.Document {
+	if limit <= 0 || limit > len(documents) {
+		limit = len(documents)
+	}
+	if len(documents) <= 1 {
+		return documents
+	}
+
+	byID := make(map[string]types.Document, len(documents))
+	candidates := make([]llm.Document, 0, len(documents))
+	for _, doc := range documents {
+		byID[doc.ID] = doc
+		candidates = append(candidates, llm.Document{ID: doc.ID, Header: doc.Title, Link: doc.URL, Text: doc.Content})
+	}
+
+	ranked, err := engine.Rerank(ctx, query, candidates, limit)
+	if err != nil {
+		log.Printf("Ошибка переупорядочивания документов: %v", err)
+		return documents[:limit]
+	}
+
+	result := make([]types.Document, 0, len(ranked))
+	for _, doc := range ranked {
+		result = append(result, byID[doc.ID])
+	}
+	return result
+}
@@ -1,47 +1,172 @@
 package retrieval
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ad/rag-bot/internal/llm"
 	"github.com/ad/rag-bot/internal/types"
 	"github.com/ad/rag-bot/internal/vectorstore"
+	"golang.org/x/sync/singleflight"
 )
 
 type RetrievalEngine interface {
 	FindRelevantDocuments(query string, limit int) ([]types.Document, error)
+	// FindWithContext учитывает диалог пользователя, см. SearchOptions
+	FindWithContext(ctx context.Context, query string, opts SearchOptions, limit int) ([]types.Document, error)
+}
+
+// SearchOptions - сведения о диалоге, в котором задан запрос
+type SearchOptions struct {
+	UserID      int64    // пользователь Telegram; 0 - запрос без пользователя (HTTP API)
+	History     []string // предыдущие реплики диалога (см. QueryWithHistory)
+	BoostDocIDs []string // документы прошлой реплики, поднимаются в выдаче (см. vectorstore.SearchWithBoost)
+	// Filters - фильтры field:value из ParseStructuredQuery, разобранные из исходного текста пользователя.
+	// Запрос query при этом передается без фильтров. Qdrant фильтры не поддерживает.
+	Filters map[string]string
+}
+
+// QueryRewriter преобразует запрос перед генерацией эмбеддинга
+type QueryRewriter func(ctx context.Context, query string) (string, error)
+
+// NoopRewriter возвращает запрос без изменений
+func NoopRewriter(ctx context.Context, query string) (string, error) {
+	return query, nil
+}
+
+// FormalizeQuery переписывает разговорный вопрос формальным техническим языком,
+// чтобы он был ближе к формулировкам документации
+func FormalizeQuery(llmEngine llm.LLMEngine) QueryRewriter {
+	return func(ctx context.Context, query string) (string, error) {
+		resp, err := llmEngine.GenerateResponse(ctx, "Перепиши этот вопрос формальным техническим языком. Ответь только переписанным вопросом: "+query, map[string]interface{}{
+			"temperature": 0.1,
+			"num_predict": 100,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if rewritten := strings.TrimSpace(resp); rewritten != "" {
+			return rewritten, nil
+		}
+		return query, nil
+	}
 }
 
 type VectorRetrieval struct {
-	vectorStore *vectorstore.VectorStore
-	llmEngine   *llm.HTTPLLMEngine
+	vectorStore   *vectorstore.VectorStore
+	llmEngine     llm.LLMEngine
+	QueryRewriter QueryRewriter // вызывается перед генерацией эмбеддинга запроса
+
+	sf singleflight.Group // объединяет одновременные одинаковые запросы
 }
 
-func NewVectorRetrieval(vs *vectorstore.VectorStore, llm *llm.HTTPLLMEngine) *VectorRetrieval {
+func NewVectorRetrieval(vs *vectorstore.VectorStore, llm llm.LLMEngine) *VectorRetrieval {
 	return &VectorRetrieval{
-		vectorStore: vs,
-		llmEngine:   llm,
+		vectorStore:   vs,
+		llmEngine:     llm,
+		QueryRewriter: NoopRewriter,
 	}
 }
 
+// FindRelevantDocuments поддерживает фильтры вида tag:billing title:invoice (см. ParseStructuredQuery):
+// они сужают набор документов до векторного поиска. Ошибка разбора фильтров возвращается как ErrInvalidQuery.
+// Одновременные одинаковые запросы выполняются один раз, результат получают все вызвавшие.
 func (vr *VectorRetrieval) FindRelevantDocuments(query string, limit int) ([]types.Document, error) {
+	freeText, filters, err := ParseStructuredQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return vr.findShared(context.Background(), freeText, filters, nil, limit)
+}
+
+// sharedSearchTimeout ограничивает общий запрос, который больше не зависит от контекста первого вызвавшего
+const sharedSearchTimeout = 2 * time.Minute
+
+// findShared объединяет одновременные одинаковые запросы (с теми же фильтрами и набором усиливаемых документов).
+// Общий запрос наследует значения контекста первого вызвавшего (spans попадают в его трассировку),
+// но не его отмену: если первый вызвавший уйдет, остальные все равно получат результат.
+// Каждый вызвавший ждет результат не дольше, чем живет его собственный контекст.
+func (vr *VectorRetrieval) findShared(ctx context.Context, query string, filters map[string]string, boostDocIDs []string, limit int) ([]types.Document, error) {
+	// Части ключа разделены нулевым байтом, чтобы ("abc", 51) и ("abc5", 1) не совпадали;
+	// фильтры отделены от документов двумя нулевыми байтами
+	hash := sha256.Sum256([]byte(query + "\x00" + strconv.Itoa(limit) + "\x00" + filterKey(filters) + "\x00\x00" + strings.Join(boostDocIDs, "\x00")))
+
+	resultCh := vr.sf.DoChan(hex.EncodeToString(hash[:]), func() (interface{}, error) {
+		sharedCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedSearchTimeout)
+		defer cancel()
+		return vr.findRelevantDocuments(sharedCtx, query, filters, boostDocIDs, limit)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-resultCh:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		// Каждый вызвавший получает свою копию, чтобы изменения не влияли на остальных
+		return slices.Clone(result.Val.([]types.Document)), nil
+	}
+}
+
+func (vr *VectorRetrieval) findRelevantDocuments(ctx context.Context, freeText string, filters map[string]string, boostDocIDs []string, limit int) ([]types.Document, error) {
+	store := filterStore(vr.vectorStore, filters)
+
+	// Без текста запроса возвращаем просто отфильтрованные документы
+	if len(filters) > 0 && strings.TrimSpace(freeText) == "" {
+		return firstDocuments(store, limit), nil
+	}
+
+	query := freeText
+	if vr.QueryRewriter != nil {
+		rewritten, err := vr.QueryRewriter(ctx, freeText)
+		if err != nil {
+			log.Printf("Ошибка переписывания запроса: %v", err)
+		} else {
+			freeText = rewritten
+		}
+	}
+
 	// Генерируем эмбеддинг для запроса
-	queryEmbedding, err := vr.llmEngine.GenerateEmbedding(query)
+	queryEmbedding, err := vr.vectorStore.QueryEmbedding(ctx, freeText, vr.llmEngine)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка генерации эмбеддинга для запроса: %w", err)
 	}
 
+	// Для переупорядочивания моделью векторный поиск отбирает больше кандидатов
+	searchLimit := limit
+	if IsRerankEnabled() && limit > 0 {
+		searchLimit = limit * rerankCandidates
+	}
+
 	// Ищем похожие документы
-	results, err := vr.vectorStore.Search(queryEmbedding, limit)
+	results, err := store.SearchWithBoost(ctx, queryEmbedding, boostDocIDs, searchLimit)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка векторного поиска: %w", err)
 	}
 
+	// Статьи, на которые ссылается лучший результат, часто отвечают на тот же вопрос
+	if IsGraphExpansionEnabled() {
+		results = expandByLinks(ctx, store, queryEmbedding, results)
+	}
+
 	// Возвращаем документы
 	var documents []types.Document
 	for _, result := range results {
 		documents = append(documents, result.Document)
 	}
 
+	if IsRerankEnabled() {
+		documents = rerank(ctx, vr.llmEngine, query, documents, limit)
+	}
+
 	return documents, nil
 }
@@ -0,0 +1,222 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/ad/rag-bot/internal/vectorstore"
+)
+
+func TestParseStructuredQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantText    string
+		wantFilters map[string]string
+		wantErr     bool
+	}{
+		{"без фильтров", "как оплатить счет", "как оплатить счет", map[string]string{}, false},
+		{"фильтры и текст", "tag:billing как оплатить", "как оплатить", map[string]string{"tag": "billing"}, false},
+		{"значение в кавычках", `title:"счет на оплату" срок`, "срок", map[string]string{"title": "счет на оплату"}, false},
+		{"поле в верхнем регистре", "TAG:billing", "", map[string]string{"tag": "billing"}, false},
+		{"неизвестное поле", "время:10:00 tag:a", "время:10:00", map[string]string{"tag": "a"}, false},
+		{"незакрытая кавычка", `title:"счет на оплату`, "", nil, true},
+		{"пустое значение", "tag: оплата", "", nil, true},
+		{"пустые кавычки", `title:""`, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, filters, err := ParseStructuredQuery(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Fatalf("ожидалась ошибка ErrInvalidQuery, получено %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if text != tt.wantText {
+				t.Errorf("текст = %q, ожидалось %q", text, tt.wantText)
+			}
+			if !reflect.DeepEqual(filters, tt.wantFilters) {
+				t.Errorf("фильтры = %v, ожидалось %v", filters, tt.wantFilters)
+			}
+		})
+	}
+}
+
+// newTestRetrieval возвращает поиск по трем документам; эмбеддинг любого запроса - [1, 0]
+func newTestRetrieval(mock *llm.MockLLMEngine) *VectorRetrieval {
+	if mock.EmbeddingFn == nil {
+		mock.EmbeddingFn = func(string) ([]float32, error) { return []float32{1, 0}, nil }
+	}
+
+	store := vectorstore.NewVectorStore()
+	store.AddDocuments([]types.Document{
+		{ID: "pay", Title: "Оплата счета", Tags: []string{"billing"}, Embedding: []float32{1, 0}},
+		{ID: "domain", Title: "Продление домена", Tags: []string{"domains"}, Embedding: []float32{0.9, 0.1}},
+		{ID: "refund", Title: "Возврат оплаты", Tags: []string{"billing"}, Embedding: []float32{0.5, 0.5}},
+	})
+	return NewVectorRetrieval(store, mock)
+}
+
+func documentIDs(docs []types.Document) string {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestVectorRetrievalFilters(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		want           string
+		wantEmbeddings int32 // вызовов мока: эмбеддинг запроса
+	}{
+		{"без фильтров", "оплата", "pay,domain,refund", 1},
+		{"фильтр по тегу", "tag:billing оплата", "pay,refund", 1},
+		{"фильтр по заголовку", `title:"возврат" оплата`, "refund", 1},
+		{"только фильтры", "tag:domains", "domain", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &llm.MockLLMEngine{}
+			vr := newTestRetrieval(mock)
+
+			docs, err := vr.FindRelevantDocuments(tt.query, 5)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if got := documentIDs(docs); got != tt.want {
+				t.Errorf("документы = %s, ожидалось %s", got, tt.want)
+			}
+			if got := mock.Calls.Load(); got != tt.wantEmbeddings {
+				t.Errorf("вызовов LLM %d, ожидалось %d", got, tt.wantEmbeddings)
+			}
+		})
+	}
+
+	vr := newTestRetrieval(&llm.MockLLMEngine{})
+	if _, err := vr.FindRelevantDocuments(`title:"оплата`, 5); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("ожидалась ошибка ErrInvalidQuery, получено %v", err)
+	}
+}
+
+func TestVectorRetrievalBoost(t *testing.T) {
+	vr := newTestRetrieval(&llm.MockLLMEngine{})
+
+	// Документ прошлой реплики поднимается над более похожими документами
+	docs, err := vr.FindWithContext(context.Background(), "оплата", SearchOptions{BoostDocIDs: []string{"refund"}}, 2)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got := documentIDs(docs); got != "refund,pay" {
+		t.Errorf("документы = %s, ожидалось refund,pay", got)
+	}
+}
+
+func TestVectorRetrievalSingleflight(t *testing.T) {
+	var rewrites atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	vr := newTestRetrieval(&llm.MockLLMEngine{})
+	vr.QueryRewriter = func(ctx context.Context, query string) (string, error) {
+		rewrites.Add(1)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return query, nil
+	}
+
+	// Первый вызвавший уходит, не дождавшись результата
+	firstCtx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := vr.FindWithContext(firstCtx, "оплата", SearchOptions{}, 5)
+		firstErr <- err
+	}()
+	<-started
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("ошибка первого вызова = %v, ожидалась context.Canceled", err)
+	}
+
+	// Остальные присоединяются к общему запросу, который не отменен вместе с первым вызовом
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			docs, err := vr.FindWithContext(context.Background(), "оплата", SearchOptions{}, 5)
+			results[i], errs[i] = documentIDs(docs), err
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("вызов %d: неожиданная ошибка: %v", i, errs[i])
+		}
+		if results[i] != "pay,domain,refund" {
+			t.Errorf("вызов %d: документы = %s", i, results[i])
+		}
+	}
+	if got := rewrites.Load(); got != 1 {
+		t.Errorf("поиск выполнен %d раз, ожидалось 1", got)
+	}
+}
+
+// reversingEngine переупорядочивает документы в обратном порядке
+type reversingEngine struct {
+	*llm.MockLLMEngine
+	candidates int
+}
+
+func (e *reversingEngine) Rerank(ctx context.Context, query string, docs []llm.Document, topK int) ([]llm.Document, error) {
+	e.candidates = len(docs)
+	reversed := slices.Clone(docs)
+	slices.Reverse(reversed)
+	return reversed[:topK], nil
+}
+
+func TestVectorRetrievalRerank(t *testing.T) {
+	t.Setenv("RETRIEVAL_RERANK", "true")
+
+	engine := &reversingEngine{MockLLMEngine: &llm.MockLLMEngine{}}
+	vr := newTestRetrieval(engine.MockLLMEngine)
+	vr.llmEngine = engine
+
+	docs, err := vr.FindRelevantDocuments("оплата", 1)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	// Модель получила все три кандидата векторного поиска и выбрала последний
+	if engine.candidates != 3 {
+		t.Errorf("кандидатов для переупорядочивания %d, ожидалось 3", engine.candidates)
+	}
+	if got := documentIDs(docs); got != "refund" {
+		t.Errorf("документы = %s, ожидалось refund", got)
+	}
+}
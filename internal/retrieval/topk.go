@@ -0,0 +1,31 @@
+package retrieval
+
+import (
+	"os"
+	"strconv"
+)
+
+// Количество документов на запрос по умолчанию и верхняя граница по умолчанию
+const (
+	defaultTopK = 2
+	defaultMaxK = 10
+)
+
+// GetMaxK возвращает максимально допустимое число документов на запрос (RETRIEVAL_MAX_K, по умолчанию 10)
+func GetMaxK() int {
+	if maxK, err := strconv.Atoi(os.Getenv("RETRIEVAL_MAX_K")); err == nil && maxK > 0 {
+		return maxK
+	}
+	return defaultMaxK
+}
+
+// GetTopK возвращает число документов, передаваемых LLM на один запрос (RETRIEVAL_TOP_K, по умолчанию 2).
+// Маленьким моделям (gemma3:1b) лишние документы переполняют контекст, большим - помогают ответить точнее.
+// Значение ограничено GetMaxK.
+func GetTopK() int {
+	topK := defaultTopK
+	if value, err := strconv.Atoi(os.Getenv("RETRIEVAL_TOP_K")); err == nil && value > 0 {
+		topK = value
+	}
+	return min(topK, GetMaxK())
+}
@@ -0,0 +1,175 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers - число одновременных загрузок вложенных sitemap по умолчанию
+const DefaultWorkers = 5
+
+// requestInterval - минимальный интервал между запросами к одному домену
+const requestInterval = time.Second
+
+// Entry - страница из sitemap
+type Entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type urlSet struct {
+	URLs []Entry `xml:"url"`
+}
+
+type sitemapIndex struct {
+	Sitemaps []Entry `xml:"sitemap"`
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// SetTransport задает транспорт для загрузки sitemap (например, с прокси); nil - стандартный
+func SetTransport(transport http.RoundTripper) {
+	httpClient.Transport = transport
+}
+
+// Fetch загружает sitemap. Для sitemap index возвращает адреса вложенных sitemap в subSitemaps,
+// для обычного sitemap - страницы в entries.
+func Fetch(sitemapURL string) (entries []Entry, subSitemaps []string, err error) {
+	resp, err := httpClient.Get(sitemapURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("HTTP ошибка %d для %s", resp.StatusCode, sitemapURL)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var index sitemapIndex
+	if err := xml.Unmarshal(body, &index); err == nil && len(index.Sitemaps) > 0 {
+		for _, sitemap := range index.Sitemaps {
+			subSitemaps = append(subSitemaps, sitemap.Loc)
+		}
+		return nil, subSitemaps, nil
+	}
+
+	var set urlSet
+	if err := xml.Unmarshal(body, &set); err != nil {
+		return nil, nil, fmt.Errorf("ошибка разбора %s: %w", sitemapURL, err)
+	}
+
+	return set.URLs, nil, nil
+}
+
+// FetchSitemapsConcurrent загружает вложенные sitemap пулом из workers потоков (по умолчанию DefaultWorkers)
+// с ограничением 1 запрос в секунду на домен и возвращает все адреса страниц без повторов.
+// Если часть sitemap загрузить не удалось, возвращаются собранные адреса и объединенная ошибка.
+func FetchSitemapsConcurrent(urls []string, workers int) ([]string, error) {
+	entries, err := FetchEntriesConcurrent(urls, workers)
+
+	locs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		locs = append(locs, entry.Loc)
+	}
+
+	return locs, err
+}
+
+// FetchEntriesConcurrent работает как FetchSitemapsConcurrent, но сохраняет <lastmod> страниц
+func FetchEntriesConcurrent(urls []string, workers int) ([]Entry, error) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	limiter := newDomainLimiter(requestInterval)
+	jobs := make(chan string)
+
+	var (
+		mu      sync.Mutex
+		seen    = make(map[string]bool)
+		entries []Entry
+		errs    []error
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sitemapURL := range jobs {
+				limiter.Wait(sitemapURL)
+
+				found, _, err := Fetch(sitemapURL)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				}
+				for _, entry := range found {
+					if !seen[entry.Loc] {
+						seen[entry.Loc] = true
+						entries = append(entries, entry)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, sitemapURL := range urls {
+		jobs <- sitemapURL
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Loc < entries[j].Loc
+	})
+
+	return entries, errors.Join(errs...)
+}
+
+// domainLimiter выдерживает интервал между запросами к одному домену для всех потоков
+type domainLimiter struct {
+	interval time.Duration
+	next     map[string]time.Time
+	mu       sync.Mutex
+}
+
+func newDomainLimiter(interval time.Duration) *domainLimiter {
+	return &domainLimiter{
+		interval: interval,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// Wait блокирует до момента, когда к домену rawURL можно отправить следующий запрос
+func (l *domainLimiter) Wait(rawURL string) {
+	host := rawURL
+	if parsed, err := url.Parse(rawURL); err == nil {
+		host = parsed.Host
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
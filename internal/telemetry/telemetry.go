@@ -0,0 +1,55 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetOTLPEndpoint возвращает адрес OTLP-коллектора (OTEL_EXPORTER_OTLP_ENDPOINT). Пусто - трассировка выключена.
+func GetOTLPEndpoint() string {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+}
+
+// Setup включает отправку трассировок по OTLP/HTTP, если задан OTEL_EXPORTER_OTLP_ENDPOINT.
+// Остальные параметры экспортера (заголовки, таймауты) читаются из стандартных переменных OTEL_EXPORTER_OTLP_*.
+// Возвращаемая функция отправляет оставшиеся spans и останавливает экспортер.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if GetOTLPEndpoint() == "" {
+		// Без провайдера spans создаются no-op трассировщиком и никуда не отправляются
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания OTLP экспортера: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// EndSpan завершает span, отмечая в нем ошибку, если она есть
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// simHashShingleSize - число слов в шингле SimHash
+const simHashShingleSize = 3
+
+// SimHash вычисляет 64-битный SimHash текста по шинглам из трех слов (без учета регистра и пунктуации).
+// У почти одинаковых текстов хеши отличаются в небольшом числе бит, см. HammingDistance.
+// Для пустого текста возвращает 0.
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	shingleSize := min(simHashShingleSize, len(words))
+
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash
+}
+
+// HammingDistance возвращает число различающихся бит двух SimHash
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
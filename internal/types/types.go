@@ -3,14 +3,25 @@ package types
 import (
 	"crypto/md5"
 	"fmt"
+	"time"
 )
 
 type Document struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	URL       string    `json:"url"`
-	Content   string    `json:"content"`
-	Embedding []float32 `json:"embedding,omitempty"`
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	URL       string            `json:"url"`
+	Content   string            `json:"content"`
+	Tags      []string          `json:"tags,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitzero"`  // время первой загрузки страницы, нулевое - неизвестно
+	UpdatedAt *time.Time        `json:"updated_at,omitempty"` // время повторной загрузки с изменившимся содержимым
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Embedding []float32         `json:"embedding,omitempty"`
+
+	ReadingTimeSeconds int    `json:"reading_time_seconds,omitempty"` // примерное время чтения статьи
+	SimHash            uint64 `json:"simhash,omitempty"`              // отпечаток содержимого для поиска почти одинаковых документов
+
+	ExternalLinks []string `json:"external_links,omitempty"` // абсолютные URL ссылок из текста статьи
+	SectionPath   []string `json:"section_path,omitempty"`   // разделы сайта, в которых лежит статья (папки пути URL)
 }
 
 // GetContentHash возвращает MD5 хеш содержимого документа для проверки изменений
@@ -19,3 +30,12 @@ func (d *Document) GetContentHash() string {
 	hash := md5.Sum([]byte(content))
 	return fmt.Sprintf("%x", hash)
 }
+
+// ModifiedAt возвращает время загрузки текущего содержимого документа: UpdatedAt, а без него CreatedAt.
+// Нулевое время - документ без даты загрузки.
+func (d *Document) ModifiedAt() time.Time {
+	if d.UpdatedAt != nil {
+		return *d.UpdatedAt
+	}
+	return d.CreatedAt
+}
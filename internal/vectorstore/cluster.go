@@ -0,0 +1,183 @@
+package vectorstore
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// GetKMeansMaxIter возвращает максимальное число итераций k-means
+func GetKMeansMaxIter() int {
+	if maxIter, err := strconv.Atoi(os.Getenv("KMEANS_MAX_ITER")); err == nil && maxIter > 0 {
+		return maxIter
+	}
+	return 100
+}
+
+// Cluster разбивает документы на k тематических групп алгоритмом k-means по косинусной близости эмбеддингов.
+// Первым в каждой группе идёт документ, ближайший к центроиду: его заголовок можно использовать как название кластера.
+// Всегда возвращается ровно k непустых групп: опустевший кластер получает документ, дальше всех отстоящий от своего центроида.
+func (vs *VectorStore) Cluster(k int) ([][]types.Document, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("количество кластеров должно быть положительным")
+	}
+
+	vs.mu.RLock()
+	var docs []types.Document
+	for _, doc := range vs.documents {
+		if len(doc.Embedding) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+	vs.mu.RUnlock()
+
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("нет документов с эмбеддингами")
+	}
+
+	if k > len(docs) {
+		return nil, fmt.Errorf("кластеров (%d) больше, чем документов с эмбеддингами (%d)", k, len(docs))
+	}
+
+	dim := len(docs[0].Embedding)
+	for _, doc := range docs {
+		if len(doc.Embedding) != dim {
+			return nil, fmt.Errorf("документ %s имеет эмбеддинг размерности %d вместо %d", doc.ID, len(doc.Embedding), dim)
+		}
+	}
+
+	centroids := initCentroids(docs, k)
+	assignments := make([]int, len(docs))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+
+	for iter := 0; iter < GetKMeansMaxIter(); iter++ {
+		changed := false
+		for i, doc := range docs {
+			best := nearestCentroid(doc.Embedding, centroids)
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		if fillEmptyClusters(docs, assignments, centroids) {
+			changed = true
+		}
+
+		if !changed {
+			break
+		}
+
+		// Пересчитываем центроиды как среднее векторов кластера
+		sums := make([][]float32, k)
+		counts := make([]int, k)
+		for i, doc := range docs {
+			c := assignments[i]
+			if sums[c] == nil {
+				sums[c] = make([]float32, dim)
+			}
+			for j, v := range doc.Embedding {
+				sums[c][j] += v
+			}
+			counts[c]++
+		}
+		for c := range centroids {
+			for j := range sums[c] {
+				sums[c][j] /= float32(counts[c])
+			}
+			centroids[c] = sums[c]
+		}
+	}
+
+	// Последняя итерация могла оставить кластер пустым, если цикл прервался по KMEANS_MAX_ITER
+	fillEmptyClusters(docs, assignments, centroids)
+
+	clusters := make([][]types.Document, k)
+	bestScores := make([]float32, k)
+	for i, doc := range docs {
+		c := assignments[i]
+		score := cosineSimilarity(doc.Embedding, centroids[c])
+		if len(clusters[c]) == 0 || score > bestScores[c] {
+			// Ближайший к центроиду документ ставим первым
+			clusters[c] = append([]types.Document{doc}, clusters[c]...)
+			bestScores[c] = score
+		} else {
+			clusters[c] = append(clusters[c], doc)
+		}
+	}
+
+	return clusters, nil
+}
+
+// fillEmptyClusters переносит в каждый пустой кластер документ, наименее похожий на центроид своего кластера,
+// и делает его эмбеддинг центроидом. Документы забираются только у кластеров, где их больше одного,
+// поэтому при k <= len(docs) пустых кластеров не остается. Возвращает true, если что-то перенесено.
+func fillEmptyClusters(docs []types.Document, assignments []int, centroids [][]float32) bool {
+	counts := make([]int, len(centroids))
+	for _, c := range assignments {
+		counts[c]++
+	}
+
+	moved := false
+	for c := range centroids {
+		if counts[c] > 0 {
+			continue
+		}
+
+		farthest := -1
+		var farthestScore float32 = 2
+		for i, doc := range docs {
+			if counts[assignments[i]] < 2 {
+				continue
+			}
+			if score := cosineSimilarity(doc.Embedding, centroids[assignments[i]]); score < farthestScore {
+				farthest = i
+				farthestScore = score
+			}
+		}
+		if farthest < 0 {
+			break
+		}
+
+		counts[assignments[farthest]]--
+		counts[c]++
+		assignments[farthest] = c
+		centroids[c] = docs[farthest].Embedding
+		moved = true
+	}
+	return moved
+}
+
+// initCentroids выбирает начальные центроиды детерминированно: первый документ,
+// затем каждый раз документ, наименее похожий на уже выбранные (farthest-first)
+func initCentroids(docs []types.Document, k int) [][]float32 {
+	centroids := [][]float32{docs[0].Embedding}
+	for len(centroids) < k {
+		farthest := 0
+		var farthestScore float32 = 2
+		for i, doc := range docs {
+			score := cosineSimilarity(doc.Embedding, centroids[nearestCentroid(doc.Embedding, centroids)])
+			if score < farthestScore {
+				farthest = i
+				farthestScore = score
+			}
+		}
+		centroids = append(centroids, docs[farthest].Embedding)
+	}
+	return centroids
+}
+
+func nearestCentroid(embedding []float32, centroids [][]float32) int {
+	best := 0
+	var bestScore float32 = -2
+	for c, centroid := range centroids {
+		if score := cosineSimilarity(embedding, centroid); score > bestScore {
+			best = c
+			bestScore = score
+		}
+	}
+	return best
+}
@@ -0,0 +1,95 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DebugDocument возвращает диагностическую информацию о документе: размер, параметры эмбеддинга,
+// ближайшие документы и позицию в результатах последнего поиска
+func (vs *VectorStore) DebugDocument(id string) string {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	index := -1
+	for i, doc := range vs.documents {
+		if doc.ID == id {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		return fmt.Sprintf("Документ %s не найден", id)
+	}
+
+	doc := vs.documents[index]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ID: %s\n", doc.ID)
+	fmt.Fprintf(&b, "Заголовок: %s\n", doc.Title)
+	fmt.Fprintf(&b, "URL: %s\n", doc.URL)
+	fmt.Fprintf(&b, "Слов: %d\n", len(strings.Fields(doc.Content)))
+	fmt.Fprintf(&b, "Размерность эмбеддинга: %d\n", len(doc.Embedding))
+	fmt.Fprintf(&b, "L2-норма эмбеддинга: %.4f\n", l2Norm(doc.Embedding))
+
+	if len(doc.Embedding) > 0 {
+		var similar []SearchResult
+		for i, other := range vs.documents {
+			if i == index || len(other.Embedding) == 0 {
+				continue
+			}
+			similar = append(similar, SearchResult{
+				Document: other,
+				Score:    cosineSimilarity(doc.Embedding, other.Embedding),
+			})
+		}
+
+		sort.Slice(similar, func(i, j int) bool {
+			return similar[i].Score > similar[j].Score
+		})
+
+		if len(similar) > 5 {
+			similar = similar[:5]
+		}
+
+		b.WriteString("Ближайшие документы:\n")
+		for _, result := range similar {
+			fmt.Fprintf(&b, "  %.4f %s (%s)\n", result.Score, result.Document.Title, result.Document.ID)
+		}
+	}
+
+	vs.lastSearchMu.Lock()
+	lastSearch := vs.lastSearch
+	vs.lastSearchMu.Unlock()
+
+	switch {
+	case lastSearch == nil:
+		b.WriteString("Позиция в последнем поиске: поиск еще не выполнялся\n")
+	default:
+		position := -1
+		for i, resultID := range lastSearch {
+			if resultID == id {
+				position = i + 1
+				break
+			}
+		}
+		if position > 0 {
+			fmt.Fprintf(&b, "Позиция в последнем поиске: %d из %d\n", position, len(lastSearch))
+		} else {
+			fmt.Fprintf(&b, "Позиция в последнем поиске: не найден среди %d результатов\n", len(lastSearch))
+		}
+	}
+
+	return b.String()
+}
+
+func l2Norm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
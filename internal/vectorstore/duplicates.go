@@ -0,0 +1,72 @@
+package vectorstore
+
+import (
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// GetSimHashThreshold возвращает максимальное расстояние Хэмминга между SimHash почти одинаковых документов
+// (SIMHASH_THRESHOLD, по умолчанию 3)
+func GetSimHashThreshold() int {
+	if threshold, err := strconv.Atoi(os.Getenv("SIMHASH_THRESHOLD")); err == nil && threshold >= 0 {
+		return threshold
+	}
+	return 3
+}
+
+// FindNearDuplicates группирует документы, SimHash которых отличаются не больше чем на threshold бит.
+// Группы связны: если A похож на B, а B на C, все три попадают в одну группу.
+// Возвращаются только группы из двух и более ID; документы без SimHash не учитываются.
+func (vs *VectorStore) FindNearDuplicates(threshold int) [][]string {
+	vs.mu.RLock()
+	var docs []types.Document
+	for _, doc := range vs.documents {
+		if doc.SimHash != 0 {
+			docs = append(docs, doc)
+		}
+	}
+	vs.mu.RUnlock()
+
+	// Система непересекающихся множеств по индексам docs
+	parent := make([]int, len(docs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range docs {
+		for j := i + 1; j < len(docs); j++ {
+			if types.HammingDistance(docs[i].SimHash, docs[j].SimHash) <= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]string)
+	for i, doc := range docs {
+		root := find(i)
+		groups[root] = append(groups[root], doc.ID)
+	}
+
+	var result [][]string
+	for _, ids := range groups {
+		if len(ids) > 1 {
+			sort.Strings(ids)
+			result = append(result, ids)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0] < result[j][0]
+	})
+
+	return result
+}
@@ -0,0 +1,92 @@
+package vectorstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// maxImportLineSize - максимальный размер одной строки JSONL (документ с эмбеддингом)
+const maxImportLineSize = 16 * 1024 * 1024
+
+// ImportResult - итог импорта документов
+type ImportResult struct {
+	Added   int // новые документы
+	Updated int // документы, заменившие существующие с тем же ID
+	Skipped int // строки с некорректным JSON, без ID или без эмбеддинга
+}
+
+// Export записывает все документы в w в формате JSONL (один JSON-объект на строку)
+func (vs *VectorStore) Export(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, doc := range vs.Snapshot() {
+		if err := encoder.Encode(doc); err != nil {
+			return fmt.Errorf("ошибка записи документа %s: %w", doc.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// Import читает документы из JSONL-потока и добавляет их в хранилище.
+// Документ с уже существующим ID заменяет прежний. Документы без ID или эмбеддинга пропускаются.
+func (vs *VectorStore) Import(r io.Reader) (ImportResult, error) {
+	var result ImportResult
+	var documents []types.Document
+
+	// Сначала читаем весь поток, чтобы не держать блокировку хранилища во время чтения
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var doc types.Document
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			vs.logger.Warn("пропущена строка с некорректным JSON", "line", line, "error", err)
+			result.Skipped++
+			continue
+		}
+
+		if doc.ID == "" || len(doc.Embedding) == 0 {
+			vs.logger.Warn("пропущен документ без ID или эмбеддинга", "line", line, "id", doc.ID)
+			result.Skipped++
+			continue
+		}
+
+		documents = append(documents, doc)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("ошибка чтения потока документов: %w", err)
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	index := make(map[string]int, len(vs.documents))
+	for i, doc := range vs.documents {
+		index[doc.ID] = i
+	}
+
+	for _, doc := range documents {
+		if i, exists := index[doc.ID]; exists {
+			vs.documents[i] = doc
+			result.Updated++
+			continue
+		}
+
+		index[doc.ID] = len(vs.documents)
+		vs.documents = append(vs.documents, doc)
+		result.Added++
+	}
+
+	// Обновленные документы могли сменить URL
+	vs.rebuildURLIndex()
+
+	return result, nil
+}
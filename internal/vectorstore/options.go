@@ -0,0 +1,126 @@
+package vectorstore
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ad/rag-bot/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultSimilarityThreshold - минимальный скор, ниже которого результаты поиска отбрасываются
+const defaultSimilarityThreshold float32 = 0.1
+
+// defaultBoostFactor - множитель скора документов в SearchWithBoost
+const defaultBoostFactor float32 = 1.5
+
+// GetBoostFactor возвращает множитель скора для SearchWithBoost из BOOST_FACTOR (по умолчанию 1.5)
+func GetBoostFactor() float32 {
+	if factor, err := strconv.ParseFloat(os.Getenv("BOOST_FACTOR"), 32); err == nil && factor > 0 {
+		return float32(factor)
+	}
+	return defaultBoostFactor
+}
+
+// defaultMaxRadiusResults - максимум результатов SearchRadius
+const defaultMaxRadiusResults = 20
+
+// GetMaxRadiusResults возвращает максимум результатов SearchRadius из MAX_RADIUS_RESULTS (по умолчанию 20)
+func GetMaxRadiusResults() int {
+	if n, err := strconv.Atoi(os.Getenv("MAX_RADIUS_RESULTS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultMaxRadiusResults
+}
+
+// VectorStoreOption настраивает VectorStore при создании
+type VectorStoreOption func(*VectorStore)
+
+// WithInitialCapacity заранее выделяет место под n документов
+func WithInitialCapacity(n int) VectorStoreOption {
+	return func(vs *VectorStore) {
+		if n > 0 {
+			vs.documents = make([]types.Document, 0, n)
+		}
+	}
+}
+
+// WithSimilarityThreshold задает минимальный скор для результатов поиска
+func WithSimilarityThreshold(t float32) VectorStoreOption {
+	return func(vs *VectorStore) {
+		vs.similarityThreshold = t
+	}
+}
+
+// WithBoostFactor задает множитель скора документов в SearchWithBoost (см. GetBoostFactor)
+func WithBoostFactor(f float32) VectorStoreOption {
+	return func(vs *VectorStore) {
+		if f > 0 {
+			vs.boostFactor = f
+		}
+	}
+}
+
+// WithLogger задает логгер хранилища
+func WithLogger(l *slog.Logger) VectorStoreOption {
+	return func(vs *VectorStore) {
+		if l != nil {
+			vs.logger = l
+		}
+	}
+}
+
+// WithMetrics включает метрики поиска и регистрирует их в Prometheus
+func WithMetrics(reg prometheus.Registerer) VectorStoreOption {
+	return func(vs *VectorStore) {
+		vs.metricsRegisterer = reg
+	}
+}
+
+// storeMetrics - метрики хранилища, nil если метрики не включены
+type storeMetrics struct {
+	searches       prometheus.Counter
+	searchDuration prometheus.Histogram
+}
+
+func (vs *VectorStore) registerMetrics(reg prometheus.Registerer) {
+	metrics := &storeMetrics{
+		searches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "rag_vectorstore_searches_total",
+			Help: "Количество поисковых запросов к векторному хранилищу",
+		}),
+		searchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "rag_vectorstore_search_duration_seconds",
+			Help:    "Длительность поиска в векторном хранилище",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+
+	documents := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rag_vectorstore_documents",
+		Help: "Количество документов в векторном хранилище",
+	}, func() float64 {
+		return float64(vs.GetDocumentCount())
+	})
+
+	for _, collector := range []prometheus.Collector{metrics.searches, metrics.searchDuration, documents} {
+		if err := reg.Register(collector); err != nil {
+			vs.logger.Warn("не удалось зарегистрировать метрики векторного хранилища", "error", err)
+			return
+		}
+	}
+
+	vs.metrics = metrics
+}
+
+// observeSearch учитывает поиск в метриках, если они включены
+func (vs *VectorStore) observeSearch(start time.Time) {
+	if vs.metrics == nil {
+		return
+	}
+
+	vs.metrics.searches.Inc()
+	vs.metrics.searchDuration.Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,202 @@
+package vectorstore
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// Размер пачки точек при загрузке в Qdrant
+const qdrantUpsertBatchSize = 100
+
+// QdrantClient - минимальный клиент HTTP API Qdrant
+type QdrantClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewQdrantClient(endpoint string) *QdrantClient {
+	return &QdrantClient{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type qdrantPoint struct {
+	ID      string                 `json:"id"`
+	Vector  []float32              `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+type qdrantSearchResponse struct {
+	Result []struct {
+		Score   float32 `json:"score"`
+		Payload struct {
+			DocumentID  string   `json:"document_id"`
+			Title       string   `json:"title"`
+			URL         string   `json:"url"`
+			Content     string   `json:"content"`
+			Tags        []string `json:"tags"`
+			SectionPath []string `json:"section_path"`
+		} `json:"payload"`
+	} `json:"result"`
+}
+
+// EnsureCollection создает коллекцию с косинусной метрикой, если её еще нет
+func (qc *QdrantClient) EnsureCollection(collection string, dim int) error {
+	resp, err := qc.client.Get(qc.collectionURL(collection))
+	if err != nil {
+		return fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body := map[string]interface{}{
+		"vectors": map[string]interface{}{
+			"size":     dim,
+			"distance": "Cosine",
+		},
+	}
+
+	return qc.do(http.MethodPut, qc.collectionURL(collection), body, nil)
+}
+
+// Upsert загружает документы с эмбеддингами как точки коллекции
+func (qc *QdrantClient) Upsert(collection string, docs []types.Document) error {
+	for start := 0; start < len(docs); start += qdrantUpsertBatchSize {
+		end := min(start+qdrantUpsertBatchSize, len(docs))
+
+		points := make([]qdrantPoint, 0, end-start)
+		for _, doc := range docs[start:end] {
+			points = append(points, qdrantPoint{
+				ID:     qdrantPointID(doc.ID),
+				Vector: doc.Embedding,
+				Payload: map[string]interface{}{
+					"document_id":  doc.ID,
+					"title":        doc.Title,
+					"url":          doc.URL,
+					"content":      doc.Content,
+					"tags":         doc.Tags,
+					"section_path": doc.SectionPath,
+				},
+			})
+		}
+
+		body := map[string]interface{}{"points": points}
+		if err := qc.do(http.MethodPut, qc.collectionURL(collection)+"/points?wait=true", body, nil); err != nil {
+			return fmt.Errorf("ошибка загрузки документов %d-%d: %w", start, end, err)
+		}
+	}
+
+	return nil
+}
+
+// Search ищет ближайшие к эмбеддингу запроса документы
+func (qc *QdrantClient) Search(collection string, queryEmbedding []float32, topK int) ([]SearchResult, error) {
+	body := map[string]interface{}{
+		"vector":       queryEmbedding,
+		"limit":        topK,
+		"with_payload": true,
+	}
+
+	var response qdrantSearchResponse
+	if err := qc.do(http.MethodPost, qc.collectionURL(collection)+"/points/search", body, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(response.Result))
+	for _, point := range response.Result {
+		results = append(results, SearchResult{
+			Document: types.Document{
+				ID:      point.Payload.DocumentID,
+				Title:   point.Payload.Title,
+				URL:     point.Payload.URL,
+				Content: point.Payload.Content,
+				Tags:    point.Payload.Tags,
+
+				SectionPath: point.Payload.SectionPath,
+			},
+			Score: point.Score,
+		})
+	}
+
+	return results, nil
+}
+
+func (qc *QdrantClient) collectionURL(collection string) string {
+	return qc.endpoint + "/collections/" + url.PathEscape(collection)
+}
+
+func (qc *QdrantClient) do(method, requestURL string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации запроса: %w", err)
+	}
+
+	req, err := http.NewRequest(method, requestURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := qc.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP ошибка: %d, ответ: %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("ошибка десериализации ответа: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// qdrantPointID формирует UUID точки из ID документа (Qdrant принимает только числа и UUID)
+func qdrantPointID(documentID string) string {
+	h := md5.Sum([]byte(documentID))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// ExportToQdrant загружает все документы с эмбеддингами в коллекцию Qdrant, создавая её при необходимости
+func (vs *VectorStore) ExportToQdrant(endpoint, collectionName string) error {
+	var docs []types.Document
+	for _, doc := range vs.Snapshot() {
+		if len(doc.Embedding) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+
+	if len(docs) == 0 {
+		return fmt.Errorf("нет документов с эмбеддингами")
+	}
+
+	client := NewQdrantClient(endpoint)
+	if err := client.EnsureCollection(collectionName, len(docs[0].Embedding)); err != nil {
+		return fmt.Errorf("ошибка создания коллекции %s: %w", collectionName, err)
+	}
+
+	return client.Upsert(collectionName, docs)
+}
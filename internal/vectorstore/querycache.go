@@ -0,0 +1,142 @@
+package vectorstore
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// queryCacheSize - сколько эмбеддингов запросов хранится в памяти
+const queryCacheSize = 1000
+
+// Embedder генерирует эмбеддинг текста (реализуется llm.LLMEngine)
+type Embedder interface {
+	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
+}
+
+// queryCache - LRU-кэш эмбеддингов запросов: повторные вопросы не обращаются к модели эмбеддингов
+type queryCache struct {
+	entries map[string]*list.Element
+	order   *list.List // в начале - самые свежие
+	mu      sync.Mutex
+}
+
+type queryCacheEntry struct {
+	query     string
+	embedding []float32
+}
+
+func (c *queryCache) get(query string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[query]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*queryCacheEntry).embedding, true
+	}
+	return nil, false
+}
+
+func (c *queryCache) put(query string, embedding []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+		c.order = list.New()
+	}
+
+	if element, ok := c.entries[query]; ok {
+		element.Value.(*queryCacheEntry).embedding = embedding
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[query] = c.order.PushFront(&queryCacheEntry{query: query, embedding: embedding})
+	if c.order.Len() > queryCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryCacheEntry).query)
+	}
+}
+
+// normalizeQuery приводит запрос к ключу кэша: одинаковые вопросы с разным регистром и пробелами совпадают
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// QueryEmbedding возвращает эмбеддинг запроса из кэша или генерирует его через embedder и кэширует
+func (vs *VectorStore) QueryEmbedding(ctx context.Context, query string, embedder Embedder) ([]float32, error) {
+	key := normalizeQuery(query)
+	if embedding, ok := vs.queryCache.get(key); ok {
+		return embedding, nil
+	}
+
+	embedding, err := embedder.GenerateEmbedding(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	vs.queryCache.put(key, embedding)
+	return embedding, nil
+}
+
+// HasQueryEmbedding сообщает, есть ли эмбеддинг запроса в кэше
+func (vs *VectorStore) HasQueryEmbedding(query string) bool {
+	_, ok := vs.queryCache.get(normalizeQuery(query))
+	return ok
+}
+
+// WarmSearchCache заранее вычисляет эмбеддинги частых запросов, чтобы первые вопросы
+// после перезапуска не ждали модель эмбеддингов. Возвращает число закэшированных запросов.
+func (vs *VectorStore) WarmSearchCache(ctx context.Context, topQueries []string, embedder Embedder) int {
+	warmed := 0
+	for _, query := range topQueries {
+		if ctx.Err() != nil {
+			break
+		}
+		if strings.TrimSpace(query) == "" {
+			continue
+		}
+
+		if _, err := vs.QueryEmbedding(ctx, query, embedder); err != nil {
+			vs.logger.Warn("не удалось прогреть эмбеддинг запроса", "query", query, "error", err)
+			continue
+		}
+		warmed++
+	}
+	return warmed
+}
+
+// GetWarmQueriesFile возвращает файл с частыми запросами для WarmSearchCache (WARM_QUERIES_FILE)
+func GetWarmQueriesFile() string {
+	return os.Getenv("WARM_QUERIES_FILE")
+}
+
+// LoadWarmQueries читает запросы из файла, по одному на строку; пустые строки и строки с # пропускаются
+func LoadWarmQueries(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла запросов: %w", err)
+	}
+	defer file.Close()
+
+	var queries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла запросов: %w", err)
+	}
+
+	return queries, nil
+}
@@ -0,0 +1,80 @@
+package vectorstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+// Форматы сериализации хранилища
+const (
+	FormatJSON = "json" // читаемый человеком, медленный
+	FormatGob  = "gob"  // компактный и быстрый
+)
+
+// ErrUnknownFormat возвращается для неподдерживаемого формата сериализации
+var ErrUnknownFormat = errors.New("неизвестный формат сериализации")
+
+// GetSerializationFormat возвращает формат сериализации хранилища из VECTOR_STORE_FORMAT (по умолчанию gob)
+func GetSerializationFormat() string {
+	if format := os.Getenv("VECTOR_STORE_FORMAT"); format != "" {
+		return format
+	}
+	return FormatGob
+}
+
+// Serialize кодирует все документы хранилища в указанном формате
+func (vs *VectorStore) Serialize(format string) ([]byte, error) {
+	documents := vs.Snapshot()
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatJSON:
+		if err := json.NewEncoder(&buf).Encode(documents); err != nil {
+			return nil, fmt.Errorf("ошибка сериализации в JSON: %w", err)
+		}
+	case FormatGob:
+		if err := gob.NewEncoder(&buf).Encode(documents); err != nil {
+			return nil, fmt.Errorf("ошибка сериализации в gob: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Deserialize заменяет документы хранилища данными, закодированными Serialize в том же формате.
+// При ошибке содержимое хранилища не меняется.
+func (vs *VectorStore) Deserialize(data []byte, format string) error {
+	var documents []types.Document
+
+	switch format {
+	case FormatJSON:
+		if err := json.Unmarshal(data, &documents); err != nil {
+			return fmt.Errorf("ошибка десериализации JSON: %w", err)
+		}
+	case FormatGob:
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&documents); err != nil {
+			return fmt.Errorf("ошибка десериализации gob: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	if documents == nil {
+		documents = make([]types.Document, 0)
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.documents = documents
+	vs.rebuildURLIndex()
+	return nil
+}
@@ -1,49 +1,364 @@
 package vectorstore
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"math"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ad/rag-bot/internal/telemetry"
 	"github.com/ad/rag-bot/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer создает spans поиска (см. telemetry.Setup)
+var tracer = otel.Tracer("github.com/ad/rag-bot/internal/vectorstore")
+
 type VectorStore struct {
 	documents []types.Document
+	urlIndex  map[string]int // URL -> индекс первого документа с этим URL в documents
+	mu        sync.RWMutex
+
+	lastSearch   []string // ID документов из результатов последнего поиска (для отладки)
+	lastSearchMu sync.Mutex
+
+	queryCache queryCache // эмбеддинги запросов, см. QueryEmbedding
+
+	similarityThreshold float32
+	boostFactor         float32
+	logger              *slog.Logger
+	metricsRegisterer   prometheus.Registerer
+	metrics             *storeMetrics
 }
 
 type SearchResult struct {
 	Document types.Document
 	Score    float32
+
+	// TopDimensions - номера измерений эмбеддинга, сильнее всего повлиявших на скалярное произведение
+	// с запросом (по модулю произведения), по убыванию вклада. Поиск его не заполняет: объяснение
+	// дорогое и нужно только для отладки, см. ExplainResults.
+	TopDimensions []int
 }
 
-func NewVectorStore() *VectorStore {
-	return &VectorStore{
-		documents: make([]types.Document, 0),
+// topDimensionsCount - сколько измерений объяснения сохраняется в SearchResult.TopDimensions
+const topDimensionsCount = 5
+
+func NewVectorStore(opts ...VectorStoreOption) *VectorStore {
+	vs := &VectorStore{
+		documents:           make([]types.Document, 0),
+		urlIndex:            make(map[string]int),
+		similarityThreshold: defaultSimilarityThreshold,
+		boostFactor:         defaultBoostFactor,
+		logger:              slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(vs)
+	}
+
+	if vs.metricsRegisterer != nil {
+		vs.registerMetrics(vs.metricsRegisterer)
 	}
+
+	return vs
 }
 
 func (vs *VectorStore) AddDocument(doc types.Document) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
 	vs.documents = append(vs.documents, doc)
+	vs.indexURL(len(vs.documents) - 1)
 }
 
 func (vs *VectorStore) AddDocuments(docs []types.Document) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
 	vs.documents = append(vs.documents, docs...)
+	for i := len(vs.documents) - len(docs); i < len(vs.documents); i++ {
+		vs.indexURL(i)
+	}
+}
+
+// Reset атомарно заменяет все документы хранилища на docs (для перезагрузки без простоя).
+// Поиски, начатые до вызова, завершаются на старом наборе документов, начатые после - видят новый;
+// промежуточного состояния, в котором часть документов уже удалена или еще не добавлена, нет.
+func (vs *VectorStore) Reset(docs []types.Document) {
+	documents := make([]types.Document, len(docs))
+	copy(documents, docs)
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.documents = documents
+	vs.rebuildURLIndex()
+}
+
+// AddDocumentIfNew добавляет документ, только если в хранилище нет документа с тем же ID или URL.
+// Возвращает true, если документ добавлен.
+func (vs *VectorStore) AddDocumentIfNew(doc types.Document) bool {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if doc.URL != "" {
+		if _, exists := vs.urlIndex[doc.URL]; exists {
+			return false
+		}
+	}
+	if vs.indexOf(doc.ID) >= 0 {
+		return false
+	}
+
+	vs.documents = append(vs.documents, doc)
+	vs.indexURL(len(vs.documents) - 1)
+	return true
+}
+
+// UpsertDocument заменяет документ с тем же ID на месте или добавляет новый.
+// Возвращает true, если документ был заменен.
+func (vs *VectorStore) UpsertDocument(doc types.Document) bool {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	i := vs.indexOf(doc.ID)
+	if i < 0 {
+		vs.documents = append(vs.documents, doc)
+		vs.indexURL(len(vs.documents) - 1)
+		return false
+	}
+
+	oldURL := vs.documents[i].URL
+	vs.documents[i] = doc
+	if oldURL != doc.URL {
+		vs.rebuildURLIndex()
+	}
+	return true
+}
+
+// indexOf возвращает индекс документа с указанным ID или -1. Вызывается под блокировкой.
+func (vs *VectorStore) indexOf(id string) int {
+	for i, doc := range vs.documents {
+		if doc.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// DeleteDocument удаляет документ по ID; возвращает false, если документа нет
+func (vs *VectorStore) DeleteDocument(id string) bool {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	i := vs.indexOf(id)
+	if i < 0 {
+		return false
+	}
+
+	vs.documents = append(vs.documents[:i], vs.documents[i+1:]...)
+	// Индексы документов после удаленного сместились
+	vs.rebuildURLIndex()
+	return true
+}
+
+// FindByURL возвращает документ с указанным URL
+func (vs *VectorStore) FindByURL(url string) (types.Document, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	if i, ok := vs.urlIndex[url]; ok {
+		return vs.documents[i], true
+	}
+	return types.Document{}, false
+}
+
+// indexURL добавляет документ с индексом i в urlIndex, если его URL еще не проиндексирован.
+// Вызывается под блокировкой.
+func (vs *VectorStore) indexURL(i int) {
+	url := vs.documents[i].URL
+	if url == "" {
+		return
+	}
+	if _, exists := vs.urlIndex[url]; !exists {
+		vs.urlIndex[url] = i
+	}
+}
+
+// rebuildURLIndex заново строит urlIndex по текущему списку документов. Вызывается под блокировкой.
+func (vs *VectorStore) rebuildURLIndex() {
+	vs.urlIndex = make(map[string]int, len(vs.documents))
+	for i := range vs.documents {
+		vs.indexURL(i)
+	}
+}
+
+// Snapshot возвращает копию списка документов, безопасную для чтения без блокировки
+func (vs *VectorStore) Snapshot() []types.Document {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	documents := make([]types.Document, len(vs.documents))
+	copy(documents, vs.documents)
+	return documents
+}
+
+// Filter возвращает новое хранилище только с документами, удовлетворяющими условию
+func (vs *VectorStore) Filter(predicate func(types.Document) bool) *VectorStore {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	// Метрики не наследуются, чтобы не регистрировать их повторно
+	filtered := NewVectorStore(WithSimilarityThreshold(vs.similarityThreshold), WithBoostFactor(vs.boostFactor), WithLogger(vs.logger))
+	for _, doc := range vs.documents {
+		if predicate(doc) {
+			filtered.documents = append(filtered.documents, doc)
+		}
+	}
+	filtered.rebuildURLIndex()
+
+	return filtered
 }
 
 func (vs *VectorStore) Search(queryEmbedding []float32, topK int) ([]SearchResult, error) {
-	if len(vs.documents) == 0 {
-		return nil, fmt.Errorf("векторное хранилище пустое")
+	return vs.search(queryEmbedding, nil, topK)
+}
+
+// SearchWithBoost ищет как Search, но умножает скор документов из boostDocIDs на множитель (см. WithBoostFactor),
+// чтобы статья, которую пользователь уже обсуждает, оставалась выше в уточняющих запросах.
+// Порог сходства применяется к скору без усиления.
+// Поиск записывается в трассировку как span vectorstore.search.
+func (vs *VectorStore) SearchWithBoost(ctx context.Context, queryEmbedding []float32, boostDocIDs []string, topK int) ([]SearchResult, error) {
+	_, span := tracer.Start(ctx, "vectorstore.search", trace.WithAttributes(
+		attribute.Int("vectorstore.top_k", topK),
+		attribute.Int("vectorstore.boosted", len(boostDocIDs)),
+	))
+
+	boost := make(map[string]bool, len(boostDocIDs))
+	for _, id := range boostDocIDs {
+		boost[id] = true
 	}
 
-	if len(queryEmbedding) == 0 {
-		return nil, fmt.Errorf("эмбеддинг запроса пустой")
+	results, err := vs.search(queryEmbedding, boost, topK)
+	span.SetAttributes(attribute.Int("vectorstore.results", len(results)))
+	telemetry.EndSpan(span, err)
+	return results, err
+}
+
+// SearchRadius возвращает все документы со сходством выше radius, отсортированные по убыванию скора,
+// но не больше GetMaxRadiusResults(). В отличие от Search, отсутствие подходящих документов не ошибка.
+func (vs *VectorStore) SearchRadius(queryEmbedding []float32, radius float32) ([]SearchResult, error) {
+	return vs.searchRadius(queryEmbedding, nil, radius)
+}
+
+// SearchRadiusWithBoost ищет как SearchRadius с усилением скора как в SearchWithBoost.
+// Радиус применяется к скору без усиления.
+func (vs *VectorStore) SearchRadiusWithBoost(ctx context.Context, queryEmbedding []float32, boostDocIDs []string, radius float32) ([]SearchResult, error) {
+	_, span := tracer.Start(ctx, "vectorstore.search_radius", trace.WithAttributes(
+		attribute.Float64("vectorstore.radius", float64(radius)),
+		attribute.Int("vectorstore.boosted", len(boostDocIDs)),
+	))
+
+	boost := make(map[string]bool, len(boostDocIDs))
+	for _, id := range boostDocIDs {
+		boost[id] = true
+	}
+
+	results, err := vs.searchRadius(queryEmbedding, boost, radius)
+	span.SetAttributes(attribute.Int("vectorstore.results", len(results)))
+	telemetry.EndSpan(span, err)
+	return results, err
+}
+
+func (vs *VectorStore) searchRadius(queryEmbedding []float32, boost map[string]bool, radius float32) ([]SearchResult, error) {
+	results, err := vs.scoreDocuments(queryEmbedding, boost, radius)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxResults := GetMaxRadiusResults(); len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	return results, nil
+}
+
+func (vs *VectorStore) search(queryEmbedding []float32, boost map[string]bool, topK int) ([]SearchResult, error) {
+	results, err := vs.scoreDocuments(queryEmbedding, boost, vs.similarityThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("не найдено релевантных документов")
 	}
 
 	if topK <= 0 {
 		topK = 5
 	}
 
+	// Возвращаем топ-K результатов
+	if topK > len(results) {
+		topK = len(results)
+	}
+
+	return results[:topK], nil
+}
+
+// ExplainResults заполняет TopDimensions у результатов поиска по запросу с эмбеддингом queryEmbedding.
+// Сортирует все измерения каждого документа, поэтому вызывается только для отладки (GET /debug/search).
+func ExplainResults(queryEmbedding []float32, results []SearchResult) {
+	for i := range results {
+		results[i].TopDimensions = topDimensions(queryEmbedding, results[i].Document.Embedding, topDimensionsCount)
+	}
+}
+
+// topDimensions возвращает n измерений с наибольшим |a[i]*b[i]|
+func topDimensions(a, b []float32, n int) []int {
+	if len(a) != len(b) || len(a) == 0 {
+		return nil
+	}
+
+	dims := make([]int, len(a))
+	for i := range dims {
+		dims[i] = i
+	}
+	contribution := func(i int) float64 {
+		return math.Abs(float64(a[i]) * float64(b[i]))
+	}
+	sort.SliceStable(dims, func(i, j int) bool {
+		return contribution(dims[i]) > contribution(dims[j])
+	})
+
+	if n > len(dims) {
+		n = len(dims)
+	}
+	return append([]int(nil), dims[:n]...)
+}
+
+// scoreDocuments возвращает все документы со скором выше threshold, отсортированные по убыванию скора
+func (vs *VectorStore) scoreDocuments(queryEmbedding []float32, boost map[string]bool, threshold float32) ([]SearchResult, error) {
+	defer vs.observeSearch(time.Now())
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	if len(vs.documents) == 0 {
+		return nil, fmt.Errorf("векторное хранилище пустое")
+	}
+
+	if len(queryEmbedding) == 0 {
+		return nil, fmt.Errorf("эмбеддинг запроса пустой")
+	}
+
 	var results []SearchResult
 	documentsWithEmbeddings := 0
 
@@ -56,7 +371,10 @@ func (vs *VectorStore) Search(queryEmbedding []float32, topK int) ([]SearchResul
 		score := cosineSimilarity(queryEmbedding, doc.Embedding)
 
 		// Фильтруем результаты с очень низким скором
-		if score > 0.1 {
+		if score > threshold {
+			if boost[doc.ID] {
+				score *= vs.boostFactor
+			}
 			results = append(results, SearchResult{
 				Document: doc,
 				Score:    score,
@@ -69,7 +387,8 @@ func (vs *VectorStore) Search(queryEmbedding []float32, topK int) ([]SearchResul
 	}
 
 	if len(results) == 0 {
-		return nil, fmt.Errorf("не найдено релевантных документов")
+		vs.logger.Debug("нет результатов выше порога сходства", "threshold", threshold, "documents", documentsWithEmbeddings)
+		return nil, nil
 	}
 
 	// Сортируем по убыванию схожести
@@ -77,7 +396,70 @@ func (vs *VectorStore) Search(queryEmbedding []float32, topK int) ([]SearchResul
 		return results[i].Score > results[j].Score
 	})
 
-	// Возвращаем топ-K результатов
+	vs.rememberLastSearch(results)
+
+	return results, nil
+}
+
+// rememberLastSearch сохраняет порядок документов последнего поиска для DebugDocument
+func (vs *VectorStore) rememberLastSearch(results []SearchResult) {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Document.ID
+	}
+
+	vs.lastSearchMu.Lock()
+	vs.lastSearch = ids
+	vs.lastSearchMu.Unlock()
+}
+
+// GetDocument возвращает документ по ID
+func (vs *VectorStore) GetDocument(id string) (types.Document, bool) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	for _, doc := range vs.documents {
+		if doc.ID == id {
+			return doc, true
+		}
+	}
+
+	return types.Document{}, false
+}
+
+// SearchByKeyword ищет точное (без учета регистра) вхождение ключевого слова в заголовке и тексте документов.
+// Скор равен количеству вхождений, результаты отсортированы по убыванию скора.
+func (vs *VectorStore) SearchByKeyword(keyword string, topK int) ([]SearchResult, error) {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return nil, fmt.Errorf("ключевое слово пустое")
+	}
+
+	if topK <= 0 {
+		topK = 5
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var results []SearchResult
+	for _, doc := range vs.documents {
+		count := strings.Count(strings.ToLower(doc.Title), keyword) +
+			strings.Count(strings.ToLower(doc.Content), keyword)
+		if count == 0 {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Document: doc,
+			Score:    float32(count),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
 	if topK > len(results) {
 		topK = len(results)
 	}
@@ -86,6 +468,9 @@ func (vs *VectorStore) Search(queryEmbedding []float32, topK int) ([]SearchResul
 }
 
 func (vs *VectorStore) GetDocumentCount() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
 	return len(vs.documents)
 }
 
@@ -0,0 +1,473 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ad/rag-bot/internal/types"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float32
+	}{
+		{"параллельные", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"перпендикулярные", []float32{1, 0}, []float32{0, 1}, 0},
+		{"противоположные", []float32{1, 2, 3}, []float32{-1, -2, -3}, -1},
+		{"пустые", []float32{}, []float32{}, 0},
+		{"nil", nil, nil, 0},
+		{"нулевой вектор", []float32{0, 0}, []float32{1, 1}, 0},
+		{"разная размерность", []float32{1, 2, 3}, []float32{1, 2}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cosineSimilarity(tt.a, tt.b)
+			if math.Abs(float64(got-tt.want)) > 1e-6 {
+				t.Errorf("cosineSimilarity = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	query := []float32{1, 0}
+
+	tests := []struct {
+		name    string
+		docs    []types.Document
+		topK    int
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "пустое хранилище",
+			topK:    2,
+			wantErr: true,
+		},
+		{
+			name: "нет эмбеддингов",
+			docs: []types.Document{
+				{ID: "a"},
+				{ID: "b"},
+			},
+			topK:    2,
+			wantErr: true,
+		},
+		{
+			name: "все скоры ниже порога",
+			docs: []types.Document{
+				{ID: "a", Embedding: []float32{0, 1}},
+				{ID: "b", Embedding: []float32{-1, 0}},
+			},
+			topK:    2,
+			wantErr: true,
+		},
+		{
+			name: "topK больше числа результатов",
+			docs: []types.Document{
+				{ID: "a", Embedding: []float32{1, 0}},
+				{ID: "b", Embedding: []float32{0, 1}},
+			},
+			topK: 5,
+			want: []string{"a"},
+		},
+		{
+			name: "порядок по убыванию скора",
+			docs: []types.Document{
+				{ID: "low", Embedding: []float32{1, 2}},
+				{ID: "best", Embedding: []float32{1, 0}},
+				{ID: "middle", Embedding: []float32{2, 1}},
+				{ID: "none"},
+			},
+			topK: 2,
+			want: []string{"best", "middle"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := NewVectorStore()
+			vs.AddDocuments(tt.docs)
+
+			results, err := vs.Search(query, tt.topK)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получено %d результатов", len(results))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+
+			if len(results) != len(tt.want) {
+				t.Fatalf("получено %d результатов, ожидалось %d", len(results), len(tt.want))
+			}
+			for i, result := range results {
+				if result.Document.ID != tt.want[i] {
+					t.Errorf("результат %d = %s, ожидался %s", i, result.Document.ID, tt.want[i])
+				}
+				if i > 0 && result.Score > results[i-1].Score {
+					t.Errorf("результаты не отсортированы: %v > %v", result.Score, results[i-1].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	vs := NewVectorStore()
+	vs.AddDocument(types.Document{ID: "a", Embedding: []float32{1, 0}})
+
+	if _, err := vs.Search(nil, 1); err == nil {
+		t.Error("ожидалась ошибка для пустого эмбеддинга запроса")
+	}
+}
+
+// Запускать с go test -race: поиск, добавление и удаление документов идут параллельно
+func TestConcurrentAccess(t *testing.T) {
+	vs := NewVectorStore()
+	vs.AddDocument(types.Document{ID: "seed", URL: "https://example.com/seed", Embedding: []float32{1, 0}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+
+		go func(i int) {
+			defer wg.Done()
+			vs.AddDocument(types.Document{
+				ID:        fmt.Sprintf("doc-%d", i),
+				URL:       fmt.Sprintf("https://example.com/%d", i),
+				Embedding: []float32{1, float32(i)},
+			})
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			if _, err := vs.Search([]float32{1, 0}, 3); err != nil {
+				t.Errorf("неожиданная ошибка поиска: %v", err)
+			}
+			vs.FindByURL("https://example.com/seed")
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+			vs.DeleteDocument(fmt.Sprintf("doc-%d", i-1))
+		}(i)
+	}
+	wg.Wait()
+
+	if _, ok := vs.FindByURL("https://example.com/seed"); !ok {
+		t.Error("документ seed не найден по URL после параллельных изменений")
+	}
+}
+
+func TestAddDocumentIfNew(t *testing.T) {
+	vs := NewVectorStore()
+
+	if !vs.AddDocumentIfNew(types.Document{ID: "a", URL: "https://example.com/a"}) {
+		t.Fatal("новый документ не добавлен")
+	}
+	if vs.AddDocumentIfNew(types.Document{ID: "b", URL: "https://example.com/a"}) {
+		t.Error("добавлен документ с существующим URL")
+	}
+	if vs.AddDocumentIfNew(types.Document{ID: "a", URL: "https://example.com/other"}) {
+		t.Error("добавлен документ с существующим ID")
+	}
+	if count := vs.GetDocumentCount(); count != 1 {
+		t.Errorf("документов в хранилище %d, ожидался 1", count)
+	}
+}
+
+func TestUpsertDocument(t *testing.T) {
+	vs := NewVectorStore()
+	vs.AddDocument(types.Document{ID: "a", URL: "https://example.com/old", Title: "старый"})
+
+	if !vs.UpsertDocument(types.Document{ID: "a", URL: "https://example.com/new", Title: "новый"}) {
+		t.Error("существующий документ не заменен")
+	}
+	if vs.UpsertDocument(types.Document{ID: "b"}) {
+		t.Error("новый документ отмечен как замена")
+	}
+	if count := vs.GetDocumentCount(); count != 2 {
+		t.Errorf("документов в хранилище %d, ожидалось 2", count)
+	}
+
+	if doc, ok := vs.FindByURL("https://example.com/new"); !ok || doc.Title != "новый" {
+		t.Errorf("по новому URL найден %+v (%v), ожидался замененный документ", doc, ok)
+	}
+	if _, ok := vs.FindByURL("https://example.com/old"); ok {
+		t.Error("документ находится по старому URL после замены")
+	}
+}
+
+func TestSearchRadius(t *testing.T) {
+	vs := NewVectorStore()
+	vs.AddDocuments([]types.Document{
+		{ID: "low", Embedding: []float32{1, 2}},
+		{ID: "best", Embedding: []float32{1, 0}},
+		{ID: "middle", Embedding: []float32{2, 1}},
+		{ID: "orthogonal", Embedding: []float32{0, 1}},
+	})
+
+	results, err := vs.SearchRadius([]float32{1, 0}, 0.5)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	want := []string{"best", "middle"}
+	if len(results) != len(want) {
+		t.Fatalf("получено %d результатов, ожидалось %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Document.ID != want[i] {
+			t.Errorf("результат %d = %s, ожидался %s", i, result.Document.ID, want[i])
+		}
+	}
+
+	results, err = vs.SearchRadius([]float32{1, 0}, 0.99999)
+	if err != nil || len(results) != 1 {
+		t.Errorf("для радиуса 0.99999 получено %d результатов (%v), ожидался 1", len(results), err)
+	}
+
+	if results, err := vs.SearchRadius([]float32{-1, 0}, 0.5); err != nil || len(results) != 0 {
+		t.Errorf("ожидался пустой результат без ошибки, получено %d результатов (%v)", len(results), err)
+	}
+}
+
+func TestSearchRadiusMaxResults(t *testing.T) {
+	t.Setenv("MAX_RADIUS_RESULTS", "3")
+
+	vs := NewVectorStore()
+	for i := 0; i < 10; i++ {
+		vs.AddDocument(types.Document{ID: fmt.Sprintf("doc-%d", i), Embedding: []float32{1, float32(i) / 10}})
+	}
+
+	results, err := vs.SearchRadius([]float32{1, 0}, 0)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("получено %d результатов, ожидалось 3", len(results))
+	}
+}
+
+func TestFindNearDuplicates(t *testing.T) {
+	article := "Чтобы подключить домен, откройте раздел настроек сайта, выберите пункт домены и нажмите кнопку подключить. " +
+		"После этого укажите имя домена и дождитесь обновления записей DNS, обычно это занимает до суток."
+
+	vs := NewVectorStore()
+	vs.AddDocuments([]types.Document{
+		{ID: "original", SimHash: types.SimHash(article)},
+		{ID: "edited", SimHash: types.SimHash(article + " Обновлено")},
+		{ID: "other", SimHash: types.SimHash("Тарифы оплачиваются банковской картой или по счету для юридических лиц, чек приходит на почту.")},
+		{ID: "empty"},
+	})
+
+	groups := vs.FindNearDuplicates(5)
+	if len(groups) != 1 {
+		t.Fatalf("найдено %d групп, ожидалась 1: %v", len(groups), groups)
+	}
+	if got := groups[0]; len(got) != 2 || got[0] != "edited" || got[1] != "original" {
+		t.Errorf("группа %v, ожидалась [edited original]", got)
+	}
+
+	if groups := vs.FindNearDuplicates(-1); len(groups) != 0 {
+		t.Errorf("при отрицательном пороге найдены группы: %v", groups)
+	}
+}
+
+// countingEmbedder считает обращения к модели эмбеддингов
+type countingEmbedder struct {
+	calls atomic.Int32
+}
+
+func (e *countingEmbedder) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	e.calls.Add(1)
+	if text == "ошибка" {
+		return nil, fmt.Errorf("модель недоступна")
+	}
+	return []float32{float32(len(text)), 1}, nil
+}
+
+func TestWarmSearchCache(t *testing.T) {
+	vs := NewVectorStore()
+	embedder := &countingEmbedder{}
+
+	warmed := vs.WarmSearchCache(context.Background(), []string{"Как сбросить пароль", "", "ошибка", "Оплата картой"}, embedder)
+	if warmed != 2 {
+		t.Errorf("прогрето %d запросов, ожидалось 2", warmed)
+	}
+	calls := embedder.calls.Load()
+
+	if _, err := vs.QueryEmbedding(context.Background(), "  как сбросить   ПАРОЛЬ ", embedder); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if embedder.calls.Load() != calls {
+		t.Error("эмбеддинг прогретого запроса сгенерирован повторно")
+	}
+
+	if _, err := vs.QueryEmbedding(context.Background(), "новый вопрос", embedder); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if embedder.calls.Load() != calls+1 {
+		t.Error("эмбеддинг нового запроса не сгенерирован")
+	}
+}
+
+func TestSearchTopDimensions(t *testing.T) {
+	vs := NewVectorStore()
+	vs.AddDocument(types.Document{ID: "a", Embedding: []float32{0.1, 3, 0, -2, 0.5, 1, 0.2}})
+
+	query := []float32{1, 1, 1, 1, 1, 1, 1}
+	results, err := vs.Search(query, 1)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if results[0].TopDimensions != nil {
+		t.Error("Search не должен вычислять TopDimensions")
+	}
+
+	ExplainResults(query, results)
+	want := []int{1, 3, 5, 4, 6}
+	got := results[0].TopDimensions
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("TopDimensions = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	vs := NewVectorStore()
+	vs.AddDocuments([]types.Document{
+		{ID: "old", URL: "https://example.com/old", Embedding: []float32{1, 0}},
+		{ID: "kept", URL: "https://example.com/kept", Embedding: []float32{0, 1}},
+	})
+
+	docs := []types.Document{
+		{ID: "kept", URL: "https://example.com/kept", Embedding: []float32{0, 1}},
+		{ID: "new", URL: "https://example.com/new", Embedding: []float32{1, 0}},
+	}
+	vs.Reset(docs)
+	docs[1].ID = "changed"
+
+	if count := vs.GetDocumentCount(); count != 2 {
+		t.Errorf("документов в хранилище %d, ожидалось 2", count)
+	}
+	if _, ok := vs.FindByURL("https://example.com/old"); ok {
+		t.Error("документ находится по URL после замены набора документов")
+	}
+	if doc, ok := vs.FindByURL("https://example.com/new"); !ok || doc.ID != "new" {
+		t.Errorf("по новому URL найден %+v (%v), ожидался документ new", doc, ok)
+	}
+
+	results, err := vs.Search([]float32{1, 0}, 1)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if results[0].Document.ID != "new" {
+		t.Errorf("найден %s, ожидался new", results[0].Document.ID)
+	}
+}
+
+// Запускать с go test -race: поиск не должен видеть промежуточного состояния при замене документов
+func TestResetConcurrentSearch(t *testing.T) {
+	first := []types.Document{{ID: "a1", Embedding: []float32{1, 0}}, {ID: "a2", Embedding: []float32{1, 0.1}}}
+	second := []types.Document{{ID: "b1", Embedding: []float32{1, 0}}, {ID: "b2", Embedding: []float32{1, 0.1}}}
+
+	vs := NewVectorStore()
+	vs.Reset(first)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				vs.Reset(second)
+			} else {
+				vs.Reset(first)
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			results, err := vs.Search([]float32{1, 0}, 2)
+			if err != nil {
+				t.Errorf("неожиданная ошибка поиска: %v", err)
+				return
+			}
+			if len(results) != 2 || results[0].Document.ID[0] != results[1].Document.ID[0] {
+				t.Errorf("результаты из разных наборов документов: %v, %v", results[0].Document.ID, results[len(results)-1].Document.ID)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCluster(t *testing.T) {
+	tests := []struct {
+		name string
+		docs []types.Document
+		k    int
+		want int // сколько документов в первом кластере
+	}{
+		{
+			name: "две темы",
+			docs: []types.Document{
+				{ID: "pay", Embedding: []float32{1, 0}},
+				{ID: "refund", Embedding: []float32{0.9, 0.1}},
+				{ID: "domain", Embedding: []float32{0, 1}},
+			},
+			k:    2,
+			want: 2,
+		},
+		{
+			// Все документы ближе всего к одному центроиду: остальные кластеры заполняются переносом
+			name: "одинаковые эмбеддинги",
+			docs: []types.Document{
+				{ID: "a", Embedding: []float32{1, 0}},
+				{ID: "b", Embedding: []float32{1, 0}},
+				{ID: "c", Embedding: []float32{1, 0}},
+				{ID: "d", Embedding: []float32{1, 0}},
+			},
+			k:    3,
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := NewVectorStore()
+			vs.AddDocuments(tt.docs)
+
+			clusters, err := vs.Cluster(tt.k)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if len(clusters) != tt.k {
+				t.Fatalf("кластеров %d, ожидалось %d", len(clusters), tt.k)
+			}
+
+			total := 0
+			for i, cluster := range clusters {
+				if len(cluster) == 0 {
+					t.Errorf("кластер %d пуст", i)
+				}
+				total += len(cluster)
+			}
+			if total != len(tt.docs) {
+				t.Errorf("в кластерах %d документов, ожидалось %d", total, len(tt.docs))
+			}
+			if len(clusters[0]) != tt.want {
+				t.Errorf("в первом кластере %d документов, ожидалось %d", len(clusters[0]), tt.want)
+			}
+		})
+	}
+}
@@ -2,23 +2,37 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"github.com/ad/rag-bot/internal/api"
 	"github.com/ad/rag-bot/internal/cache"
+	"github.com/ad/rag-bot/internal/config"
+	"github.com/ad/rag-bot/internal/format"
 	"github.com/ad/rag-bot/internal/llm"
 	"github.com/ad/rag-bot/internal/parser"
+	"github.com/ad/rag-bot/internal/querylog"
 	"github.com/ad/rag-bot/internal/retrieval"
+	"github.com/ad/rag-bot/internal/telemetry"
+	"github.com/ad/rag-bot/internal/types"
 	"github.com/ad/rag-bot/internal/vectorstore"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
-	"github.com/microcosm-cc/bluemonday"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/html"
 
 	"github.com/gomarkdown/markdown"
@@ -28,28 +42,180 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 )
 
+const categoryOffTopic = "off-topic"
+
+// Категории для маршрутизации запросов перед поиском документов
+var queryCategories = []string{"technical", "billing", "greeting", categoryOffTopic}
+
+// warmTopQueries - сколько самых частых вопросов из журнала запросов прогревается при запуске
+const warmTopQueries = 50
+
+// prewarmTopQueries - сколько самых частых вопросов прогревается по расписанию PREWARM_SCHEDULE
+const prewarmTopQueries = 20
+
+// tracer создает корневой span обработки сообщения (см. telemetry.Setup)
+var tracer = otel.Tracer("github.com/ad/rag-bot")
+
 func main() {
+	configFile := flag.String("config", config.GetConfigFile(), "YAML-файл настроек (по умолчанию CONFIG_FILE)")
+	dataDir := flag.String("data", "", "Папка с документами (по умолчанию DATA_DIR или data)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки настроек: %v", err)
+	}
+	if *dataDir != "" {
+		cfg.DataDir = *dataDir
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Некорректные настройки: %v", err)
+	}
+	if len(cfg.FileKeys) > 0 {
+		fmt.Printf("Из файла %s загружены настройки: %s\n", *configFile, strings.Join(cfg.FileKeys, ", "))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	shutdownTracing, err := telemetry.Setup(ctx, "rag-bot")
+	if err != nil {
+		log.Printf("Ошибка настройки трассировки (трассировка выключена): %v", err)
+	} else {
+		if endpoint := telemetry.GetOTLPEndpoint(); endpoint != "" {
+			fmt.Printf("Трассировки отправляются в %s\n", endpoint)
+		}
+		defer func() {
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelShutdown()
+			if err := shutdownTracing(shutdownCtx); err != nil {
+				log.Printf("Ошибка отправки трассировок: %v", err)
+			}
+		}()
+	}
+
+	var offset int64
+	for {
+		err := runBot(ctx, cfg, offset)
+
+		var restart *restartError
+		if errors.As(err, &restart) {
+			log.Println("Перезапуск бота...")
+			offset = restart.UpdateID
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+}
+
+// runBot загружает документы, генерирует эмбеддинги и запускает бота до отмены ctx.
+// Команда /restart останавливает запуск и возвращает *restartError; initialOffset - последнее
+// уже обработанное обновление Telegram.
+func runBot(ctx context.Context, cfg *config.Config, initialOffset int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Метрики снимаются с регистрации при выходе, чтобы следующий запуск зарегистрировал их заново
+	registerer := newRunRegisterer(prometheus.DefaultRegisterer)
+	defer registerer.unregisterAll()
+
 	rateLimiter := NewRateLimiter()
+	groupRateLimiter := NewGroupRateLimiter(GetGroupRateLimit())
+	conversationHistory := NewConversationHistory()
+	settings := NewSettings()
 
 	// 1. Сначала инициализируем LLM
-	llmEngine := llm.NewHTTPLLM(llm.GetApiURL())
+	if dir := llm.GetPromptTemplateDir(); dir != "" {
+		loaded, err := llm.LoadPromptTemplates(dir)
+		if err != nil {
+			log.Printf("Ошибка загрузки шаблонов промптов (используются встроенные): %v", err)
+		}
+		if len(loaded) > 0 {
+			fmt.Printf("Загружены шаблоны промптов из %s: %s\n", dir, strings.Join(loaded, ", "))
+		}
+	}
+	var llmOptions []llm.Option
+	if userAgent := os.Getenv("LLM_USER_AGENT"); userAgent != "" {
+		llmOptions = append(llmOptions, llm.WithUserAgent(userAgent))
+	}
+	httpEngine := llm.NewHTTPLLM(llm.GetApiURL(), llmOptions...)
+	var llmEngine llm.LLMEngine = httpEngine
+
+	// Журнал аудита всех вызовов LLM включается только явно
+	if auditLogPath := llm.GetAuditLogPath(); auditLogPath != "" {
+		auditingEngine := llm.NewAuditingEngine(llmEngine, auditLogPath)
+		defer auditingEngine.Close()
+		llmEngine = auditingEngine
+		fmt.Printf("Журнал аудита LLM: %s\n", auditLogPath)
+	}
+
+	// Ollama может запускаться дольше бота (Docker Compose): ждем ее, а не падаем сразу
+	retryInterval, retryTimeout := llm.GetStartupRetry()
+	if err := httpEngine.WaitForOllama(ctx, retryInterval, retryTimeout); err != nil {
+		return err
+	}
 
 	// 2. Инициализируем векторную систему и кэш
 	fmt.Println("Инициализация векторной системы...")
 	markdownParser := parser.NewMarkdownParser()
-	vectorStore := vectorstore.NewVectorStore()
-	embeddingCache := cache.NewEmbeddingCache("cache/embeddings.json")
+	markdownParser.ExtractCodeSnippets = os.Getenv("PARSER_EXTRACT_CODE") == "true"
+	markdownParser.CSVTitleColumn = os.Getenv("CSV_TITLE_COLUMN")
+	markdownParser.CSVContentColumn = os.Getenv("CSV_CONTENT_COLUMN")
+	markdownParser.CSVURLColumn = os.Getenv("CSV_URL_COLUMN")
+	markdownParser.CSVMetadataColumns = os.Getenv("CSV_METADATA_COLUMNS") == "true"
+	markdownParser.DeduplicateContent = os.Getenv("PARSER_DEDUPLICATE") == "true"
+	markdownParser.SortFiles = os.Getenv("PARSER_SORT_FILES") == "true"
+	if err := markdownParser.CheckTransformers(); err != nil {
+		return fmt.Errorf("ошибка CONTENT_TRANSFORMERS: %w", err)
+	}
+	vectorStore := vectorstore.NewVectorStore(
+		vectorstore.WithMetrics(registerer),
+		vectorstore.WithBoostFactor(vectorstore.GetBoostFactor()),
+	)
+	cacheOptions := []cache.CacheOption{cache.WithMaxEntries(cache.GetCacheMaxEntries())}
+	// Общий кэш в Redis нужен, когда несколько экземпляров бота работают за балансировщиком
+	switch backend := cache.GetCacheBackend(); backend {
+	case "file":
+	case "redis":
+		redisCache, err := cache.NewRedisEmbeddingCache(cache.GetRedisURL(), cache.GetRedisKeyPrefix())
+		if err != nil {
+			return fmt.Errorf("ошибка подключения к кэшу Redis: %w", err)
+		}
+		defer redisCache.Close()
+		cacheOptions = append(cacheOptions, cache.WithBackend(redisCache))
+		fmt.Printf("Кэш эмбеддингов хранится в Redis (префикс ключей %s)\n", cache.GetRedisKeyPrefix())
+	default:
+		return fmt.Errorf("неизвестный CACHE_BACKEND: %s", backend)
+	}
+	embeddingCache := cache.NewEmbeddingCache("cache/embeddings.json", cacheOptions...)
 
 	// 3. Загружаем и обрабатываем документы
-	documents, err := markdownParser.ParseDirectory("data")
+	documents, parseStats, err := markdownParser.ParseDirectory(cfg.DataDir)
 	if err != nil {
-		log.Fatalf("Ошибка загрузки документов: %v", err)
+		return fmt.Errorf("ошибка загрузки документов: %w", err)
 	}
 
 	fmt.Printf("Загружено документов: %d\n", len(documents))
+	fmt.Printf("Файлов: %d, пропущено: %d, документов без текста: %d, слов: %d (в среднем %d на документ)\n",
+		parseStats.Total, parseStats.Skipped, parseStats.ZeroContent, parseStats.TotalWordCount, parseStats.AverageWordCount)
 
 	if len(documents) == 0 {
-		log.Fatal("Не найдено документов для обработки в папке data/")
+		return fmt.Errorf("не найдено документов для обработки в папке %s", cfg.DataDir)
+	}
+
+	// Загружаем из кэша только эмбеддинги документов, которые есть в data/
+	documentIDs := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		documentIDs = append(documentIDs, doc.ID)
+	}
+	if err := embeddingCache.Preload(documentIDs); errors.Is(err, cache.ErrUnsupportedVersion) {
+		// Кэш записан другой версией бота: работать без него значит пересчитать и затереть его
+		return fmt.Errorf("ошибка загрузки кэша эмбеддингов: %w", err)
+	} else if err != nil {
+		log.Printf("Ошибка предзагрузки кэша (будет загружен полностью): %v", err)
 	}
 
 	// Показываем статистику кэша
@@ -90,7 +256,7 @@ func main() {
 		}
 
 		// Если в кэше нет, генерируем новый эмбеддинг
-		embedding, err := llmEngine.GenerateEmbedding(text)
+		embedding, err := llmEngine.GenerateEmbedding(ctx, text)
 		if err != nil {
 			log.Printf("Ошибка генерации эмбеддинга для %s: %v", doc.ID, err)
 			continue
@@ -113,7 +279,7 @@ func main() {
 	}
 
 	if successCount == 0 {
-		log.Fatal("Не удалось сгенерировать эмбеддинги ни для одного документа")
+		return errors.New("не удалось сгенерировать эмбеддинги ни для одного документа")
 	} else {
 		embeddingCache.FlushCache() // Сбрасываем кэш каждые 10 документов
 	}
@@ -122,18 +288,101 @@ func main() {
 	fmt.Printf("Инициализация завершена. Документов с эмбеддингами в хранилище: %d\n", successCount)
 	fmt.Printf("Статистика кэша: %d попаданий, %d новых эмбеддингов\n", cacheHits, cacheUpdates)
 
+	// Прогреваем модели, чтобы первый запрос не ждал их загрузки
+	if os.Getenv("LLM_WARMUP") == "true" {
+		fmt.Println("Прогрев моделей LLM...")
+		warmupCtx, cancelWarmup := context.WithTimeout(ctx, 5*time.Minute)
+		if err := httpEngine.WarmupModel(warmupCtx); err != nil {
+			log.Printf("Ошибка прогрева моделей: %v", err)
+		}
+		cancelWarmup()
+	}
+
+	// Проверяем, что модель генерации отвечает; без нее бот еще может отвечать из кэша
+	if err := llmEngine.HealthCheck(ctx); err != nil {
+		log.Printf("ВНИМАНИЕ: проверка модели генерации не пройдена: %v", err)
+	} else {
+		fmt.Println("Модель генерации отвечает")
+	}
+
 	// ...existing code для телеграм бота...
 	// 5. Создаем retrieval engine
-	retrievalEngine := retrieval.NewVectorRetrieval(vectorStore, llmEngine)
+	retrievalMode := os.Getenv("RETRIEVAL_MODE")
+	retrievalEngine := newRetrievalEngine(retrievalMode, vectorStore, llmEngine)
+
+	// A/B-тест: половина пользователей получает документы от второй стратегии поиска
+	var abRetrieval *retrieval.ABRetrieval
+	if abMode := retrieval.GetABRetrievalMode(); abMode != "" {
+		fmt.Printf("A/B-тест поиска: %s против %s\n", retrievalModeName(retrievalMode), retrievalModeName(abMode))
+		abRetrieval = retrieval.NewABRetrieval(
+			retrievalEngine, retrievalModeName(retrievalMode),
+			newRetrievalEngine(abMode, vectorStore, llmEngine), retrievalModeName(abMode),
+		)
+		retrievalEngine = abRetrieval
+	}
 
 	// 6. Запуск Telegram-бота
-	tgToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if tgToken == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN is not set")
+	if err := embeddingCache.RegisterMetrics(registerer); err != nil {
+		log.Printf("Ошибка регистрации метрик кэша: %v", err)
+	}
+	if err := rateLimiter.RegisterMetrics(registerer); err != nil {
+		log.Printf("Ошибка регистрации метрик ограничителя запросов: %v", err)
+	}
+
+	// Журнал запросов для /top_queries включается только явно
+	var queryLog *querylog.QueryLog
+	if queryLogPath := querylog.GetQueryLogPath(); queryLogPath != "" {
+		queryLog, err = querylog.Open(queryLogPath)
+		if err != nil {
+			log.Printf("Ошибка открытия журнала запросов: %v", err)
+		} else {
+			defer queryLog.Close()
+			fmt.Printf("Журнал запросов: %s\n", queryLogPath)
+		}
+	}
+
+	// Эмбеддинги частых вопросов считаются в фоне, чтобы первые запросы после перезапуска не ждали модель
+	warmQueries := prewarmQueries(queryLog, warmTopQueries)
+	if len(warmQueries) > 0 {
+		go func() {
+			warmed := vectorStore.WarmSearchCache(ctx, warmQueries, llmEngine)
+			log.Printf("Прогрет кэш эмбеддингов запросов: %d из %d", warmed, len(warmQueries))
+		}()
+	}
+
+	// Эмбеддинги ожидаемых вопросов считаются по расписанию PREWARM_SCHEDULE, до пика обращений
+	if prewarmSchedule, err := retrieval.GetPrewarmSchedule(); err != nil {
+		log.Printf("Прогрев по расписанию выключен: %v", err)
+	} else if len(prewarmSchedule) > 0 {
+		if vectorRetrieval, ok := retrievalEngine.(*retrieval.VectorRetrieval); ok {
+			schedulePrewarm(ctx, prewarmSchedule, func() {
+				vectorRetrieval.PrewarmEmbeddings(ctx, prewarmQueries(queryLog, prewarmTopQueries))
+			})
+		} else {
+			log.Printf("PREWARM_SCHEDULE поддерживается только в режиме векторного поиска без A/B-теста")
+		}
 	}
 
+	ingester := NewIngester(markdownParser, cfg.DataDir, vectorStore, embeddingCache, llmEngine)
+
+	// Обновление с командой /restart; 0 - перезапуск не запрошен
+	var restartUpdateID atomic.Int64
+
 	opts := []bot.Option{
 		bot.WithSkipGetMe(),
+		bot.WithInitialOffset(initialOffset),
+		bot.WithMessageTextHandler("stats", bot.MatchTypeCommandStartOnly, adminOnly(statsHandler(vectorStore, embeddingCache, rateLimiter))),
+		bot.WithMessageTextHandler("document", bot.MatchTypeCommandStartOnly, documentHandler(vectorStore)),
+		bot.WithMessageTextHandler("search", bot.MatchTypeCommandStartOnly, searchHandler(retrievalEngine, rateLimiter, settings)),
+		bot.WithMessageTextHandler("settings", bot.MatchTypeCommandStartOnly, adminOnly(settingsHandler(settings))),
+		bot.WithMessageTextHandler("top_queries", bot.MatchTypeCommandStartOnly, adminOnly(topQueriesHandler(queryLog))),
+		bot.WithMessageTextHandler("ingest_url", bot.MatchTypeCommandStartOnly, adminOnly(ingestURLHandler(ingester))),
+		bot.WithMessageTextHandler("export", bot.MatchTypeCommandStartOnly, adminOnly(exportHandler(vectorStore))),
+		bot.WithCallbackQueryDataHandler(feedbackCallbackPrefix, bot.MatchTypePrefix, feedbackHandler(abRetrieval)),
+		bot.WithMessageTextHandler("restart", bot.MatchTypeCommandStartOnly, adminOnly(restartHandler(func(updateID int64) {
+			restartUpdateID.Store(updateID)
+			cancel()
+		}))),
 		bot.WithDefaultHandler(func(ctx context.Context, b *bot.Bot, update *models.Update) {
 			if update.Message == nil {
 				return
@@ -141,7 +390,23 @@ func main() {
 
 			userID := update.Message.From.ID
 
-			// Rate limiting
+			// Корневой span запроса: в него вкладываются spans выделения сути, эмбеддинга, поиска и ответа
+			ctx, span := tracer.Start(ctx, "bot.handle_message", trace.WithAttributes(
+				attribute.Int64("telegram.user_id", userID),
+				attribute.Int64("telegram.chat_id", update.Message.Chat.ID),
+			))
+			defer span.End()
+
+			// Rate limiting: сначала общий лимит группового чата, затем лимит пользователя
+			if chatType := update.Message.Chat.Type; chatType == models.ChatTypeGroup || chatType == models.ChatTypeSupergroup {
+				if !groupRateLimiter.Allow(update.Message.Chat.ID) {
+					_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+						ChatID: update.Message.Chat.ID,
+						Text:   "Слишком много запросов в этом чате. Попробуйте позже.",
+					})
+					return
+				}
+			}
 			if !rateLimiter.Allow(userID) {
 				_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 					ChatID: update.Message.Chat.ID,
@@ -165,22 +430,70 @@ func main() {
 				return
 			}
 
+			freeText, filters, err := parseUserQuery(query)
+			if err != nil {
+				_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+					ChatID: update.Message.Chat.ID,
+					Text:   "Ошибка в запросе: " + err.Error(),
+				})
+				return
+			}
+
 			// Показываем индикатор печати
 			_, _ = b.SendChatAction(ctx, &bot.SendChatActionParams{
 				ChatID: update.Message.Chat.ID,
 				Action: models.ChatActionTyping,
 			})
 
-			// выделяем суть из вопроса пользователя при помощи ollama
-			essence, err := llmEngine.ExtractEssence(query)
+			// Определяем категорию запроса, чтобы не запускать RAG для нерелевантных вопросов
+			category, err := llmEngine.ClassifyQuery(ctx, freeText, queryCategories)
+			if err != nil {
+				log.Printf("Ошибка классификации запроса: %v", err)
+			} else {
+				log.Printf("Категория запроса: %s", category)
+			}
+
+			if category == categoryOffTopic {
+				_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+					ChatID: update.Message.Chat.ID,
+					Text:   "Я отвечаю только на вопросы о работе с Nethouse. Пожалуйста, задайте вопрос о сервисе, и я постараюсь помочь.",
+				})
+				return
+			}
+
+			// выделяем суть из вопроса пользователя (без фильтров) при помощи ollama
+			essence, err := llmEngine.ExtractEssence(ctx, freeText)
 			if err != nil {
 				log.Printf("Ошибка выделения сути вопроса: %v", err)
-				essence = query // fallback на исходный запрос
+				essence = freeText // fallback на исходный запрос
 			}
 			log.Printf("Суть запроса: %s -> %s", query, essence)
 
+			if queryLog != nil {
+				if err := queryLog.Record(userID, query, essence); err != nil {
+					log.Printf("%v", err)
+				}
+			}
+
 			// Ищем документы
-			docs, err := retrievalEngine.FindRelevantDocuments(essence, 2)
+			var docs []types.Document
+			if explainer, ok := retrievalEngine.(*retrieval.VectorRetrieval); ok && retrieval.IsExplainEnabled() {
+				var explained []retrieval.ExplainedResult
+				explained, err = explainer.FindWithExplanation(ctx, essence, filters, settings.TopK())
+				for _, result := range explained {
+					log.Printf("Документ %s выбран: %s", result.ID, result.Reason)
+					docs = append(docs, result.Document)
+				}
+			} else {
+				// Документы прошлой реплики поднимаются в выдаче, пока пользователь продолжает о них разговор
+				docs, err = retrievalEngine.FindWithContext(ctx, essence, retrieval.SearchOptions{
+					UserID:      userID,
+					History:     conversationHistory.Get(userID),
+					BoostDocIDs: conversationHistory.LastDocuments(userID),
+					Filters:     filters,
+				}, settings.TopK())
+			}
+			conversationHistory.Add(userID, essence)
 			if err != nil {
 				log.Printf("Ошибка поиска документов: %v", err)
 				_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
@@ -200,27 +513,52 @@ func main() {
 
 			log.Printf("Found %d documents for query: %s\n", len(docs), essence)
 
-			// Конвертируем в формат для llm.Answer()
-			var llmDocs []llm.Document
+			docIDs := make([]string, 0, len(docs))
 			for _, doc := range docs {
-				llmDoc := llm.Document{
-					Header: doc.Title,
-					Link:   doc.URL,
-					Text:   doc.Content,
-				}
-				llmDocs = append(llmDocs, llmDoc)
+				docIDs = append(docIDs, doc.ID)
+			}
+			conversationHistory.SetLastDocuments(userID, docIDs)
 
+			llmDocs := llm.DeduplicateDocs(toLLMDocuments(docs))
+			for _, doc := range docs {
 				log.Printf("- %s\n", doc.Title)
 			}
 
 			// Генерируем ответ
-			response, err := llmEngine.Answer(essence, llmDocs)
+			// Ответ с цитатами: источники выводятся нумерованным списком под ответом
+			var response string
+			var citations []llm.Citation
+			if os.Getenv("ANSWER_CITATIONS") == "true" {
+				response, citations, err = llmEngine.AnswerWithCitations(ctx, essence, llmDocs)
+			} else {
+				response, err = llmEngine.Answer(ctx, essence, llmDocs)
+			}
 			if err != nil {
 				log.Printf("Ошибка генерации ответа: %v", err)
 				response = "Ошибка при генерации ответа."
 			}
 
-			response = TelegramSupportedHTML(string(mdToHTML([]byte(truncateText(response, 4000)))))
+			// Предлагаем связанные вопросы; клавиатура скрывается после выбора
+			var replyMarkup models.ReplyMarkup
+			if err == nil && os.Getenv("SUGGEST_FOLLOW_UPS") == "true" {
+				replyMarkup = &models.ReplyKeyboardRemove{RemoveKeyboard: true}
+				followUps, err := llmEngine.SuggestFollowUps(ctx, essence, llmDocs)
+				if err != nil {
+					log.Printf("Ошибка генерации дополнительных вопросов: %v", err)
+				} else if len(followUps) > 0 {
+					replyMarkup = followUpKeyboard(followUps)
+				}
+			}
+
+			// Кнопки оценки ответа для A/B-теста. У сообщения может быть только одна клавиатура:
+			// если под ответом дополнительные вопросы, кнопки оценки отправляются отдельным сообщением.
+			askFeedback := err == nil && abRetrieval != nil
+			if askFeedback && replyMarkup == nil {
+				replyMarkup = feedbackKeyboard(update.Message.From.ID)
+				askFeedback = false
+			}
+
+			response = format.TruncateHTML(format.TelegramSupportedHTML(string(mdToHTML([]byte(response))))+citationsHTML(citations), 4000)
 
 			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 				ChatID:    update.Message.Chat.ID,
@@ -229,42 +567,203 @@ func main() {
 				LinkPreviewOptions: &models.LinkPreviewOptions{
 					IsDisabled: bot.True(),
 				},
+				ReplyMarkup: replyMarkup,
 			})
 
-			log.Println("Ответ:", truncateText(response, 4000))
+			log.Println("Ответ:", response)
 
 			if err != nil {
 				log.Printf("Ошибка отправки сообщения: %v", err)
-			} else {
-				log.Printf("Ответ отправлен в чат ID: %d", update.Message.Chat.ID)
+				return
+			}
+			log.Printf("Ответ отправлен в чат ID: %d", update.Message.Chat.ID)
+
+			if askFeedback {
+				_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+					ChatID:      update.Message.Chat.ID,
+					Text:        "Ответ был полезен?",
+					ReplyMarkup: feedbackKeyboard(update.Message.From.ID),
+				})
 			}
 		}),
 	}
 
-	b, err := bot.New(tgToken, opts...)
+	b, err := bot.New(cfg.TelegramBotToken, opts...)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer cancel()
+	// Перед перезапуском дожидаемся остановки HTTP API, чтобы освободить порт
+	apiStopped := make(chan struct{})
+	if port := api.GetAPIPort(); port != "" {
+		apiServer, err := api.NewServer(vectorStore)
+		if err != nil {
+			return fmt.Errorf("ошибка настройки HTTP API: %w", err)
+		}
+		apiServer.HandleIngest(ingester.IngestURLs)
+		apiServer.HandleDebugSearch(llmEngine)
+		apiServer.HandleQueryStream(newQueryStream(llmEngine, retrievalEngine, settings))
+		if abRetrieval != nil {
+			apiServer.HandleABReport(abRetrieval)
+		}
+		go func() {
+			defer close(apiStopped)
+			log.Printf("HTTP API запущен на порту %s", port)
+			if err := apiServer.Start(ctx, ":"+port); err != nil {
+				log.Printf("Ошибка HTTP API: %v", err)
+			}
+		}()
+	} else {
+		close(apiStopped)
+	}
 
 	log.Println("Bot started...")
 	if me, err := b.GetMe(ctx); err != nil {
-		log.Fatalf("Failed to get bot info: %v", err)
+		cancel()
+		<-apiStopped
+		return fmt.Errorf("failed to get bot info: %w", err)
 	} else {
 		log.Printf("Waiting for messages on @%s (ID: %d)", me.Username, me.ID)
 	}
 
 	b.Start(ctx)
+	<-apiStopped
+
+	if err := embeddingCache.FlushCache(); err != nil {
+		log.Printf("Ошибка сохранения кэша: %v", err)
+	}
+
+	if updateID := restartUpdateID.Load(); updateID != 0 {
+		return &restartError{UpdateID: updateID}
+	}
+	return nil
+}
+
+// toLLMDocuments конвертирует найденные документы в формат для llm.Answer()
+func toLLMDocuments(docs []types.Document) []llm.Document {
+	llmDocs := make([]llm.Document, 0, len(docs))
+	for _, doc := range docs {
+		llmDocs = append(llmDocs, llm.Document{
+			ID:           doc.ID,
+			Header:       doc.Title,
+			Link:         doc.URL,
+			Text:         doc.Content,
+			CodeSnippets: parser.CodeSnippets(doc),
+
+			ReadingTimeSeconds: doc.ReadingTimeSeconds,
+			Embedding:          doc.Embedding,
+		})
+	}
+	return llmDocs
+}
+
+// schedulePrewarm запускает prewarm в каждое время из расписания, пока не отменен ctx
+func schedulePrewarm(ctx context.Context, schedule []retrieval.ClockTime, prewarm func()) {
+	var mu sync.Mutex
+	var timer *time.Timer
+
+	var arm func()
+	arm = func() {
+		next := retrieval.NextPrewarm(time.Now(), schedule)
+		log.Printf("Следующий прогрев эмбеддингов запросов: %s", next.Format("2006-01-02 15:04"))
+
+		mu.Lock()
+		defer mu.Unlock()
+		timer = time.AfterFunc(time.Until(next), func() {
+			if ctx.Err() != nil {
+				return
+			}
+			prewarm()
+			arm()
+		})
+	}
+	arm()
+
+	context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		timer.Stop()
+	})
+}
+
+// prewarmQueries возвращает запросы для прогрева: из WARM_QUERIES_FILE (файл перечитывается при каждом прогреве)
+// и top самых частых вопросов из журнала запросов
+func prewarmQueries(queryLog *querylog.QueryLog, top int) []string {
+	var queries []string
+	if warmQueriesFile := vectorstore.GetWarmQueriesFile(); warmQueriesFile != "" {
+		fromFile, err := vectorstore.LoadWarmQueries(warmQueriesFile)
+		if err != nil {
+			log.Printf("Ошибка загрузки частых запросов: %v", err)
+		}
+		queries = append(queries, fromFile...)
+	}
+	if queryLog != nil {
+		topQueries, err := queryLog.TopQueries(top)
+		if err != nil {
+			log.Printf("%v", err)
+		}
+		for _, item := range topQueries {
+			queries = append(queries, item.Essence)
+		}
+	}
+	return queries
+}
+
+// newRetrievalEngine создает поиск для режима RETRIEVAL_MODE (или RETRIEVAL_AB_MODE)
+func newRetrievalEngine(mode string, vectorStore *vectorstore.VectorStore, llmEngine llm.LLMEngine) retrieval.RetrievalEngine {
+	switch mode {
+	case "hybrid":
+		fmt.Println("Используется гибридный поиск (векторы + ключевые слова)")
+		return retrieval.NewHybridRetrieval(vectorStore, llmEngine)
+	case "qdrant":
+		fmt.Printf("Используется поиск в Qdrant (%s, коллекция %s)\n", retrieval.GetQdrantURL(), retrieval.GetQdrantCollection())
+		return retrieval.NewQdrantRetrieval(retrieval.GetQdrantURL(), retrieval.GetQdrantCollection(), llmEngine)
+	default:
+		vectorRetrieval := retrieval.NewVectorRetrieval(vectorStore, llmEngine)
+		if os.Getenv("QUERY_REWRITE") == "formal" {
+			vectorRetrieval.QueryRewriter = retrieval.FormalizeQuery(llmEngine)
+		}
+		return vectorRetrieval
+	}
+}
+
+// retrievalModeName возвращает название режима поиска для отчетов (пустой режим - vector)
+func retrievalModeName(mode string) string {
+	if mode == "" {
+		return "vector"
+	}
+	return mode
 }
 
-// Безопасное обрезание текста
-func truncateText(text string, maxLen int) string {
-	if len(text) <= maxLen {
-		return text
+// followUpKeyboard строит одноразовую клавиатуру с дополнительными вопросами, по одному в строке
+func followUpKeyboard(questions []string) *models.ReplyKeyboardMarkup {
+	keyboard := make([][]models.KeyboardButton, 0, len(questions))
+	for _, question := range questions {
+		keyboard = append(keyboard, []models.KeyboardButton{{Text: question}})
+	}
+
+	return &models.ReplyKeyboardMarkup{
+		Keyboard:        keyboard,
+		ResizeKeyboard:  true,
+		OneTimeKeyboard: true,
 	}
-	return text[:maxLen]
+}
+
+// citationsHTML форматирует цитаты нумерованным списком для сообщения Telegram
+func citationsHTML(citations []llm.Citation) string {
+	if len(citations) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n<b>Источники:</b>")
+	for i, citation := range citations {
+		fmt.Fprintf(&sb, "\n%d. «%s»", i+1, html.EscapeString(citation.RelevantSentence))
+		if citation.URL != "" {
+			fmt.Fprintf(&sb, " — <a href=\"%s\">%s</a>", html.EscapeString(citation.URL), html.EscapeString(citation.URL))
+		}
+	}
+	return sb.String()
 }
 
 func mdToHTML(md []byte) []byte {
@@ -280,48 +779,3 @@ func mdToHTML(md []byte) []byte {
 
 	return markdown.Render(doc, renderer)
 }
-
-func TelegramSupportedHTML(htmlText string) string {
-	adjustedHTMLText := adjustHTMLTags(htmlText)
-	p := bluemonday.NewPolicy()
-	p.AllowElements("b", "strong", "i", "em", "u", "ins", "s", "strike", "del", "a", "code", "pre")
-	p.AllowAttrs("href").OnElements("a")
-	p.AllowAttrs("class").OnElements("code")
-	return strings.TrimRight(p.Sanitize(adjustedHTMLText), "\n")
-}
-
-// telegram not allow h1-h6 tags
-// replace these tags with a combination of <b> and <i> for visual distinction
-func adjustHTMLTags(htmlText string) string {
-	buff := strings.Builder{}
-	tokenizer := html.NewTokenizer(strings.NewReader(htmlText))
-	for {
-		if tokenizer.Next() == html.ErrorToken {
-			return buff.String()
-		}
-		token := tokenizer.Token()
-		switch token.Type {
-		case html.StartTagToken, html.EndTagToken:
-			switch token.Data {
-			case "h1", "h2", "h3":
-				if token.Type == html.StartTagToken {
-					buff.WriteString("<b>")
-				}
-				if token.Type == html.EndTagToken {
-					buff.WriteString("</b>")
-				}
-			case "h4", "h5", "h6":
-				if token.Type == html.StartTagToken {
-					buff.WriteString("<i><b>")
-				}
-				if token.Type == html.EndTagToken {
-					buff.WriteString("</b></i>")
-				}
-			default:
-				buff.WriteString(token.String())
-			}
-		default:
-			buff.WriteString(token.String())
-		}
-	}
-}
@@ -1,13 +1,39 @@
 package main
 
 import (
+	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// userRateLimitInterval - минимальный интервал между запросами одного пользователя
+const userRateLimitInterval = 10 * time.Second
+
 type RateLimiter struct {
-	users map[int64]time.Time
-	mu    sync.RWMutex
+	users     map[int64]time.Time
+	lastSweep time.Time // когда из users последний раз удалялись неактивные пользователи
+	mu        sync.RWMutex
+
+	total    atomic.Uint64
+	allowed  atomic.Uint64
+	rejected atomic.Uint64
+
+	// Отклоненные запросы за текущие сутки (для /stats); меняются под mu
+	rejectedToday uint64
+	day           string
+}
+
+// RateLimiterStats - статистика ограничителя с момента запуска
+type RateLimiterStats struct {
+	TotalRequests    uint64
+	AllowedRequests  uint64
+	RejectedRequests uint64
+	RejectedToday    uint64 // отклонено с начала текущих суток
+	ActiveUsers      int    // пользователей, для которых хранится время последнего запроса (не дольше двух интервалов)
 }
 
 func NewRateLimiter() *RateLimiter {
@@ -20,10 +46,167 @@ func (rl *RateLimiter) Allow(userID int64) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	rl.total.Add(1)
+
+	// Пользователи, которые больше не пишут, иначе остались бы в памяти навсегда
+	if time.Since(rl.lastSweep) > userRateLimitInterval {
+		rl.sweep()
+	}
+
 	lastReq, exists := rl.users[userID]
-	if !exists || time.Since(lastReq) > 10*time.Second {
+	if !exists || time.Since(lastReq) > userRateLimitInterval {
 		rl.users[userID] = time.Now()
+		rl.allowed.Add(1)
 		return true
 	}
+
+	rl.rejected.Add(1)
+	if today := time.Now().Format(time.DateOnly); today != rl.day {
+		rl.day = today
+		rl.rejectedToday = 0
+	}
+	rl.rejectedToday++
 	return false
 }
+
+// sweep удаляет пользователей, чей последний запрос старше интервала: они уже не ограничены. Вызывается под rl.mu.
+func (rl *RateLimiter) sweep() {
+	for userID, lastReq := range rl.users {
+		if time.Since(lastReq) > userRateLimitInterval {
+			delete(rl.users, userID)
+		}
+	}
+	rl.lastSweep = time.Now()
+}
+
+// Stats возвращает счетчики запросов и число отслеживаемых пользователей
+func (rl *RateLimiter) Stats() RateLimiterStats {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	stats := RateLimiterStats{
+		TotalRequests:    rl.total.Load(),
+		AllowedRequests:  rl.allowed.Load(),
+		RejectedRequests: rl.rejected.Load(),
+		ActiveUsers:      len(rl.users),
+	}
+	if rl.day == time.Now().Format(time.DateOnly) {
+		stats.RejectedToday = rl.rejectedToday
+	}
+	return stats
+}
+
+// RegisterMetrics регистрирует метрики ограничителя в Prometheus
+func (rl *RateLimiter) RegisterMetrics(reg prometheus.Registerer) error {
+	for _, result := range []struct {
+		label string
+		value func(RateLimiterStats) uint64
+	}{
+		{"allowed", func(s RateLimiterStats) uint64 { return s.AllowedRequests }},
+		{"rejected", func(s RateLimiterStats) uint64 { return s.RejectedRequests }},
+	} {
+		value := result.value
+		collector := prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "rag_rate_limiter_requests_total",
+			Help:        "Количество запросов пользователей, проверенных ограничителем, по результату проверки",
+			ConstLabels: prometheus.Labels{"result": result.label},
+		}, func() float64 {
+			return float64(value(rl.Stats()))
+		})
+
+		if err := reg.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	activeUsers := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rag_rate_limiter_active_users",
+		Help: "Количество пользователей, отслеживаемых ограничителем запросов",
+	}, func() float64 {
+		return float64(rl.Stats().ActiveUsers)
+	})
+
+	return reg.Register(activeUsers)
+}
+
+// Ограничение запросов на весь групповой чат по умолчанию
+const (
+	defaultGroupRateLimitRequests = 20
+	defaultGroupRateLimitWindow   = time.Minute
+)
+
+// GetGroupRateLimit возвращает лимит запросов группового чата за окно
+// (GROUP_RATE_LIMIT_REQUESTS, GROUP_RATE_LIMIT_WINDOW в формате 30s, 1m)
+func GetGroupRateLimit() (requests int, window time.Duration) {
+	requests, err := strconv.Atoi(os.Getenv("GROUP_RATE_LIMIT_REQUESTS"))
+	if err != nil || requests <= 0 {
+		requests = defaultGroupRateLimitRequests
+	}
+
+	window, err = time.ParseDuration(os.Getenv("GROUP_RATE_LIMIT_WINDOW"))
+	if err != nil || window <= 0 {
+		window = defaultGroupRateLimitWindow
+	}
+
+	return requests, window
+}
+
+// GroupRateLimiter ограничивает число запросов от всех участников группового чата вместе (token bucket):
+// у каждого чата до requests токенов, которые восстанавливаются равномерно за window
+type GroupRateLimiter struct {
+	requests  int
+	window    time.Duration
+	buckets   map[int64]*tokenBucket
+	lastSweep time.Time // когда из buckets последний раз удалялись неактивные чаты
+	mu        sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewGroupRateLimiter(requests int, window time.Duration) *GroupRateLimiter {
+	return &GroupRateLimiter{
+		requests: requests,
+		window:   window,
+		buckets:  make(map[int64]*tokenBucket),
+	}
+}
+
+func (rl *GroupRateLimiter) Allow(chatID int64) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.lastSweep) > rl.window {
+		rl.sweep(now)
+	}
+
+	bucket, exists := rl.buckets[chatID]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(rl.requests), updated: now}
+		rl.buckets[chatID] = bucket
+	}
+
+	refill := now.Sub(bucket.updated).Seconds() / rl.window.Seconds() * float64(rl.requests)
+	bucket.tokens = min(float64(rl.requests), bucket.tokens+refill)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// sweep удаляет чаты без запросов дольше окна: их токены уже восстановились полностью,
+// и новый bucket для них ничем не отличается от удаленного. Вызывается под rl.mu.
+func (rl *GroupRateLimiter) sweep(now time.Time) {
+	for chatID, bucket := range rl.buckets {
+		if now.Sub(bucket.updated) > rl.window {
+			delete(rl.buckets, chatID)
+		}
+	}
+	rl.lastSweep = now
+}
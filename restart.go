@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// restartError возвращается runBot, когда администратор запросил перезапуск командой /restart
+type restartError struct {
+	// UpdateID - обновление с командой /restart; новый запуск начинает получать обновления после него,
+	// иначе Telegram повторно доставит команду и бот уйдет в бесконечный перезапуск
+	UpdateID int64
+}
+
+func (e *restartError) Error() string {
+	return fmt.Sprintf("запрошен перезапуск бота (обновление %d)", e.UpdateID)
+}
+
+// runRegisterer запоминает метрики одного запуска бота, чтобы снять их с регистрации перед перезапуском:
+// иначе новые хранилище и кэш не смогут зарегистрировать метрики с теми же именами
+type runRegisterer struct {
+	prometheus.Registerer
+
+	mu         sync.Mutex
+	collectors []prometheus.Collector
+}
+
+func newRunRegisterer(reg prometheus.Registerer) *runRegisterer {
+	return &runRegisterer{Registerer: reg}
+}
+
+func (r *runRegisterer) Register(collector prometheus.Collector) error {
+	if err := r.Registerer.Register(collector); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, collector)
+	return nil
+}
+
+func (r *runRegisterer) MustRegister(collectors ...prometheus.Collector) {
+	for _, collector := range collectors {
+		if err := r.Register(collector); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// unregisterAll снимает с регистрации все метрики, зарегистрированные через r
+func (r *runRegisterer) unregisterAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, collector := range r.collectors {
+		r.Registerer.Unregister(collector)
+	}
+	r.collectors = nil
+}
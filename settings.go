@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ad/rag-bot/internal/retrieval"
+)
+
+// Settings - параметры бота, которые администратор может менять без перезапуска командой /settings
+type Settings struct {
+	topK atomic.Int32
+	maxK int
+}
+
+func NewSettings() *Settings {
+	s := &Settings{maxK: retrieval.GetMaxK()}
+	s.topK.Store(int32(retrieval.GetTopK()))
+	return s
+}
+
+// TopK возвращает число документов, которые ищутся на один запрос
+func (s *Settings) TopK() int {
+	return int(s.topK.Load())
+}
+
+// SetTopK меняет число документов на запрос в пределах от 1 до RETRIEVAL_MAX_K
+func (s *Settings) SetTopK(k int) error {
+	if k < 1 || k > s.maxK {
+		return fmt.Errorf("topk должен быть от 1 до %d", s.maxK)
+	}
+	s.topK.Store(int32(k))
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ad/rag-bot/internal/api"
+	"github.com/ad/rag-bot/internal/llm"
+	"github.com/ad/rag-bot/internal/retrieval"
+)
+
+// newQueryStream возвращает обработчик вопросов для WebSocket API: суть вопроса, поиск документов
+// и ответ, который передается по фрагментам, если движок поддерживает потоковую генерацию
+func newQueryStream(llmEngine llm.LLMEngine, retrievalEngine retrieval.RetrievalEngine, settings *Settings) api.QueryStreamFunc {
+	return func(ctx context.Context, query string, onToken func(token string) error) ([]api.Source, error) {
+		freeText, filters, err := parseUserQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка в запросе: %w", err)
+		}
+
+		essence, err := llmEngine.ExtractEssence(ctx, freeText)
+		if err != nil {
+			log.Printf("Ошибка выделения сути вопроса: %v", err)
+			essence = freeText // fallback на исходный запрос
+		}
+
+		// Запрос без пользователя: A/B-тест поиска его не учитывает
+		docs, err := retrievalEngine.FindWithContext(ctx, essence, retrieval.SearchOptions{Filters: filters}, settings.TopK())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка поиска документов: %w", err)
+		}
+		if len(docs) == 0 {
+			return nil, onToken("Не найдено подходящих документов по вашему запросу.")
+		}
+
+		sources := make([]api.Source, 0, len(docs))
+		for _, doc := range docs {
+			sources = append(sources, api.Source{Title: doc.Title, URL: doc.URL})
+		}
+
+		llmDocs := llm.DeduplicateDocs(toLLMDocuments(docs))
+		// Движок с журналом аудита не поддерживает потоковый ответ: отдаем ответ одним фрагментом
+		if streamer, ok := llmEngine.(llm.AnswerStreamer); ok {
+			_, err = streamer.AnswerStream(ctx, essence, llmDocs, onToken)
+			return sources, err
+		}
+
+		response, err := llmEngine.Answer(ctx, essence, llmDocs)
+		if err != nil {
+			return sources, err
+		}
+		return sources, onToken(response)
+	}
+}